@@ -4,39 +4,78 @@ import (
 	"os"
 	"queuectl/internal/cli"
 	"queuectl/internal/store"
+	"queuectl/pkg/client"
 )
 
 func main() {
-	st, err := store.NewStore("queue.db")
-	if err != nil {
-		panic(err)
-	}
-	//log.Println("DB Created")
+	// the root command opens the database into st once flags are parsed
+	st := &store.Store{}
+	c := client.NewFromStore(st)
 
-	root := cli.NewRootCmd()
-	root.AddCommand(cli.NewEnqueueCmd(st))
-	root.AddCommand(cli.NewListCmd(st))
-	root.AddCommand(cli.NewStatusCmd(st))
+	root := cli.NewRootCmd(st)
+	root.AddCommand(cli.NewEnqueueCmd(c))
+	root.AddCommand(cli.NewListCmd(c))
+	root.AddCommand(cli.NewCancelCmd(c))
+	root.AddCommand(cli.NewStatusCmd(c, st))
+	root.AddCommand(cli.NewGCCmd(st))
 	root.AddCommand(cli.NewResetCmd(st))
+	root.AddCommand(cli.NewLogsCmd(st))
+	root.AddCommand(cli.NewServeCmd(st))
 
 	//worker cli's
 	workerRoot := cli.NewWorkerRootCmd()
-	workerRoot.AddCommand(cli.NewWorkerCmd(st))
+	workerRoot.AddCommand(cli.NewWorkerCmd(c))
 	workerRoot.AddCommand(cli.NewWorkerStopCmd())
 	root.AddCommand(workerRoot)
 
 	//dlq cli's
 	dlqRoot := cli.NewDLQRootCmd()
-	dlqRoot.AddCommand(cli.NewDLQListCmd(st))
-	dlqRoot.AddCommand(cli.NewDLQRetryCmd(st))
+	dlqRoot.AddCommand(cli.NewDLQListCmd(c))
+	dlqRoot.AddCommand(cli.NewDLQRetryCmd(c))
+	dlqRoot.AddCommand(cli.NewDLQShowCmd(c))
+	dlqRoot.AddCommand(cli.NewDLQPurgeCmd(c))
+	dlqRoot.AddCommand(cli.NewDLQExportCmd(c))
 	root.AddCommand(dlqRoot)
 
+	//queue cli's
+	queueRoot := cli.NewQueueRootCmd()
+	queueRoot.AddCommand(cli.NewQueueListCmd(st))
+	queueRoot.AddCommand(cli.NewQueueLimitCmd(st))
+	root.AddCommand(queueRoot)
+
+	//schedule cli's
+	scheduleRoot := cli.NewScheduleRootCmd()
+	scheduleRoot.AddCommand(cli.NewScheduleAddCmd(st))
+	scheduleRoot.AddCommand(cli.NewScheduleListCmd(st))
+	scheduleRoot.AddCommand(cli.NewScheduleRemoveCmd(st))
+	scheduleRoot.AddCommand(cli.NewSchedulePauseCmd(st))
+	scheduleRoot.AddCommand(cli.NewScheduleResumeCmd(st))
+	scheduleRoot.AddCommand(cli.NewScheduleRunCmd(st))
+	root.AddCommand(scheduleRoot)
+
 	//config cli's
 	configRoot := cli.NewConfigRootCmd()
 	configRoot.AddCommand(cli.NewConfigSetCmd(st))
 	configRoot.AddCommand(cli.NewConfigGetCmd(st))
+	configRoot.AddCommand(cli.NewConfigUnsetCmd(st))
+	configRoot.AddCommand(cli.NewConfigListCmd(st))
+	configRoot.AddCommand(cli.NewConfigDescribeCmd(st))
 	root.AddCommand(configRoot)
 
+	//migrate cli's
+	migrateRoot := cli.NewMigrateRootCmd()
+	migrateRoot.AddCommand(cli.NewMigrateStatusCmd(st))
+	migrateRoot.AddCommand(cli.NewMigrateUpCmd(st))
+	migrateRoot.AddCommand(cli.NewMigrateDownCmd(st))
+	root.AddCommand(migrateRoot)
+
+	//context cli's
+	contextRoot := cli.NewContextRootCmd()
+	contextRoot.AddCommand(cli.NewContextAddCmd())
+	contextRoot.AddCommand(cli.NewContextUseCmd())
+	contextRoot.AddCommand(cli.NewContextListCmd())
+	root.AddCommand(contextRoot)
+
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCancelCmd(c *client.Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel <jobID>",
+		Short: "Cancel a queued job, or stop a running one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			running, err := c.Cancel(context.Background(), id)
+			if err != nil {
+				return fmt.Errorf("cancel failed: %w", err)
+			}
+			if running {
+				fmt.Println("Cancel requested, the worker will stop job:", id)
+				return nil
+			}
+			fmt.Println("Job cancelled:", id)
+			return nil
+		},
+	}
+}
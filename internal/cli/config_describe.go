@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewConfigDescribeCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe [key]",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Explain config keys: type, default, allowed values and current value",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings := store.Settings()
+			if len(args) == 1 {
+				s, ok := store.LookupSetting(args[0])
+				if !ok {
+					return fmt.Errorf("unknown config key %q (see `queuectl config list`)", args[0])
+				}
+				settings = []store.Setting{s}
+			}
+
+			for i, s := range settings {
+				current, err := st.GetConfig(context.Background(), s.Key)
+				if err != nil {
+					return err
+				}
+				def := s.Default
+				if s.Secret && current != "" {
+					current = "********"
+				}
+				if def == "" {
+					def = "(not set)"
+				}
+				if current == "" {
+					current = "(not set)"
+				}
+
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Println(s.Key)
+				fmt.Printf("  %s\n", s.Description)
+				fmt.Printf("  Type:    %s\n", s.Type)
+				fmt.Printf("  Allowed: %s\n", s.Allowed())
+				fmt.Printf("  Default: %s\n", def)
+				fmt.Printf("  Current: %s\n", current)
+			}
+			return nil
+		},
+	}
+}
@@ -18,6 +18,9 @@ func NewConfigGetCmd(st *store.Store) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if _, known := store.LookupSetting(args[0]); !known && val == "" {
+				return fmt.Errorf("unknown config key %q (see `queuectl config list`)", args[0])
+			}
 			if val == "" {
 				fmt.Println("(not set)")
 			} else {
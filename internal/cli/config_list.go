@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func NewConfigListCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		Short: "List every config value and whether it is the default or was set",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := st.ConfigOverrides(context.Background())
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%-22s | %-10s | %s\n", "KEY", "SOURCE", "VALUE")
+			for _, s := range store.Settings() {
+				value, source := s.Default, "default"
+				if v, ok := overrides[s.Key]; ok {
+					value, source = v, "set"
+					if _, err := s.Validate(v); err != nil {
+						source = "invalid"
+					}
+				}
+				if s.Secret && value != "" {
+					value = "********"
+				}
+				if value == "" {
+					value = "(not set)"
+				}
+				fmt.Printf("%-22s | %-10s | %s\n", s.Key, source, value)
+			}
+
+			// left by older versions or typos before keys were checked;
+			// nothing reads them, `config unset` removes them
+			var unknown []string
+			for k := range overrides {
+				if _, ok := store.LookupSetting(k); !ok {
+					unknown = append(unknown, k)
+				}
+			}
+			sort.Strings(unknown)
+			for _, k := range unknown {
+				fmt.Printf("%-22s | %-10s | %s\n", k, "unknown", overrides[k])
+			}
+			return nil
+		},
+	}
+}
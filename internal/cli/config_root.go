@@ -5,6 +5,6 @@ import "github.com/spf13/cobra"
 func NewConfigRootCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "config",
-		Short: "Basic configuration: set, get",
+		Short: "Configuration: set, get, unset, list, describe",
 	}
 }
@@ -12,12 +12,20 @@ func NewConfigSetCmd(st *store.Store) *cobra.Command {
 	return &cobra.Command{
 		Use:   "set <key> <value>",
 		Args:  cobra.ExactArgs(2),
-		Short: "Set a config value",
+		Short: "Set a config value (see `config list` for the keys)",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
 			key, value := args[0], args[1]
-			if err := st.SetConfig(context.Background(), key, value); err != nil {
+			if err := st.SetConfig(ctx, key, value); err != nil {
 				return fmt.Errorf("failed to set config: %w", err)
 			}
+			value, err := st.GetConfig(ctx, key)
+			if err != nil {
+				return err
+			}
+			if s, _ := store.LookupSetting(key); s.Secret {
+				value = "********"
+			}
 			fmt.Println("Updated:", key, "=", value)
 			return nil
 		},
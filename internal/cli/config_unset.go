@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewConfigUnsetCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset <key>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Put a config value back to its default",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			key := args[0]
+			if err := st.UnsetConfig(ctx, key); err != nil {
+				return fmt.Errorf("failed to unset config: %w", err)
+			}
+			value, err := st.GetConfig(ctx, key)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				fmt.Println("Unset:", key)
+			} else {
+				fmt.Println("Reset:", key, "=", value, "(default)")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"queuectl/internal/userconfig"
+
+	"github.com/spf13/cobra"
+)
+
+func NewContextAddCmd() *cobra.Command {
+	var use bool
+
+	cmd := &cobra.Command{
+		Use:         "add <name> <db-path>",
+		Short:       "Add or update a named context",
+		Args:        cobra.ExactArgs(2),
+		Annotations: map[string]string{dbUse: dbNone},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := userconfig.ValidateContextName(name); err != nil {
+				return err
+			}
+			// contexts are used from any directory
+			path, err := filepath.Abs(args[1])
+			if err != nil {
+				return err
+			}
+
+			cfg, err := userconfig.Load()
+			if err != nil {
+				return err
+			}
+			_, existed := cfg.Contexts[name]
+			cfg.Contexts[name] = userconfig.Context{DB: path}
+			if use {
+				cfg.CurrentContext = name
+			}
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save contexts: %w", err)
+			}
+
+			verb := "Added"
+			if existed {
+				verb = "Updated"
+			}
+			fmt.Printf("%s context %s: %s\n", verb, name, path)
+			if use {
+				fmt.Println("Switched to context", name)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&use, "use", false, "Also make it the current context")
+	return cmd
+}
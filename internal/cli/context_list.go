@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"queuectl/internal/userconfig"
+
+	"github.com/spf13/cobra"
+)
+
+func NewContextListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "list",
+		Short:       "List contexts and show which database is in use",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{dbUse: dbNone},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := userconfig.Load()
+			if err != nil {
+				return err
+			}
+
+			if len(cfg.Contexts) == 0 {
+				fmt.Println("No contexts. Add one with `queuectl context add <name> <db-path>`.")
+			}
+			for _, name := range cfg.Names() {
+				mark := " "
+				if name == cfg.CurrentContext {
+					mark = "*"
+				}
+				fmt.Printf("%s %s | %s\n", mark, name, cfg.Contexts[name].DB)
+			}
+
+			path, source, err := resolveDB(cmd)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nUsing %s (from %s)\n", path, source)
+			return nil
+		},
+	}
+}
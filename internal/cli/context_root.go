@@ -0,0 +1,17 @@
+package cli
+
+import "github.com/spf13/cobra"
+
+func NewContextRootCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "context",
+		Short: "Named database locations: add, use, list",
+		Long: `Contexts name queue databases, e.g. staging and production, so commands can
+switch between them explicitly. They are kept in queuectl/config.json under
+the user config dir ($XDG_CONFIG_HOME or ~/.config).
+
+The database a command uses is the first of: --db, --context,
+$QUEUECTL_DB, the current context, and queuectl/queue.db under the user data
+dir ($XDG_DATA_HOME or ~/.local/share).`,
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"queuectl/internal/userconfig"
+
+	"github.com/spf13/cobra"
+)
+
+func NewContextUseCmd() *cobra.Command {
+	var none bool
+
+	cmd := &cobra.Command{
+		Use:         "use <name>",
+		Short:       "Switch the current context",
+		Annotations: map[string]string{dbUse: dbNone},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if none {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := userconfig.Load()
+			if err != nil {
+				return err
+			}
+			if none {
+				cfg.CurrentContext = ""
+			} else {
+				if _, ok := cfg.Contexts[args[0]]; !ok {
+					return fmt.Errorf("unknown context %q (see `queuectl context list`)", args[0])
+				}
+				cfg.CurrentContext = args[0]
+			}
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save contexts: %w", err)
+			}
+
+			if none {
+				fmt.Println("No current context, using the default database")
+			} else {
+				fmt.Println("Switched to context", args[0])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&none, "none", false, "Clear the current context and go back to the default database")
+	return cmd
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"queuectl/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDLQExportCmd(c *client.Client) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write every DLQ job as JSON Lines",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobs, err := c.ListDead(context.Background())
+			if err != nil {
+				return err
+			}
+
+			toFile := output != "" && output != "-"
+			var w io.Writer = os.Stdout
+			var f *os.File
+			if toFile {
+				f, err = os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close() // on the error paths; closed explicitly below
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			for _, j := range jobs {
+				if err := enc.Encode(j); err != nil {
+					return err
+				}
+			}
+			if toFile {
+				// a failed close can mean the data never reached the disk
+				if err := f.Close(); err != nil {
+					return fmt.Errorf("write %s: %w", output, err)
+				}
+				fmt.Fprintf(os.Stderr, "%d job(s) exported to %s\n", len(jobs), output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write instead of stdout")
+	return cmd
+}
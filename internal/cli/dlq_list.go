@@ -3,17 +3,18 @@ package cli
 import (
 	"context"
 	"fmt"
-	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func NewDLQListCmd(st *store.Store) *cobra.Command {
+func NewDLQListCmd(c *client.Client) *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
 		Short: "List jobs in the dead letter queue",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			jobs, err := st.ListDLQ(context.Background())
+			jobs, err := c.ListDead(context.Background())
 			if err != nil {
 				return err
 			}
@@ -24,8 +25,8 @@ func NewDLQListCmd(st *store.Store) *cobra.Command {
 			}
 
 			for _, j := range jobs {
-				fmt.Printf("%s | attempts=%d/%d | command=%s\n",
-					j.ID, j.Attempts, j.MaxRetries, j.Command)
+				fmt.Printf("%s | failed=%s | attempts=%d/%d | command=%s | error=%s\n",
+					j.ID, j.FailedAt.Format(time.RFC3339), j.Attempts, j.MaxRetries, j.Display(), j.LastError)
 			}
 			return nil
 		},
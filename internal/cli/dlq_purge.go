@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDLQPurgeCmd(c *client.Client) *cobra.Command {
+	var all bool
+	var ids []string
+	var olderThan string
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete jobs from the dead letter queue",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var age time.Duration
+			if olderThan != "" {
+				var err error
+				age, err = store.ParseAge(olderThan)
+				if err != nil {
+					return fmt.Errorf("invalid --older-than: %w", err)
+				}
+			}
+			// ParseAge reads 0 as "no limit", which must not empty the DLQ without --all.
+			if !all && len(ids) == 0 && age == 0 {
+				return errors.New("give --id, --older-than (more than 0), or --all to empty the DLQ")
+			}
+
+			f := client.DeadFilter{IDs: ids}
+			if age > 0 {
+				f.Before = time.Now().UTC().Add(-age)
+			}
+
+			n, err := c.PurgeDeadWhere(context.Background(), f)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d job(s) purged from the DLQ\n", n)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Purge every DLQ job (narrowed by --id and --older-than)")
+	cmd.Flags().StringSliceVar(&ids, "id", nil, "Purge this job; repeat or comma-separate for several")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only purge jobs that failed longer ago than this, e.g. 30d or 12h")
+	return cmd
+}
@@ -2,24 +2,61 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func NewDLQRetryCmd(st *store.Store) *cobra.Command {
-	return &cobra.Command{
-		Use:   "retry <jobID>",
-		Short: "Move a job from DLQ back to the queue",
-		Args:  cobra.ExactArgs(1),
+func NewDLQRetryCmd(c *client.Client) *cobra.Command {
+	var all bool
+	var filter string
+	var since time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "retry [jobID]",
+		Short: "Move a job, or every matching job, from DLQ back to the queue",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			id := args[0]
-			if err := st.RetryDLQ(context.Background(), id); err != nil {
-				return fmt.Errorf("retry failed: %w", err)
+			bulk := all || filter != "" || since > 0
+			if len(args) == 1 {
+				if bulk {
+					return errors.New("give a job ID or --all/--filter/--since, not both")
+				}
+				id := args[0]
+				if err := c.RetryDead(context.Background(), id); err != nil {
+					return fmt.Errorf("retry failed: %w", err)
+				}
+				fmt.Println("Job returned to queue:", id)
+				return nil
+			}
+			if !bulk {
+				return errors.New("give a job ID, or --all to retry the whole DLQ")
+			}
+			if since < 0 {
+				return fmt.Errorf("invalid --since %s", since)
+			}
+
+			f := client.DeadFilter{Command: filter}
+			if since > 0 {
+				f.Since = time.Now().UTC().Add(-since)
+			}
+			ids, err := c.RetryDeadWhere(context.Background(), f)
+			if err != nil {
+				return fmt.Errorf("retry failed, no jobs were moved: %w", err)
 			}
-			fmt.Println("Job returned to queue:", id)
+			for _, id := range ids {
+				fmt.Println("Job returned to queue:", id)
+			}
+			fmt.Printf("%d job(s) retried\n", len(ids))
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Retry every job in the DLQ (narrowed by --filter and --since)")
+	cmd.Flags().StringVar(&filter, "filter", "", "Only retry jobs whose command matches this glob, e.g. '*backup*'")
+	cmd.Flags().DurationVar(&since, "since", 0, "Only retry jobs that failed within this long, e.g. 1h")
+	return cmd
 }
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"queuectl/pkg/client"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDLQShowCmd(c *client.Client) *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "show <jobID>",
+		Short: "Show everything stored about a job in the DLQ",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			j, err := c.GetDead(context.Background(), args[0])
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(j)
+			}
+
+			fmt.Printf("ID:          %s\n", j.ID)
+			fmt.Printf("Runs:        %s\n", j.Display())
+			if j.Workdir != "" {
+				fmt.Printf("Workdir:     %s\n", j.Workdir)
+			}
+			if len(j.Env) > 0 {
+				// names only, values may be secrets; --json shows them
+				keys := make([]string, 0, len(j.Env))
+				for k := range j.Env {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				fmt.Printf("Env:         %s\n", strings.Join(keys, ", "))
+			}
+			fmt.Printf("Queue:       %s (priority %d)\n", j.Queue, j.Priority)
+			fmt.Printf("Attempts:    %d/%d\n", j.Attempts, j.MaxRetries)
+			if j.Timeout > 0 {
+				fmt.Printf("Timeout:     %ds\n", j.Timeout)
+			}
+			fmt.Printf("Created:     %s\n", j.CreatedAt.Format(time.RFC3339))
+			fmt.Printf("Failed:      %s\n", j.FailedAt.Format(time.RFC3339))
+			fmt.Printf("Last error:  %s\n", j.LastError)
+			fmt.Printf("\nSee `queuectl logs %s` for the output of each attempt.\n", j.ID)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the job as json, including env values")
+	return cmd
+}
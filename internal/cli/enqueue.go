@@ -1,45 +1,197 @@
 package cli
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
-	"queuectl/internal/model"
+	"io"
+	"os"
 	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func NewEnqueueCmd(st *store.Store) *cobra.Command {
+// maxJobLine bounds one line of an --file job list.
+const maxJobLine = 4 << 20
+
+func NewEnqueueCmd(c *client.Client) *cobra.Command {
+	var priority int
+	var queue, runAt, delay, uniqueKey, file string
+	var uniqueFor time.Duration
+	var atomic bool
+
 	cmd := &cobra.Command{
-		Use:   "enqueue '{\"id\":\"job1\",\"command\":\"sleep 2\"}'",
+		Use:   "enqueue '{\"id\":\"job1\",\"command\":\"sleep 2\"}' | --file jobs.jsonl",
 		Short: "Add a job to the queue",
-		Args:  cobra.ExactArgs(1),
+		Long: `Add a job to the queue.
+
+The id may be left out, a ULID is generated for it. With a unique key set,
+enqueueing while another job holds the same key adds nothing and reports the
+existing job instead.
+
+With --file, jobs are read one json object per line (JSON Lines) from the
+file, or from stdin when it is "-", and enqueued in batches. Bad lines are
+reported with their line number and skipped, unless --atomic is given, in
+which case nothing is enqueued unless every line is.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("file") {
+				return cobra.NoArgs(cmd, args)
+			}
+			if cmd.Flags().Changed("atomic") {
+				return fmt.Errorf("--atomic needs --file")
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var j model.Job
-			if err := json.Unmarshal([]byte(args[0]), &j); err != nil {
-				return fmt.Errorf("invalid job json: %w", err)
+			// flags win over the json fields
+			now := time.Now().UTC()
+			if cmd.Flags().Changed("run-at") && cmd.Flags().Changed("delay") {
+				return fmt.Errorf("use either --run-at or --delay, not both")
+			}
+			var at time.Time
+			if cmd.Flags().Changed("run-at") {
+				t, err := time.Parse(time.RFC3339, runAt)
+				if err != nil {
+					return fmt.Errorf("invalid --run-at %q (want RFC3339, e.g. 2025-01-02T15:04:05Z): %w", runAt, err)
+				}
+				at = t.UTC()
+			}
+			if cmd.Flags().Changed("delay") {
+				d, err := time.ParseDuration(delay)
+				if err != nil || d < 0 {
+					return fmt.Errorf("invalid --delay %q (want a non-negative duration, e.g. 90s or 2h)", delay)
+				}
+				at = now.Add(d)
+			}
+			if cmd.Flags().Changed("unique-for") && (uniqueFor < 0 || uniqueFor%time.Second != 0) {
+				return fmt.Errorf("invalid --unique-for %s (want whole seconds, e.g. 10m)", uniqueFor)
+			}
+			override := func(j *client.Job) {
+				if cmd.Flags().Changed("priority") {
+					j.Priority = priority
+				}
+				if cmd.Flags().Changed("queue") {
+					j.Queue = queue
+				}
+				if !at.IsZero() {
+					j.RunAt = at
+				}
+				if cmd.Flags().Changed("unique-key") {
+					j.UniqueKey = uniqueKey
+				}
+				if cmd.Flags().Changed("unique-for") {
+					j.UniqueFor = int(uniqueFor / time.Second)
+				}
+			}
+
+			if cmd.Flags().Changed("file") {
+				return enqueueFile(c, file, atomic, override)
 			}
 
-			// Fill defaults
-			j.State = "pending"
-			j.Attempts = 0
-			j.CreatedAt = time.Now().UTC()
-			j.UpdatedAt = j.CreatedAt
-			j.AvailableAt = j.CreatedAt
-			if j.MaxRetries == 0 {
-				j.MaxRetries = 3
+			j, err := client.ParseJob([]byte(args[0]))
+			if err != nil {
+				return err
 			}
+			override(&j)
 
-			err := st.Enqueue(context.Background(), j)
+			stored, err := c.Enqueue(context.Background(), j)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println("Job enqueued:", j.ID)
+			if stored.Deduplicated {
+				fmt.Printf("Job deduplicated, existing job: %s (%s)\n", stored.ID, stored.State)
+				return nil
+			}
+			msg := "Job enqueued: " + stored.ID
+			if stored.Queue != "" && stored.Queue != store.DefaultQueue {
+				msg += " (queue " + stored.Queue + ")"
+			}
+			if stored.RunAt.After(time.Now().UTC()) {
+				msg += ", scheduled for " + stored.RunAt.Format(time.RFC3339)
+			}
+			fmt.Println(msg)
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVar(&priority, "priority", 0, "Job priority, higher runs first (overrides json)")
+	cmd.Flags().StringVar(&queue, "queue", "", "Queue to add the job to (overrides json, default \"default\")")
+	cmd.Flags().StringVar(&runAt, "run-at", "", "Run no earlier than this RFC3339 time (overrides json)")
+	cmd.Flags().StringVar(&delay, "delay", "", "Run after this Go duration, e.g. 90s or 2h (overrides json)")
+	cmd.Flags().StringVar(&uniqueKey, "unique-key", "", "Deduplicate against jobs holding this key (overrides json)")
+	cmd.Flags().DurationVar(&uniqueFor, "unique-for", 0, "Hold the unique key for this long after enqueue instead of while the job is active")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Enqueue every job in this JSON Lines file, - for stdin (flags apply to each job)")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "With --file, enqueue nothing unless every job is valid and accepted")
 	return cmd
 }
+
+// enqueueFile enqueues the jobs in a JSON Lines file, reporting bad lines on
+// stderr by line number.
+func enqueueFile(c *client.Client, path string, atomic bool, override func(*client.Job)) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var jobs []client.Job
+	var lines []int // line number of each job
+	invalid := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxJobLine)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		j, err := client.ParseJob([]byte(line))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", n, err)
+			invalid++
+			continue
+		}
+		override(&j)
+		jobs = append(jobs, j)
+		lines = append(lines, n)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if atomic && invalid > 0 {
+		return fmt.Errorf("%d invalid line(s), nothing was enqueued", invalid)
+	}
+
+	results, err := c.EnqueueBatch(context.Background(), jobs, atomic)
+	enqueued, deduplicated, failed := 0, 0, invalid
+	for i, res := range results {
+		switch {
+		case res.Err != nil:
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", lines[i], res.Err)
+			failed++
+		case res.Deduplicated:
+			deduplicated++
+		case res.ID != "":
+			enqueued++
+		}
+	}
+	if atomic && err != nil {
+		return fmt.Errorf("nothing was enqueued")
+	}
+
+	fmt.Printf("%d job(s) enqueued, %d deduplicated, %d failed\n", enqueued, deduplicated, failed)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d job(s) failed", failed, len(jobs)+invalid)
+	}
+	return nil
+}
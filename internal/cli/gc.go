@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewGCCmd(st *store.Store) *cobra.Command {
+	var olderThan string
+	var vacuum bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete old completed and cancelled jobs and compact the database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if olderThan == "" {
+				v, err := st.GetConfig(ctx, "completed_retention")
+				if err != nil {
+					return err
+				}
+				olderThan = v
+			}
+			keep, err := store.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid retention %q: %w", olderThan, err)
+			}
+			if keep == 0 {
+				return errors.New("no retention set: pass --older-than or `queuectl config set completed_retention 7d`")
+			}
+
+			n, err := st.DeleteFinishedJobs(ctx, time.Now().UTC().Add(-keep))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %d finished job(s) older than %s\n", n, olderThan)
+
+			if vacuum {
+				if err := st.Vacuum(ctx); err != nil {
+					return err
+				}
+				fmt.Println("Database vacuumed")
+				return nil
+			}
+			return st.Compact(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Delete jobs finished longer ago than this, e.g. 7d (default completed_retention)")
+	cmd.Flags().BoolVar(&vacuum, "vacuum", false, "Rebuild the whole database file; needs no other queuectl process running")
+	return cmd
+}
@@ -3,19 +3,28 @@ package cli
 import (
 	"context"
 	"fmt"
-	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func NewListCmd(st *store.Store) *cobra.Command {
-	var state string
+func NewListCmd(c *client.Client) *cobra.Command {
+	var state, queue, sortBy string
+	var minPriority, limit int
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List jobs in the queue",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			jobs, err := st.ListJobs(context.Background(), state)
+			opts := client.ListOptions{State: state, Queue: queue, SortBy: sortBy, Limit: limit}
+			if cmd.Flags().Changed("min-priority") {
+				opts.MinPriority = &minPriority
+			}
+
+			jobs, err := c.List(context.Background(), opts)
 			if err != nil {
 				return err
 			}
@@ -25,14 +34,47 @@ func NewListCmd(st *store.Store) *cobra.Command {
 				return nil
 			}
 
+			now := time.Now().UTC()
 			for _, j := range jobs {
-				fmt.Printf("%s | %-10s | attempts=%d/%d | %s\n",
-					j.ID, j.State, j.Attempts, j.MaxRetries, j.Command)
+				when := ""
+				if j.State == "pending" && j.RunAt.After(now) {
+					when = " | scheduled=" + j.RunAt.Format(time.RFC3339)
+				}
+				if j.State == "blocked" {
+					when = " | waiting on " + strings.Join(j.WaitingOn, ", ")
+				}
+				if j.CancelRequested {
+					when = " | cancel requested"
+				}
+				fmt.Printf("%s | %-10s | queue=%s | prio=%d | attempts=%d/%d%s | %s%s\n",
+					j.ID, j.State, j.Queue, j.Priority, j.Attempts, j.MaxRetries, when, j.Display(), payloadDetails(j))
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&state, "state", "", "Filter by job state (pending,processing,completed,dead)")
+	cmd.Flags().StringVar(&state, "state", "", "Filter by job state (pending,blocked,processing,completed,dead,cancelled) or scheduled for pending jobs not yet due")
+	cmd.Flags().StringVar(&queue, "queue", "", "Filter by queue")
+	cmd.Flags().StringVar(&sortBy, "sort", "created", "Sort order (created,priority)")
+	cmd.Flags().IntVar(&minPriority, "min-priority", 0, "Only show jobs with at least this priority")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Show at most this many jobs (0 = all)")
 	return cmd
 }
+
+// payloadDetails lists a job's env names (never values, they may be
+// secrets) and working directory.
+func payloadDetails(j client.Job) string {
+	var out string
+	if len(j.Env) > 0 {
+		keys := make([]string, 0, len(j.Env))
+		for k := range j.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out += " | env=" + strings.Join(keys, ",")
+	}
+	if j.Workdir != "" {
+		out += " | workdir=" + j.Workdir
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewLogsCmd(st *store.Store) *cobra.Command {
+	var attemptNum int
+	var follow bool
+
+	cmd := &cobra.Command{
+		Use:   "logs <jobID>",
+		Short: "Show captured output and exit status of a job's attempts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			id := args[0]
+
+			if follow {
+				return followAttempt(ctx, st, id, attemptNum)
+			}
+
+			var attempts []model.Attempt
+			if attemptNum > 0 {
+				a, err := st.GetAttempt(ctx, id, attemptNum)
+				if err != nil {
+					return err
+				}
+				if a == nil {
+					return fmt.Errorf("job %s has no attempt %d", id, attemptNum)
+				}
+				attempts = append(attempts, *a)
+			} else {
+				var err error
+				attempts, err = st.ListAttempts(ctx, id)
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(attempts) == 0 {
+				fmt.Println("No attempts recorded for", id)
+				return nil
+			}
+
+			for _, a := range attempts {
+				printAttemptHeader(a)
+				if a.Stdout != "" {
+					fmt.Println("--- stdout")
+					fmt.Print(a.Stdout)
+				}
+				if a.Stderr != "" {
+					fmt.Println("--- stderr")
+					fmt.Print(a.Stderr)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&attemptNum, "attempt", 0, "Show only this attempt number")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream output of the latest (or --attempt) attempt until it finishes")
+	return cmd
+}
+
+func printAttemptHeader(a model.Attempt) {
+	status := "running"
+	if a.Finished() {
+		status = fmt.Sprintf("exit=%d", a.ExitCode)
+		if a.Signal != "" {
+			status += " signal=" + a.Signal
+		}
+		status += " finished=" + a.FinishedAt.Format(time.RFC3339)
+	}
+	fmt.Printf("=== attempt %d | worker=%s | started=%s | %s\n",
+		a.Attempt, a.WorkerID, a.StartedAt.Format(time.RFC3339), status)
+	if a.Error != "" {
+		fmt.Println("error:", a.Error)
+	}
+}
+
+// followAttempt polls an attempt and prints output as it is saved by the
+// worker, returning once the attempt has finished. It gives up when the job
+// does not exist, or finished without the attempt being recorded as done.
+func followAttempt(ctx context.Context, st *store.Store, id string, n int) error {
+	var outSeen, errSeen int
+	var outCut, errCut bool
+	headerShown := false
+
+	for {
+		// read the job first: workers finish the attempt before the job, so
+		// a finished job means the attempt read next is final
+		j, err := st.GetJob(ctx, id)
+		if err != nil {
+			return err
+		}
+		a, err := st.GetAttempt(ctx, id, n)
+		if err != nil {
+			return err
+		}
+		if a != nil {
+			if !headerShown {
+				printAttemptHeader(*a)
+				headerShown = true
+				// pin to this attempt so a retry does not switch streams midway
+				n = a.Attempt
+			}
+			outSeen, outCut = followStream(os.Stdout, a.Stdout, outSeen, outCut)
+			errSeen, errCut = followStream(os.Stderr, a.Stderr, errSeen, errCut)
+			if a.Finished() {
+				status := fmt.Sprintf("exit=%d", a.ExitCode)
+				if a.Signal != "" {
+					status += " signal=" + a.Signal
+				}
+				fmt.Printf("=== attempt %d finished: %s\n", a.Attempt, status)
+				return nil
+			}
+		}
+
+		switch j.State {
+		case "completed", "cancelled", "dead":
+			if a != nil {
+				fmt.Printf("=== job %s is %s, attempt %d was never finished\n", id, j.State, a.Attempt)
+				return nil
+			}
+			if n > 0 {
+				return fmt.Errorf("job %s has no attempt %d", id, n)
+			}
+			fmt.Println("No attempts recorded for", id)
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// followStream prints the part of out past seen and returns the new offset.
+// Output past output_max_bytes is never stored, so once the worker starts
+// dropping it the kept part stops growing; a note says so, once.
+func followStream(w io.Writer, out string, seen int, cut bool) (int, bool) {
+	kept, truncated := model.SplitTruncated(out)
+	if len(kept) > seen {
+		fmt.Fprint(w, kept[seen:])
+		seen = len(kept)
+	}
+	if truncated && !cut {
+		fmt.Fprintln(w, "\n[... output truncated at output_max_bytes, the rest is not kept]")
+		cut = true
+	}
+	return seen, cut
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewMigrateDownCmd(st *store.Store) *cobra.Command {
+	var to int
+
+	cmd := &cobra.Command{
+		Use:         "down --to N",
+		Short:       "Revert migrations down to version N",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{dbUse: dbNoMigrate},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			from, err := st.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if err := st.MigrateDown(ctx, to); err != nil {
+				return err
+			}
+			if from == to {
+				fmt.Printf("Already at version %d\n", to)
+				return nil
+			}
+			fmt.Printf("Reverted from version %d to %d. Commands run by this queuectl migrate it up again; use an older queuectl next.\n", from, to)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&to, "to", 0, "Version to go back to")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
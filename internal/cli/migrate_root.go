@@ -0,0 +1,14 @@
+package cli
+
+import "github.com/spf13/cobra"
+
+func NewMigrateRootCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Inspect and change the database schema version: status, up, down",
+		Long: `Every command migrates the database to the latest schema it knows when it
+opens it, so migrate is mostly useful to check where a database stands or to
+step back before running an older queuectl against it. A database migrated
+past what this queuectl knows is refused by every other command.`,
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewMigrateStatusCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:         "status",
+		Short:       "Show applied and pending migrations",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{dbUse: dbNoMigrate},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ms, err := st.Migrations(context.Background())
+			if err != nil {
+				return err
+			}
+			version, err := st.SchemaVersion(context.Background())
+			if err != nil {
+				return err
+			}
+
+			for _, m := range ms {
+				state := "pending"
+				if m.Applied {
+					state = "applied " + m.AppliedAt.Local().Format(time.RFC3339)
+				}
+				note := ""
+				switch {
+				case m.Version > store.LatestSchemaVersion():
+					note = " | unknown to this queuectl"
+				case !m.Reversible:
+					note = " | irreversible"
+				}
+				fmt.Printf("%3d | %-20s | %s%s\n", m.Version, m.Name, state, note)
+			}
+			fmt.Printf("\nDatabase at version %d, this queuectl migrates to %d\n", version, store.LatestSchemaVersion())
+			return nil
+		},
+	}
+}
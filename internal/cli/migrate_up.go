@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewMigrateUpCmd(st *store.Store) *cobra.Command {
+	var to int
+
+	cmd := &cobra.Command{
+		Use:         "up",
+		Short:       "Apply pending migrations",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{dbUse: dbNoMigrate},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("to") {
+				to = store.LatestSchemaVersion()
+			}
+			ctx := context.Background()
+			from, err := st.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if err := st.MigrateUp(ctx, to); err != nil {
+				return err
+			}
+			if from == to {
+				fmt.Printf("Already at version %d\n", to)
+				return nil
+			}
+			fmt.Printf("Migrated from version %d to %d\n", from, to)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&to, "to", 0, "Stop at this version (default latest)")
+	return cmd
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func NewQueueLimitCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "limit <queue> <max_processing>",
+		Short: "Cap concurrently processing jobs of a queue across all workers (0 = unlimited)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid limit: %s", args[1])
+			}
+			if err := st.SetQueueLimit(context.Background(), name, n); err != nil {
+				return fmt.Errorf("failed to set limit: %w", err)
+			}
+			fmt.Println("Updated:", name, "max_processing =", n)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewQueueListCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List queues with pending/processing counts and limits",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			queues, err := st.ListQueues(context.Background())
+			if err != nil {
+				return err
+			}
+
+			if len(queues) == 0 {
+				fmt.Println("No queues found.")
+				return nil
+			}
+
+			for _, q := range queues {
+				limit := "unlimited"
+				if q.MaxProcessing > 0 {
+					limit = fmt.Sprint(q.MaxProcessing)
+				}
+				fmt.Printf("%-15s | pending=%d | processing=%d | max_processing=%s\n",
+					q.Name, q.Pending, q.Processing, limit)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,10 @@
+package cli
+
+import "github.com/spf13/cobra"
+
+func NewQueueRootCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect named queues and set concurrency limits",
+	}
+}
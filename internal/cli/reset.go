@@ -11,7 +11,7 @@ import (
 func NewResetCmd(st *store.Store) *cobra.Command {
 	return &cobra.Command{
 		Use:   "reset",
-		Short: "Clear all jobs and DLQ entries (development only)",
+		Short: "Clear all jobs, DLQ entries and attempt logs (development only)",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			if err := st.ResetQueue(context.Background()); err != nil {
@@ -20,8 +20,11 @@ func NewResetCmd(st *store.Store) *cobra.Command {
 			if err := st.ResetDLQ(context.Background()); err != nil {
 				return fmt.Errorf("failed to clear DLQ: %w", err)
 			}
+			if err := st.ResetAttempts(context.Background()); err != nil {
+				return fmt.Errorf("failed to clear job attempts: %w", err)
+			}
 
-			fmt.Println("Queue and DLQ cleared.")
+			fmt.Println("Queue, DLQ and attempt logs cleared.")
 			return nil
 		},
 	}
@@ -1,13 +1,93 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"queuectl/internal/engine"
+	"queuectl/internal/store"
+	"queuectl/internal/userconfig"
+
 	"github.com/spf13/cobra"
 )
 
-func NewRootCmd() *cobra.Command {
+// dbUse is the annotation telling the root command how much of the
+// database a subcommand needs: dbNoMigrate to open it without migrating,
+// dbPathOnly to resolve its path without opening it, dbNone to skip even
+// that. Unset means open and migrate it.
+const (
+	dbUse       = "queuectl/db"
+	dbNoMigrate = "open"
+	dbPathOnly  = "path"
+	dbNone      = "none"
+)
+
+// NewRootCmd returns the root command. Before any subcommand runs it picks
+// the database (see userconfig.Resolve) and opens it into st, which the
+// subcommands were built with.
+func NewRootCmd(st *store.Store) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "queuectl",
 		Short: "Job queue system",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Annotations[dbUse] == dbNone {
+				return nil
+			}
+			path, source, err := resolveDB(cmd)
+			if err != nil {
+				return err
+			}
+			engine.SetStopFile(engine.StopFileFor(path))
+			if cmd.Annotations[dbUse] == dbPathOnly {
+				return nil
+			}
+
+			if source == "default" {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					return err
+				}
+			}
+			// a worker pulling from `queuectl serve` never opens the local
+			// database; it only needs the stop file next to where it would be
+			if usesServer(cmd) {
+				return nil
+			}
+			if source == "default" {
+				if _, err := os.Stat("queue.db"); err == nil {
+					fmt.Fprintf(os.Stderr, "warning: using %s; ./queue.db is no longer opened by default, pass --db queue.db to use it\n", path)
+				}
+			}
+			open := store.NewStore
+			if cmd.Annotations[dbUse] == dbNoMigrate {
+				open = store.OpenStore
+			}
+			opened, err := open(path)
+			if err != nil {
+				return err
+			}
+			*st = *opened
+			return nil
+		},
 	}
+	cmd.PersistentFlags().String("db", "", "Queue database file (default $"+userconfig.EnvDB+", the current context, or the XDG data dir)")
+	cmd.PersistentFlags().String("context", "", "Use the database of this named context")
 	return cmd
 }
+
+// usesServer reports whether cmd was pointed at a remote server with
+// --server.
+func usesServer(cmd *cobra.Command) bool {
+	f := cmd.Flags().Lookup("server")
+	return f != nil && f.Value.String() != ""
+}
+
+// resolveDB returns the database path for cmd from its --db and --context
+// flags and the user config.
+func resolveDB(cmd *cobra.Command) (string, string, error) {
+	db, _ := cmd.Flags().GetString("db")
+	name, _ := cmd.Flags().GetString("context")
+	if db != "" && name != "" {
+		return "", "", fmt.Errorf("use either --db or --context, not both")
+	}
+	return userconfig.Resolve(db, name)
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleAddCmd(st *store.Store) *cobra.Command {
+	var catchup string
+
+	cmd := &cobra.Command{
+		Use:   "add <name> '<cron>' '{\"command\":\"./nightly.sh\"}'",
+		Short: "Add a recurring job (5-field cron, evaluated in UTC)",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sc := model.Schedule{
+				Name:     args[0],
+				Cron:     args[1],
+				Template: args[2],
+				Catchup:  catchup,
+			}
+			if err := st.AddSchedule(context.Background(), sc, time.Now().UTC()); err != nil {
+				return err
+			}
+			fmt.Println("Schedule added:", sc.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&catchup, "catchup", "one", "What to do with ticks missed while no scheduler ran (one,all,skip)")
+	return cmd
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleListCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List recurring jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schedules, err := st.ListSchedules(context.Background())
+			if err != nil {
+				return err
+			}
+
+			if len(schedules) == 0 {
+				fmt.Println("No schedules found.")
+				return nil
+			}
+
+			for _, sc := range schedules {
+				status := "active"
+				if sc.Paused {
+					status = "paused"
+				}
+				last := "never"
+				if !sc.LastRunAt.IsZero() {
+					last = sc.LastRunAt.Format(time.RFC3339)
+				}
+				fmt.Printf("%s | %-6s | cron=%q | catchup=%s | next=%s | last=%s | %s\n",
+					sc.Name, status, sc.Cron, sc.Catchup,
+					sc.NextRunAt.Format(time.RFC3339), last, sc.Template)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewSchedulePauseCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "pause <name>",
+		Short: "Stop a recurring job from firing until resumed",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := st.PauseSchedule(context.Background(), args[0]); err != nil {
+				return fmt.Errorf("pause failed: %w", err)
+			}
+			fmt.Println("Schedule paused:", args[0])
+			return nil
+		},
+	}
+}
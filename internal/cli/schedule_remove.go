@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleRemoveCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Delete a recurring job (already enqueued runs are kept)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := st.RemoveSchedule(context.Background(), args[0]); err != nil {
+				return fmt.Errorf("remove failed: %w", err)
+			}
+			fmt.Println("Schedule removed:", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleResumeCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume <name>",
+		Short: "Resume a paused recurring job from its next tick",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := st.ResumeSchedule(context.Background(), args[0], time.Now().UTC()); err != nil {
+				return fmt.Errorf("resume failed: %w", err)
+			}
+			fmt.Println("Schedule resumed:", args[0])
+			return nil
+		},
+	}
+}
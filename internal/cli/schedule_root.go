@@ -0,0 +1,10 @@
+package cli
+
+import "github.com/spf13/cobra"
+
+func NewScheduleRootCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage recurring cron-style jobs",
+	}
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"queuectl/internal/engine"
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleRunCmd(st *store.Store) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Run a standalone scheduler that enqueues due recurring jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine.RemoveStopFile()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt)
+			go func() {
+				<-sigCh
+				fmt.Println("Stopping scheduler...")
+				cancel()
+			}()
+
+			fmt.Println("Scheduler started. Use `queuectl worker stop` or Ctrl+C to stop.")
+			engine.NewScheduler(st).Run(ctx)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"queuectl/internal/engine"
+	"queuectl/internal/server"
+	"queuectl/internal/store"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewServeCmd(st *store.Store) *cobra.Command {
+	var addr string
+	var noAuth bool
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the queue over an HTTP/JSON API",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := st.GetConfig(context.Background(), server.TokenKey)
+			if err != nil {
+				return err
+			}
+			if token == "" && !noAuth {
+				return fmt.Errorf("set a token first with `queuectl config set %s <token>`, or pass --no-auth", server.TokenKey)
+			}
+
+			srv := server.New(st)
+			srv.AllowNoAuth = noAuth
+			httpSrv := &http.Server{Addr: addr, Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+
+			// remote workers cannot reach the database, so retention is
+			// enforced here
+			janitorCtx, stopJanitor := context.WithCancel(context.Background())
+			defer stopJanitor()
+			go engine.NewJanitor(st).Run(janitorCtx)
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- httpSrv.ListenAndServe() }()
+			fmt.Println("Serving API on", addr)
+			if token == "" {
+				fmt.Println("Warning: no api_token set, requests are not authenticated")
+			}
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt)
+
+			select {
+			case err := <-errCh:
+				return err
+			case <-sigCh:
+				fmt.Println("Shutting down API server...")
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := httpSrv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return err
+				}
+				return nil
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "Address to listen on")
+	cmd.Flags().BoolVar(&noAuth, "no-auth", false, "Serve without a bearer token while api_token is unset")
+	return cmd
+}
@@ -4,16 +4,22 @@ import (
 	"context"
 	"fmt"
 	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
-func NewStatusCmd(st *store.Store) *cobra.Command {
-	return &cobra.Command{
+// NewStatusCmd counts jobs through c. The --history rollup is kept only in
+// the SQLite database, so it reads st.
+func NewStatusCmd(c *client.Client, st *store.Store) *cobra.Command {
+	var historyDays int
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show queue status summary",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			stats, err := st.QueueStatus(context.Background())
+			stats, err := c.Status(context.Background())
 			if err != nil {
 				return err
 			}
@@ -21,7 +27,26 @@ func NewStatusCmd(st *store.Store) *cobra.Command {
 			for state, count := range stats {
 				fmt.Printf("  %-10s %d\n", state, count)
 			}
+
+			if historyDays <= 0 {
+				return nil
+			}
+			since := time.Now().UTC().AddDate(0, 0, -(historyDays - 1))
+			days, err := st.DailyStats(context.Background(), since)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nFinished jobs, last %d day(s):\n", historyDays)
+			if len(days) == 0 {
+				fmt.Println("  none")
+			}
+			for _, d := range days {
+				fmt.Printf("  %s  %-12s %-10s %d\n", d.Day, d.Queue, d.State, d.Count)
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVar(&historyDays, "history", 0, "Also show completed and cancelled jobs per day for this many days, including ones removed by gc")
+	return cmd
 }
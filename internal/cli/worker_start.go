@@ -6,46 +6,96 @@ import (
 	"os"
 	"os/signal"
 
-	"queuectl/internal/engine"
-	"queuectl/internal/store"
+	"queuectl/pkg/client"
 	"strconv"
 
 	"github.com/spf13/cobra"
 )
 
-func NewWorkerCmd(st *store.Store) *cobra.Command {
+func NewWorkerCmd(c *client.Client) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start worker processes",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			engine.RemoveStopFile()
+			queuesStr, _ := cmd.Flags().GetString("queues")
+			opts := []client.WorkerOption{client.WithQueues(queuesStr)}
 
-			countStr, _ := cmd.Flags().GetString("count")
-			count, err := strconv.Atoi(countStr)
-			if err != nil || count < 1 {
-				return fmt.Errorf("invalid worker count: %s", countStr)
+			// without --count the number of workers follows worker_concurrency
+			count := 0
+			if cmd.Flags().Changed("count") {
+				countStr, _ := cmd.Flags().GetString("count")
+				n, err := strconv.Atoi(countStr)
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid worker count: %s", countStr)
+				}
+				count = n
+				opts = append(opts, client.WithConcurrency(count))
 			}
 
-			ctx, cancel := context.WithCancel(context.Background())
+			if addr, _ := cmd.Flags().GetString("metrics-addr"); addr != "" {
+				opts = append(opts, client.WithMetricsAddr(addr))
+			}
+
+			withScheduler, _ := cmd.Flags().GetBool("scheduler")
+			if withScheduler {
+				opts = append(opts, client.WithScheduler())
+			}
 
-			// Start workers
-			for i := 0; i < count; i++ {
-				go engine.NewWorker(st).Run(ctx)
+			var w *client.Worker
+			var err error
+			if serverURL, _ := cmd.Flags().GetString("server"); serverURL != "" {
+				token, _ := cmd.Flags().GetString("token")
+				if token == "" {
+					token = os.Getenv("QUEUECTL_TOKEN")
+				}
+				w, err = client.NewRemoteWorker(serverURL, token, opts...)
+				if err == nil {
+					fmt.Println("Pulling jobs from", serverURL)
+				}
+			} else {
+				w, err = c.NewWorker(opts...)
+			}
+			if err != nil {
+				return err
 			}
 
-			fmt.Printf("Started %d workers. Use `queuectl worker stop` to stop.\n", count)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- w.Run(ctx) }()
+
+			if withScheduler {
+				fmt.Println("Started scheduler for recurring jobs.")
+			}
+			if count > 0 {
+				fmt.Printf("Started %d workers. Use `queuectl worker stop` to stop.\n", count)
+			} else {
+				fmt.Println("Started workers as set by worker_concurrency. Use `queuectl worker stop` to stop.")
+			}
+			if queuesStr != "" {
+				fmt.Println("Claiming from queues:", queuesStr)
+			}
 
 			// Handle OS signals for graceful shutdown
 			sigCh := make(chan os.Signal, 1)
 			signal.Notify(sigCh, os.Interrupt)
 
-			<-sigCh // wait for stop signal
-			fmt.Println("Stopping workers gracefully...")
-			cancel()
-			return nil
+			select {
+			case <-sigCh: // wait for stop signal
+				fmt.Println("Stopping workers gracefully...")
+				cancel()
+				return <-done
+			case err := <-done: // `queuectl worker stop`
+				cancel()
+				return err
+			}
 		},
 	}
 
-	cmd.Flags().String("count", "1", "number of workers to start")
+	cmd.Flags().String("count", "", "number of workers to start (default worker_concurrency config, followed as it changes)")
+	cmd.Flags().String("queues", "", "queues to claim from, with optional weights (e.g. emails:3,reports); default all")
+	cmd.Flags().Bool("scheduler", false, "also run the recurring job scheduler in this process")
+	cmd.Flags().String("server", "", "pull jobs from a `queuectl serve` API at this URL instead of the local database")
+	cmd.Flags().String("metrics-addr", "", "serve Prometheus metrics for these workers at this address, e.g. 127.0.0.1:9090")
+	cmd.Flags().String("token", "", "bearer token for --server (default $QUEUECTL_TOKEN)")
 	return cmd
 }
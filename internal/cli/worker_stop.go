@@ -9,8 +9,9 @@ import (
 
 func NewWorkerStopCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "stop",
-		Short: "Gracefully stop running workers",
+		Use:         "stop",
+		Short:       "Gracefully stop running workers",
+		Annotations: map[string]string{dbUse: dbPathOnly},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			//creates stop file
 			if err := engine.CreateStopFile(); err != nil {
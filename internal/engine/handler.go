@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"sort"
+	"sync"
+)
+
+// Handler runs jobs of one type inside the worker process. A returned error
+// fails the attempt like a non-zero exit; wrap ErrPermanent to skip the
+// remaining retries. Handlers should return once ctx is done, which happens
+// when the job times out or the worker loses its lease.
+type Handler interface {
+	Handle(ctx context.Context, payload json.RawMessage) error
+}
+
+// HandlerFunc adapts a plain function to Handler.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+func (f HandlerFunc) Handle(ctx context.Context, payload json.RawMessage) error {
+	return f(ctx, payload)
+}
+
+// ErrPermanent sends a failed job straight to the DLQ.
+var ErrPermanent = store.ErrPermanent
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+// Register makes fn the handler for jobs enqueued with the given type.
+// It panics if the type is empty or already registered.
+func Register(jobType string, fn HandlerFunc) {
+	RegisterHandler(jobType, fn)
+}
+
+// RegisterHandler is Register for values implementing Handler.
+func RegisterHandler(jobType string, h Handler) {
+	if jobType == "" {
+		panic("engine: Register with empty job type")
+	}
+	if h == nil {
+		panic("engine: Register with nil handler for " + jobType)
+	}
+
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if _, dup := handlers[jobType]; dup {
+		panic("engine: Register called twice for " + jobType)
+	}
+	handlers[jobType] = h
+}
+
+// Unregister removes the handler for jobType, if any.
+func Unregister(jobType string) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	delete(handlers, jobType)
+}
+
+// LookupHandler returns the handler registered for jobType.
+func LookupHandler(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	h, ok := handlers[jobType]
+	return h, ok
+}
+
+// HandlerTypes lists the registered job types in sorted order.
+func HandlerTypes() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for t := range handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// runHandler dispatches job to its registered handler. A type with no
+// handler fails permanently since retrying on this worker cannot help.
+func runHandler(ctx context.Context, job *model.Job) (err error) {
+	h, ok := LookupHandler(job.Type)
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %q: %w", job.Type, ErrPermanent)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler %s panicked: %v", job.Type, r)
+		}
+	}()
+	return h.Handle(ctx, job.Payload)
+}
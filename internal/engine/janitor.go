@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+)
+
+// Janitor periodically applies the retention policies in config and keeps
+// the database file compact. Workers
+// with the database and `queuectl serve` each run one; running several
+// against the same database is harmless.
+type Janitor struct {
+	Store    *store.Store
+	Interval time.Duration
+}
+
+func NewJanitor(st *store.Store) *Janitor {
+	return &Janitor{Store: st, Interval: 1 * time.Minute}
+}
+
+func (j *Janitor) Run(ctx context.Context) {
+	for {
+		if err := j.Sweep(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("Janitor error:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.Interval):
+		}
+	}
+}
+
+// Sweep applies every retention policy once, then compacts the database.
+// Config is read on each sweep, so changes take effect without a restart.
+func (j *Janitor) Sweep(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	maxAge := time.Duration(j.Store.MustGetInt("dlq_max_age_days", 0)) * 24 * time.Hour
+	maxCount := j.Store.MustGetInt("dlq_max_count", 0)
+	if maxAge > 0 || maxCount > 0 {
+		n, err := j.Store.TrimDLQ(ctx, now, maxAge, maxCount)
+		if err != nil {
+			return fmt.Errorf("dlq retention: %w", err)
+		}
+		if n > 0 {
+			fmt.Printf("Removed %d job(s) from the DLQ by retention policy\n", n)
+		}
+	}
+
+	retention, err := j.Store.GetConfig(ctx, "completed_retention")
+	if err != nil {
+		return err
+	}
+	keep, err := store.ParseAge(retention)
+	if err != nil {
+		return fmt.Errorf("completed_retention: %w", err)
+	}
+	if keep > 0 {
+		n, err := j.Store.DeleteFinishedJobs(ctx, now.Add(-keep))
+		if err != nil {
+			return fmt.Errorf("completed retention: %w", err)
+		}
+		if n > 0 {
+			fmt.Printf("Removed %d finished job(s) older than %s\n", n, retention)
+		}
+	}
+
+	return j.Store.Compact(ctx)
+}
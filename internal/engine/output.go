@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"bytes"
+	"queuectl/internal/model"
+	"sync"
+)
+
+// cappedBuffer keeps the first max bytes written to it and counts the rest,
+// so a chatty job cannot blow up the attempts table.
+type cappedBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	max     int
+	dropped int
+}
+
+func newCappedBuffer(max int) *cappedBuffer {
+	return &cappedBuffer{max: max}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	room := b.max - b.buf.Len()
+	if room < 0 {
+		room = 0
+	}
+	if len(p) > room {
+		b.buf.Write(p[:room])
+		b.dropped += len(p) - room
+	} else {
+		b.buf.Write(p)
+	}
+	// always report a full write, dropping output must not fail the job
+	return len(p), nil
+}
+
+// String returns the captured output, with a marker if anything was dropped.
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dropped == 0 {
+		return b.buf.String()
+	}
+	return model.TruncatedOutput(b.buf.String(), b.dropped)
+}
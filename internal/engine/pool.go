@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Settings is the config a worker runs with. A Pool reads it again whenever
+// the config version moves.
+type Settings struct {
+	Base, Cap    int
+	Lease        time.Duration
+	OutputMax    int
+	KillGrace    time.Duration
+	PollInterval time.Duration
+	// Concurrency is how many workers a Pool runs.
+	Concurrency int
+}
+
+// LoadSettings reads the worker settings from config.
+func LoadSettings(t Transport) Settings {
+	lease := t.MustGetInt("lease_seconds", 30)
+	if lease < 1 {
+		lease = 30
+	}
+	concurrency := t.MustGetInt("worker_concurrency", 1)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return Settings{
+		Base:         t.MustGetInt("backoff_base", 2),
+		Cap:          t.MustGetInt("backoff_cap_seconds", 60),
+		Lease:        time.Duration(lease) * time.Second,
+		OutputMax:    t.MustGetInt("output_max_bytes", 64*1024),
+		KillGrace:    time.Duration(t.MustGetInt("timeout_grace_seconds", 10)) * time.Second,
+		PollInterval: time.Duration(t.MustGetInt("poll_interval_ms", 200)) * time.Millisecond,
+		Concurrency:  concurrency,
+	}
+}
+
+// Changes lists the settings that differ from old, by config key, as
+// "key old -> new".
+func (s Settings) Changes(old Settings) []string {
+	var changes []string
+	add := func(key string, from, to int64) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s %d -> %d", key, from, to))
+		}
+	}
+	add("backoff_base", int64(old.Base), int64(s.Base))
+	add("backoff_cap_seconds", int64(old.Cap), int64(s.Cap))
+	add("lease_seconds", int64(old.Lease/time.Second), int64(s.Lease/time.Second))
+	add("output_max_bytes", int64(old.OutputMax), int64(s.OutputMax))
+	add("timeout_grace_seconds", int64(old.KillGrace/time.Second), int64(s.KillGrace/time.Second))
+	add("poll_interval_ms", old.PollInterval.Milliseconds(), s.PollInterval.Milliseconds())
+	add("worker_concurrency", int64(old.Concurrency), int64(s.Concurrency))
+	return changes
+}
+
+// Pool runs workers over one transport and keeps them in step with config.
+// It checks the config version every ReloadInterval; when it moves, the
+// workers pick up the new settings before their next claim and the pool
+// starts or retires workers to match worker_concurrency. Config changes
+// never interrupt jobs in flight, and a retired worker counts toward
+// worker_concurrency until its job is done.
+type Pool struct {
+	Transport Transport
+	Queues    []QueueWeight
+	Metrics   *metrics.Metrics
+	// Override, when set, adjusts every loaded Settings, e.g. to pin values
+	// given as options so config changes leave them alone.
+	Override       func(*Settings)
+	ReloadInterval time.Duration
+
+	settings    Settings
+	version     int64
+	checkFailed bool
+	workers     []*Worker
+	// retiring counts retired workers still finishing a job.
+	retiring atomic.Int32
+	wg       sync.WaitGroup
+}
+
+func NewPool(t Transport) *Pool {
+	return &Pool{Transport: t, ReloadInterval: time.Second}
+}
+
+// Run starts the workers and returns when ctx is cancelled or the stop file
+// appears, once every worker has returned. After the stop file jobs in
+// flight finish first; cancelling ctx stops them.
+func (p *Pool) Run(ctx context.Context) {
+	p.version, _ = p.Transport.ConfigVersion(ctx)
+	p.settings = p.load()
+	p.resize(ctx)
+	defer p.wg.Wait()
+
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ShouldStop() {
+			return
+		}
+		p.reload(ctx)
+		p.resize(ctx)
+	}
+}
+
+func (p *Pool) load() Settings {
+	s := LoadSettings(p.Transport)
+	if p.Override != nil {
+		p.Override(&s)
+	}
+	if s.Concurrency < 1 {
+		s.Concurrency = 1
+	}
+	return s
+}
+
+// reload reads config again if its version moved and applies whatever
+// changed.
+func (p *Pool) reload(ctx context.Context) {
+	v, err := p.Transport.ConfigVersion(ctx)
+	if err != nil {
+		if !p.checkFailed && ctx.Err() == nil {
+			fmt.Println("Config check error:", err)
+		}
+		p.checkFailed = true
+		return
+	}
+	p.checkFailed = false
+	if v == p.version {
+		return
+	}
+	p.version = v
+
+	s := p.load()
+	changes := s.Changes(p.settings)
+	p.settings = s
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Println("Config changed:", strings.Join(changes, ", "))
+	for _, w := range p.workers {
+		w.Reload(s)
+	}
+}
+
+// resize starts or retires workers until there are settings.Concurrency.
+// Retired workers finish their current job first, and no worker replaces
+// one until it has.
+func (p *Pool) resize(ctx context.Context) {
+	for len(p.workers)+int(p.retiring.Load()) < p.settings.Concurrency {
+		w := newWorker(p.Transport, p.settings)
+		w.Queues = p.Queues
+		w.Metrics = p.Metrics
+		p.workers = append(p.workers, w)
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			w.Run(ctx)
+			if w.retired.Load() {
+				p.retiring.Add(-1)
+			}
+		}()
+	}
+	for len(p.workers) > p.settings.Concurrency {
+		last := len(p.workers) - 1
+		p.retiring.Add(1)
+		p.workers[last].Retire()
+		p.workers = p.workers[:last]
+	}
+}
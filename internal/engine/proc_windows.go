@@ -0,0 +1,26 @@
+//go:build windows
+
+package engine
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, windows has no process groups to signal.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateGroup kills the process, windows has no graceful equivalent of SIGTERM.
+func terminateGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killGroup kills the process.
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// exitSignal always returns "" since windows processes are not killed by signals.
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// runProcess runs cmd until it exits or ctx is done. On cancellation the
+// process group gets SIGTERM, and SIGKILL if it is still alive after grace.
+func runProcess(ctx context.Context, cmd *exec.Cmd, grace time.Duration) error {
+	setProcessGroup(cmd)
+	// don't let a child that escaped the group hold our pipes open forever
+	cmd.WaitDelay = grace
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	_ = terminateGroup(cmd)
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(grace):
+	}
+
+	_ = killGroup(cmd)
+	return <-done
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"queuectl/internal/store"
+	"strconv"
+	"strings"
+)
+
+// QueueWeight is a queue a worker claims from, and how often it is tried
+// first relative to the worker's other queues.
+type QueueWeight struct {
+	Name   string
+	Weight int
+}
+
+// ParseQueues parses a subscription like "emails:3,reports" where the
+// weight defaults to 1.
+func ParseQueues(spec string) ([]QueueWeight, error) {
+	var result []QueueWeight
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, weightStr, hasWeight := strings.Cut(part, ":")
+		weight := 1
+		if hasWeight {
+			n, err := strconv.Atoi(weightStr)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid weight for queue %q: %s", name, weightStr)
+			}
+			weight = n
+		}
+		if err := store.ValidateQueueName(name); err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("queue %q listed twice", name)
+		}
+		seen[name] = true
+
+		result = append(result, QueueWeight{Name: name, Weight: weight})
+	}
+	return result, nil
+}
+
+// claimOrder returns the queues to try for the next claim. Each queue's
+// chance of going first is proportional to its weight, so a busy queue
+// cannot starve the others. No subscription means any queue ("").
+func (w *Worker) claimOrder() []string {
+	if len(w.Queues) == 0 {
+		return []string{""}
+	}
+
+	remaining := append([]QueueWeight(nil), w.Queues...)
+	order := make([]string, 0, len(remaining))
+	for len(remaining) > 0 {
+		total := 0
+		for _, q := range remaining {
+			total += q.Weight
+		}
+		pick := rand.Intn(total)
+		for i, q := range remaining {
+			if pick < q.Weight {
+				order = append(order, q.Name)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= q.Weight
+		}
+	}
+	return order
+}
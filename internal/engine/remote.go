@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"queuectl/internal/model"
+	"queuectl/internal/protocol"
+	"queuectl/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	_ Transport = (*store.Store)(nil)
+	_ Transport = (*RemoteTransport)(nil)
+)
+
+// RemoteTransport is a Transport backed by the /worker/ endpoints of
+// `queuectl serve`, so workers can run on hosts without the database. The
+// server stamps every time itself; the now arguments are ignored.
+type RemoteTransport struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+func NewRemoteTransport(baseURL, token string) *RemoteTransport {
+	return &RemoteTransport{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// call sends in as json and decodes the reply into out, unless the server
+// answered 204. Errors carrying protocol.CodeLeaseLost become
+// store.ErrLeaseLost.
+func (r *RemoteTransport) call(ctx context.Context, method, path string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.BaseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e protocol.ErrorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		if e.Code == protocol.CodeLeaseLost {
+			return resp.StatusCode, store.ErrLeaseLost
+		}
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, e.Error)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s %s: decode reply: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func jobPath(id, suffix string) string {
+	return "/worker/jobs/" + url.PathEscape(id) + suffix
+}
+
+func (r *RemoteTransport) Claim(ctx context.Context, now time.Time, workerID string, lease time.Duration, queue string) (*model.Job, error) {
+	var j model.Job
+	status, err := r.call(ctx, http.MethodPost, "/worker/claim", protocol.ClaimRequest{
+		WorkerID: workerID,
+		LeaseMS:  lease.Milliseconds(),
+		Queue:    queue,
+	}, &j)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *RemoteTransport) ExtendLease(ctx context.Context, j *model.Job, until time.Time) error {
+	var updated model.Job
+	_, err := r.call(ctx, http.MethodPost, jobPath(j.ID, "/heartbeat"), protocol.HeartbeatRequest{
+		WorkerID: j.WorkerID,
+		LeaseMS:  time.Until(until).Milliseconds(),
+	}, &updated)
+	if err != nil {
+		return err
+	}
+	j.LeaseExpiresAt = updated.LeaseExpiresAt
+	if updated.CancelRequested {
+		j.CancelRequested = true
+		return store.ErrCancelRequested
+	}
+	return nil
+}
+
+func (r *RemoteTransport) Complete(ctx context.Context, j *model.Job, now time.Time) error {
+	_, err := r.call(ctx, http.MethodPost, jobPath(j.ID, "/complete"), protocol.OwnerRequest{WorkerID: j.WorkerID}, nil)
+	return err
+}
+
+func (r *RemoteTransport) FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error) {
+	var resp protocol.FailResponse
+	_, err := r.call(ctx, http.MethodPost, jobPath(j.ID, "/fail"), protocol.FailRequest{
+		WorkerID:   j.WorkerID,
+		Error:      execErr.Error(),
+		Permanent:  errors.Is(execErr, store.ErrPermanent),
+		Base:       base,
+		CapSeconds: capSeconds,
+	}, &resp)
+	return resp.MovedToDLQ, err
+}
+
+func (r *RemoteTransport) MarkCancelled(ctx context.Context, j *model.Job, now time.Time) error {
+	_, err := r.call(ctx, http.MethodPost, jobPath(j.ID, "/cancelled"), protocol.OwnerRequest{WorkerID: j.WorkerID}, nil)
+	return err
+}
+
+func (r *RemoteTransport) ReapExpired(ctx context.Context, now time.Time, base, capSeconds int) (int, error) {
+	var resp protocol.ReapResponse
+	_, err := r.call(ctx, http.MethodPost, "/worker/reap", protocol.ReapRequest{Base: base, CapSeconds: capSeconds}, &resp)
+	return resp.Reaped, err
+}
+
+func (r *RemoteTransport) StartAttempt(ctx context.Context, j *model.Job, now time.Time) (*model.Attempt, error) {
+	var a model.Attempt
+	if _, err := r.call(ctx, http.MethodPost, jobPath(j.ID, "/attempts"), protocol.OwnerRequest{WorkerID: j.WorkerID}, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *RemoteTransport) UpdateAttemptOutput(ctx context.Context, a *model.Attempt) error {
+	_, err := r.call(ctx, http.MethodPut, jobPath(a.JobID, "/attempts/"+strconv.Itoa(a.Attempt)+"/output"), a, nil)
+	return err
+}
+
+func (r *RemoteTransport) FinishAttempt(ctx context.Context, a *model.Attempt) error {
+	_, err := r.call(ctx, http.MethodPost, jobPath(a.JobID, "/attempts/"+strconv.Itoa(a.Attempt)+"/finish"), a, nil)
+	return err
+}
+
+func (r *RemoteTransport) ConfigVersion(ctx context.Context) (int64, error) {
+	var resp protocol.ConfigVersionResponse
+	_, err := r.call(ctx, http.MethodGet, "/worker/config-version", nil, &resp)
+	return resp.Version, err
+}
+
+// MustGetInt reads a config value from the server, falling back to
+// defaultVal when it is unset or unreachable.
+func (r *RemoteTransport) MustGetInt(key string, defaultVal int) int {
+	var cv struct {
+		Value string `json:"value"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := r.call(ctx, http.MethodGet, "/config/"+url.PathEscape(key), nil, &cv); err != nil {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(cv.Value)
+	if err != nil {
+		return defaultVal
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/store"
+	"time"
+)
+
+// Scheduler enqueues jobs for due cron schedules. Any number of schedulers
+// may run against the same database; each tick is still fired once.
+type Scheduler struct {
+	Store    *store.Store
+	Interval time.Duration
+}
+
+func NewScheduler(st *store.Store) *Scheduler {
+	return &Scheduler{Store: st, Interval: 1 * time.Second}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		//checks for stop file
+		if ShouldStop() {
+			fmt.Println("Scheduler stopping!")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("Scheduler shutting down!")
+			return
+		default:
+		}
+
+		ids, err := s.Store.FireDueSchedules(ctx, time.Now().UTC())
+		if err != nil {
+			fmt.Println("Schedule error:", err)
+		}
+		for _, id := range ids {
+			fmt.Printf("Scheduled job enqueued: %s\n", id)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.Interval):
+		}
+	}
+}
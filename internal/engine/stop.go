@@ -4,7 +4,21 @@ import (
 	"os"
 )
 
-const stopFile = ".queuectl-stop"
+// stopFile is checked by running workers and schedulers. The CLI points it
+// next to the database with SetStopFile so `worker stop` reaches the workers
+// of that database whatever directory either was started from.
+var stopFile = ".queuectl-stop"
+
+// StopFileFor returns the stop file used for the database at dbPath.
+func StopFileFor(dbPath string) string {
+	return dbPath + ".stop"
+}
+
+// SetStopFile changes the stop file. It must be called before any worker
+// starts.
+func SetStopFile(path string) {
+	stopFile = path
+}
 
 func ShouldStop() bool {
 	_, err := os.Stat(stopFile)
@@ -2,26 +2,111 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"os"
 	"os/exec"
+	"queuectl/internal/metrics"
+	"queuectl/internal/model"
 	"queuectl/internal/store"
+	"sort"
+	"sync/atomic"
 	"time"
 )
 
+var workerSeq atomic.Int64
+
+// Transport is what a Worker needs from the queue. *store.Store satisfies
+// it for workers next to the database; RemoteTransport talks to
+// `queuectl serve` for workers on other hosts.
+type Transport interface {
+	Claim(ctx context.Context, now time.Time, workerID string, lease time.Duration, queue string) (*model.Job, error)
+	ExtendLease(ctx context.Context, j *model.Job, until time.Time) error
+	Complete(ctx context.Context, j *model.Job, now time.Time) error
+	FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error)
+	MarkCancelled(ctx context.Context, j *model.Job, now time.Time) error
+	ReapExpired(ctx context.Context, now time.Time, base, capSeconds int) (int, error)
+
+	StartAttempt(ctx context.Context, j *model.Job, now time.Time) (*model.Attempt, error)
+	UpdateAttemptOutput(ctx context.Context, a *model.Attempt) error
+	FinishAttempt(ctx context.Context, a *model.Attempt) error
+
+	MustGetInt(key string, defaultVal int) int
+	ConfigVersion(ctx context.Context) (int64, error)
+}
+
 type Worker struct {
-	Store *store.Store
-	Base  int
-	Cap   int
+	Transport Transport
+	Base      int
+	Cap       int
+
+	// ID identifies this worker as the owner of the jobs it claims.
+	ID string
+	// Lease is how long a claim stays valid without a heartbeat.
+	Lease time.Duration
+	// Queues restricts which queues the worker claims from; empty means all.
+	Queues []QueueWeight
+	// OutputMax caps the stdout and stderr kept per attempt, in bytes.
+	OutputMax int
+	// KillGrace is how long a timed out or cancelled job gets between
+	// SIGTERM and SIGKILL.
+	KillGrace time.Duration
+	// PollInterval is how long an idle worker waits before trying to claim
+	// again, plus up to as much jitter.
+	PollInterval time.Duration
+	// Metrics, when set, records claims, outcomes and timings.
+	Metrics *metrics.Metrics
+
+	lastReap time.Time
+	pending  atomic.Pointer[Settings]
+	retired  atomic.Bool
+}
+
+func NewWorker(st Transport) *Worker {
+	return newWorker(st, LoadSettings(st))
+}
+
+func newWorker(st Transport, s Settings) *Worker {
+	w := &Worker{Transport: st, ID: newWorkerID()}
+	w.apply(s)
+	return w
+}
+
+// apply switches the worker to s. Only the worker's own goroutine may call
+// it once Run has started.
+func (w *Worker) apply(s Settings) {
+	w.Base, w.Cap = s.Base, s.Cap
+	w.Lease = s.Lease
+	w.OutputMax = s.OutputMax
+	w.KillGrace = s.KillGrace
+	w.PollInterval = s.PollInterval
+}
+
+// Reload hands the worker new settings. A running worker switches to them
+// before its next claim; a job in flight finishes under the old ones.
+func (w *Worker) Reload(s Settings) {
+	w.pending.Store(&s)
 }
 
-func NewWorker(st *store.Store) *Worker {
-	base := st.MustGetInt("backoff_base", 2)
-	cap := st.MustGetInt("backoff_cap_seconds", 60)
-	return &Worker{Store: st, Base: base, Cap: cap}
+// Retire makes the worker return from Run once its current job, if any, is
+// done.
+func (w *Worker) Retire() {
+	w.retired.Store(true)
+}
+
+// newWorkerID builds an id that is unique across processes sharing a database.
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), workerSeq.Add(1))
 }
 
 func (w *Worker) Run(ctx context.Context) {
+	w.Metrics.SetWorkerBusy(w.ID, false)
+	defer w.Metrics.RemoveWorker(w.ID)
 	for {
 
 		//checks for stop file
@@ -37,34 +122,263 @@ func (w *Worker) Run(ctx context.Context) {
 		default:
 		}
 
+		if w.retired.Load() {
+			fmt.Println("Worker retired!")
+			return
+		}
+		if s := w.pending.Swap(nil); s != nil {
+			w.apply(*s)
+		}
+
+		//return jobs orphaned by dead workers
+		w.reap(ctx)
+
 		//claim job from queue
 		now := time.Now().UTC()
-		job, err := w.Store.ClaimOne(ctx, now)
+		job, err := w.claim(ctx, now)
 		if err != nil {
 			fmt.Println("Claim error:", err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
 		if job == nil {
-			time.Sleep(time.Duration(200+rand.Intn(200)) * time.Millisecond)
+			time.Sleep(w.pollDelay())
 			continue
 		}
 
-		fmt.Printf("Running job %s: %s\n", job.ID, job.Command)
+		w.Metrics.JobClaimed(job.Queue, now.Sub(job.AvailableAt))
+		w.Metrics.SetWorkerBusy(w.ID, true)
+		w.runJob(ctx, job)
+		w.Metrics.SetWorkerBusy(w.ID, false)
+	}
+}
+
+// claim tries the worker's queues in weighted order and returns the first
+// job found.
+func (w *Worker) claim(ctx context.Context, now time.Time) (*model.Job, error) {
+	for _, queue := range w.claimOrder() {
+		job, err := w.Transport.Claim(ctx, now, w.ID, w.Lease, queue)
+		if err != nil || job != nil {
+			return job, err
+		}
+	}
+	return nil, nil
+}
+
+// runJob executes a claimed job while heartbeating its lease, records the
+// attempt and then reports the outcome.
+func (w *Worker) runJob(ctx context.Context, job *model.Job) {
+	fmt.Printf("Running job %s: %s\n", job.ID, job.Display())
+
+	attempt, err := w.Transport.StartAttempt(ctx, job, time.Now().UTC())
+	if err != nil {
+		fmt.Printf("Job %s attempt not recorded: %v\n", job.ID, err)
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the heartbeat reports why it killed the job: lease lost or cancelled
+	stopped := make(chan error, 1)
+	go w.heartbeat(jobCtx, job, w.Lease, stopped, cancel)
+
+	stdout := newCappedBuffer(w.OutputMax)
+	stderr := newCappedBuffer(w.OutputMax)
+	if attempt != nil {
+		go w.streamOutput(jobCtx, attempt, stdout, stderr)
+	}
+
+	runCtx := jobCtx
+	if job.Timeout > 0 {
+		var stop context.CancelFunc
+		runCtx, stop = context.WithTimeout(jobCtx, time.Duration(job.Timeout)*time.Second)
+		defer stop()
+	}
+
+	started := time.Now()
+	var state *os.ProcessState
+	if job.Type != "" {
+		err = runHandler(runCtx, job)
+	} else {
+		cmd := buildCommand(job)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = runProcess(runCtx, cmd, w.KillGrace)
+		state = cmd.ProcessState
+	}
+	if err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %ds", job.Timeout)
+	}
+	cancel()
 
-		cmd := exec.CommandContext(ctx, "bash", "-lc", job.Command)
-		err = cmd.Run()
+	var stopReason error
+	select {
+	case stopReason = <-stopped:
+	default:
+	}
+	if err != nil && errors.Is(stopReason, store.ErrCancelRequested) {
+		err = errors.New("cancelled")
+	}
+	// a worker shutting down kills its job; the run is recorded as failed
+	// and retried, as if its worker had died
+	if err != nil && stopReason == nil && ctx.Err() != nil {
+		err = errors.New("worker shut down")
+	}
+	// ctx may be cancelled by now, the outcome is reported regardless
+	report := context.WithoutCancel(ctx)
+	w.Metrics.JobRan(job.Queue, time.Since(started))
 
-		if err == nil {
-			_ = w.Store.Complete(ctx, job.ID, time.Now().UTC())
-			fmt.Printf("Job %s completed!\n", job.ID)
+	if attempt != nil {
+		attempt.FinishedAt = time.Now().UTC()
+		attempt.Stdout = stdout.String()
+		attempt.Stderr = stderr.String()
+		if state != nil {
+			attempt.ExitCode = state.ExitCode()
+			attempt.Signal = exitSignal(state)
+		} else if job.Type != "" && err == nil {
+			attempt.ExitCode = 0
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		if ferr := w.Transport.FinishAttempt(report, attempt); ferr != nil {
+			fmt.Printf("Job %s attempt not recorded: %v\n", job.ID, ferr)
+		}
+	}
+
+	switch {
+	case errors.Is(stopReason, store.ErrLeaseLost):
+		fmt.Printf("Job %s lease lost, result discarded!\n", job.ID)
+		return
+	case errors.Is(stopReason, store.ErrCancelRequested) && err != nil:
+		if err := w.Transport.MarkCancelled(report, job, time.Now().UTC()); err != nil {
+			fmt.Printf("Job %s cancellation could not be recorded: %v\n", job.ID, err)
+			return
+		}
+		w.Metrics.JobCancelled(job.Queue)
+		fmt.Printf("Job %s cancelled!\n", job.ID)
+		return
+	}
+
+	if err == nil {
+		if err := w.Transport.Complete(report, job, time.Now().UTC()); err != nil {
+			fmt.Printf("Job %s could not be completed: %v\n", job.ID, err)
+			return
+		}
+		w.Metrics.JobCompleted(job.Queue)
+		fmt.Printf("Job %s completed!\n", job.ID)
+	} else {
+		moved, ferr := w.Transport.FailRetry(report, job, time.Now().UTC(), w.Base, w.Cap, err)
+		if ferr != nil {
+			fmt.Printf("Job %s failure could not be recorded: %v\n", job.ID, ferr)
+			return
+		}
+		w.Metrics.JobFailed(job.Queue, moved)
+		if moved {
+			fmt.Printf("Job %s moved to DLQ!\n", job.ID)
 		} else {
-			moved, _ := w.Store.FailRetry(ctx, job, time.Now().UTC(), w.Base, w.Cap, err)
-			if moved {
-				fmt.Printf("Job %s moved to DLQ!\n", job.ID)
-			} else {
-				fmt.Printf("Job %s failed, retry scheduled!\n", job.ID)
+			fmt.Printf("Job %s failed, retry scheduled!\n", job.ID)
+		}
+	}
+}
+
+// buildCommand runs Args directly when present, otherwise Command through
+// bash for jobs enqueued as a single shell string.
+func buildCommand(job *model.Job) *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(job.Args) > 0 {
+		cmd = exec.Command(job.Args[0], job.Args[1:]...)
+	} else {
+		cmd = exec.Command("bash", "-lc", job.Command)
+	}
+
+	cmd.Dir = job.Workdir
+	if len(job.Env) > 0 {
+		keys := make([]string, 0, len(job.Env))
+		for k := range job.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		cmd.Env = os.Environ()
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+job.Env[k])
+		}
+	}
+	return cmd
+}
+
+// streamOutput periodically saves the output captured so far, so
+// `queuectl logs --follow` can show a job while it runs.
+func (w *Worker) streamOutput(ctx context.Context, attempt *model.Attempt, stdout, stderr *cappedBuffer) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	snapshot := *attempt
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			out, errOut := stdout.String(), stderr.String()
+			if out == snapshot.Stdout && errOut == snapshot.Stderr {
+				continue
 			}
+			snapshot.Stdout, snapshot.Stderr = out, errOut
+			_ = w.Transport.UpdateAttemptOutput(ctx, &snapshot)
 		}
 	}
 }
+
+// heartbeat extends the job's lease until ctx is done. If the lease is lost
+// the job is now someone else's, and if a cancel was requested it should not
+// run at all; either way the running command is killed and the reason sent
+// on stopped. The lease is passed in so a reload cannot change it mid-job.
+func (w *Worker) heartbeat(ctx context.Context, job *model.Job, lease time.Duration, stopped chan<- error, kill context.CancelFunc) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.Transport.ExtendLease(ctx, job, time.Now().UTC().Add(lease))
+			if errors.Is(err, store.ErrLeaseLost) || errors.Is(err, store.ErrCancelRequested) {
+				stopped <- err
+				kill()
+				return
+			}
+			if err != nil {
+				fmt.Printf("Job %s heartbeat error: %v\n", job.ID, err)
+			}
+		}
+	}
+}
+
+// pollDelay is how long to sleep when no job was found.
+func (w *Worker) pollDelay() time.Duration {
+	interval := w.PollInterval
+	if interval <= 0 {
+		interval = 200 * time.Millisecond
+	}
+	return interval + time.Duration(rand.Int63n(int64(interval)))
+}
+
+// reap hands expired leases back to the queue, at most once per half lease.
+func (w *Worker) reap(ctx context.Context) {
+	now := time.Now().UTC()
+	if now.Sub(w.lastReap) < w.Lease/2 {
+		return
+	}
+	w.lastReap = now
+
+	n, err := w.Transport.ReapExpired(ctx, now, w.Base, w.Cap)
+	if err != nil {
+		fmt.Println("Reap error:", err)
+		return
+	}
+	if n > 0 {
+		fmt.Printf("Recovered %d job(s) with expired leases\n", n)
+	}
+}
@@ -0,0 +1,291 @@
+// Package metrics keeps queuectl's counters, histograms and gauges and
+// renders them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"queuectl/internal/store"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	waitBuckets     = []float64{0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+	durationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+)
+
+// Metrics records what workers do. All methods are safe on a nil *Metrics,
+// so callers that do not export metrics can leave it unset.
+type Metrics struct {
+	mu sync.Mutex
+
+	// counters by queue
+	claimed      map[string]float64
+	completed    map[string]float64
+	failed       map[string]float64
+	deadLettered map[string]float64
+	cancelled    map[string]float64
+
+	// histograms by queue
+	wait     map[string]*histogram
+	duration map[string]*histogram
+
+	busy map[string]bool // by worker id
+}
+
+func New() *Metrics {
+	return &Metrics{
+		claimed:      map[string]float64{},
+		completed:    map[string]float64{},
+		failed:       map[string]float64{},
+		deadLettered: map[string]float64{},
+		cancelled:    map[string]float64{},
+		wait:         map[string]*histogram{},
+		duration:     map[string]*histogram{},
+		busy:         map[string]bool{},
+	}
+}
+
+// JobClaimed counts a claim and how long the job waited since it became
+// runnable.
+func (m *Metrics) JobClaimed(queue string, wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claimed[queue]++
+	observe(m.wait, queue, waitBuckets, wait)
+}
+
+// JobRan records how long a run took, whatever its outcome.
+func (m *Metrics) JobRan(queue string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observe(m.duration, queue, durationBuckets, d)
+}
+
+func (m *Metrics) JobCompleted(queue string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed[queue]++
+}
+
+// JobFailed counts a failed run; dead is set when it sent the job to the DLQ.
+func (m *Metrics) JobFailed(queue string, dead bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed[queue]++
+	if dead {
+		m.deadLettered[queue]++
+	}
+}
+
+// JobCancelled counts a running job stopped by `queuectl cancel`.
+func (m *Metrics) JobCancelled(queue string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancelled[queue]++
+}
+
+// SetWorkerBusy marks a worker as running a job or idle.
+func (m *Metrics) SetWorkerBusy(workerID string, busy bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.busy[workerID] = busy
+}
+
+// RemoveWorker drops a worker that has stopped from the busy gauge.
+func (m *Metrics) RemoveWorker(workerID string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, workerID)
+}
+
+// WriteTo renders every series in the text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "queuectl_jobs_claimed_total", "Jobs claimed by workers.", m.claimed)
+	writeCounter(&b, "queuectl_jobs_completed_total", "Jobs that finished successfully.", m.completed)
+	writeCounter(&b, "queuectl_jobs_failed_total", "Job runs that failed, including ones that will be retried.", m.failed)
+	writeCounter(&b, "queuectl_jobs_dead_lettered_total", "Jobs moved to the DLQ.", m.deadLettered)
+	writeCounter(&b, "queuectl_jobs_cancelled_total", "Running jobs stopped by a cancel request.", m.cancelled)
+	writeHistograms(&b, "queuectl_job_queue_wait_seconds", "Time from a job becoming runnable to being claimed.", m.wait)
+	writeHistograms(&b, "queuectl_job_duration_seconds", "Time a job took to run.", m.duration)
+
+	if len(m.busy) > 0 {
+		header(&b, "queuectl_worker_busy", "Whether a worker is running a job (1) or idle (0).", "gauge")
+		for _, id := range sortedKeys(m.busy) {
+			v := 0.0
+			if m.busy[id] {
+				v = 1
+			}
+			sample(&b, "queuectl_worker_busy", v, "worker", id)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// WriteQueueGauges renders the current number of jobs per state and the
+// DLQ size, read from the database at scrape time.
+func WriteQueueGauges(ctx context.Context, w io.Writer, st store.Backend) error {
+	stats, err := st.QueueStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	header(&b, "queuectl_jobs", "Jobs in the queue by state.", "gauge")
+	for _, state := range sortedKeys(stats) {
+		if state == "dead" {
+			continue
+		}
+		sample(&b, "queuectl_jobs", float64(stats[state]), "state", state)
+	}
+	header(&b, "queuectl_dlq_size", "Jobs in the dead letter queue.", "gauge")
+	sample(&b, "queuectl_dlq_size", float64(stats["dead"]))
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves /metrics: queue gauges from st when it is not nil, then
+// the series recorded in m.
+func Handler(m *Metrics, st store.Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if st != nil {
+			if err := WriteQueueGauges(r.Context(), w, st); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		_, _ = m.WriteTo(w)
+	})
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func observe(hs map[string]*histogram, queue string, bounds []float64, d time.Duration) {
+	h := hs[queue]
+	if h == nil {
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		hs[queue] = h
+	}
+	v := d.Seconds()
+	if v < 0 {
+		v = 0
+	}
+	for i, le := range h.bounds {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func writeCounter(b *strings.Builder, name, help string, byQueue map[string]float64) {
+	header(b, name, help, "counter")
+	for _, q := range sortedKeys(byQueue) {
+		sample(b, name, byQueue[q], "queue", q)
+	}
+}
+
+func writeHistograms(b *strings.Builder, name, help string, byQueue map[string]*histogram) {
+	header(b, name, help, "histogram")
+	for _, q := range sortedKeys(byQueue) {
+		h := byQueue[q]
+		var cum uint64
+		for i, le := range h.bounds {
+			cum += h.counts[i]
+			sample(b, name+"_bucket", float64(cum), "queue", q, "le", formatFloat(le))
+		}
+		sample(b, name+"_bucket", float64(h.count), "queue", q, "le", "+Inf")
+		sample(b, name+"_sum", h.sum, "queue", q)
+		sample(b, name+"_count", float64(h.count), "queue", q)
+	}
+}
+
+func header(b *strings.Builder, name, help, typ string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one line; labels are name/value pairs.
+func sample(b *strings.Builder, name string, v float64, labels ...string) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attempt is one execution of a job by a worker.
+type Attempt struct {
+	JobID      string
+	Attempt    int
+	WorkerID   string
+	StartedAt  time.Time
+	FinishedAt time.Time // zero while the attempt is still running
+	ExitCode   int       // -1 when unknown or killed by a signal
+	Signal     string
+	Stdout     string
+	Stderr     string
+	Error      string
+}
+
+func (a Attempt) Finished() bool {
+	return !a.FinishedAt.IsZero()
+}
+
+// truncatedMarker follows the kept part of output that went past
+// output_max_bytes.
+const truncatedMarker = "\n[... output truncated, %d bytes dropped]\n"
+
+// TruncatedOutput returns the kept part of a stream with the marker saying
+// how many bytes were dropped after it.
+func TruncatedOutput(kept string, dropped int) string {
+	return kept + fmt.Sprintf(truncatedMarker, dropped)
+}
+
+// SplitTruncated splits stored output into the part the worker kept and
+// whether a truncation marker follows it. The kept part only grows while
+// the job runs; the marker is rewritten as more bytes are dropped.
+func SplitTruncated(out string) (kept string, truncated bool) {
+	if !strings.HasSuffix(out, " bytes dropped]\n") {
+		return out, false
+	}
+	i := strings.LastIndex(out, "\n[... output truncated, ")
+	if i < 0 {
+		return out, false
+	}
+	return out[:i], true
+}
@@ -1,14 +1,69 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Job struct {
-	ID          string
-	Command     string
-	State       string
-	Attempts    int
-	MaxRetries  int
+	ID      string
+	Command string // run with bash -lc when Args is empty
+
+	// Args, when set, is executed directly without a shell.
+	Args    []string
+	Env     map[string]string // added to the worker's environment
+	Workdir string
+
+	// Type, when set, names an in-process handler registered with the
+	// engine; it runs with Payload instead of a command.
+	Type    string
+	Payload json.RawMessage
+
+	Queue    string
+	State    string
+	Attempts int
+	// MaxRetries is how many runs the job gets before it moves to the DLQ;
+	// 0 takes the max_retries config when it is enqueued.
+	MaxRetries  int `json:"max_retries"`
+	Priority    int // higher runs first
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	AvailableAt time.Time
+	Timeout     int // seconds a run may take, 0 for no limit
+
+	// jobs that must complete before this one leaves the blocked state
+	DependsOn []string `json:"depends_on"`
+
+	// UniqueKey, when set, makes enqueueing a second job with the same key
+	// return the first one instead. The key is held while the job is pending,
+	// blocked or processing, or, with UniqueFor set, for that many seconds
+	// after it was created whatever its state.
+	UniqueKey string `json:"unique_key"`
+	UniqueFor int    `json:"unique_for"`
+
+	// lease held by the worker currently processing the job
+	WorkerID       string
+	LeaseExpiresAt time.Time
+	// set on a processing job by `queuectl cancel`; the worker stops it
+	CancelRequested bool
+	// error of the most recent failed run, kept across DLQ retries
+	LastError string
+	// when the job entered the DLQ; zero for jobs still queued
+	FailedAt time.Time
+}
+
+// Display returns what the job runs: the bash command, the argv as a json
+// array, or the handler type followed by its payload.
+func (j Job) Display() string {
+	switch {
+	case j.Type != "":
+		if len(j.Payload) == 0 {
+			return j.Type
+		}
+		return j.Type + " " + string(j.Payload)
+	case len(j.Args) > 0:
+		b, _ := json.Marshal(j.Args)
+		return string(b)
+	}
+	return j.Command
 }
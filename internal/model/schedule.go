@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Schedule enqueues a job from Template every time Cron ticks.
+type Schedule struct {
+	Name     string
+	Cron     string
+	Template string // JobSpec json without id, run_at or delay
+	Paused   bool
+	// Catchup decides what happens to ticks missed while no scheduler ran:
+	// "one" fires once for all of them, "all" fires each, "skip" drops them.
+	Catchup   string
+	NextRunAt time.Time
+	LastRunAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobSpec is the job json accepted by `queuectl enqueue`. On top of the job
+// fields it can schedule the job for later with either run_at (RFC3339) or
+// delay (Go duration, e.g. "90s" or "2h").
+type JobSpec struct {
+	Job
+	RunAt string `json:"run_at"`
+	Delay string `json:"delay"`
+}
+
+// ToJob validates the spec and returns a pending job ready to be enqueued.
+func (s JobSpec) ToJob(now time.Time) (Job, error) {
+	j := s.Job
+
+	kinds := 0
+	for _, set := range []bool{j.Command != "", len(j.Args) > 0, j.Type != ""} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		return Job{}, fmt.Errorf("job needs a command, args or type")
+	case kinds > 1:
+		return Job{}, fmt.Errorf("set only one of command, args or type")
+	case len(j.Args) > 0 && j.Args[0] == "":
+		return Job{}, fmt.Errorf("args[0] must name the program to run")
+	case len(j.Payload) > 0 && j.Type == "":
+		return Job{}, fmt.Errorf("payload needs a handler type")
+	}
+	if len(j.Payload) > 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, j.Payload); err != nil {
+			return Job{}, fmt.Errorf("invalid payload: %w", err)
+		}
+		j.Payload = buf.Bytes()
+	}
+	for k := range j.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return Job{}, fmt.Errorf("invalid env name %q", k)
+		}
+	}
+
+	if j.MaxRetries < 0 {
+		return Job{}, fmt.Errorf("invalid max_retries: %d (must be >= 1, or 0 for the max_retries config)", j.MaxRetries)
+	}
+	if j.Timeout < 0 {
+		return Job{}, fmt.Errorf("invalid timeout: %d (must be seconds >= 0)", j.Timeout)
+	}
+	if j.UniqueFor < 0 {
+		return Job{}, fmt.Errorf("invalid unique_for: %d (must be seconds >= 0)", j.UniqueFor)
+	}
+	if j.UniqueFor > 0 && j.UniqueKey == "" {
+		return Job{}, fmt.Errorf("unique_for needs a unique_key")
+	}
+
+	available := now
+	switch {
+	case s.RunAt != "" && s.Delay != "":
+		return Job{}, fmt.Errorf("set either run_at or delay, not both")
+	case s.RunAt != "":
+		t, err := time.Parse(time.RFC3339, s.RunAt)
+		if err != nil {
+			return Job{}, fmt.Errorf("invalid run_at %q (want RFC3339, e.g. 2025-01-02T15:04:05Z): %w", s.RunAt, err)
+		}
+		available = t.UTC()
+	case s.Delay != "":
+		d, err := time.ParseDuration(s.Delay)
+		if err != nil {
+			return Job{}, fmt.Errorf("invalid delay %q: %w", s.Delay, err)
+		}
+		if d < 0 {
+			return Job{}, fmt.Errorf("invalid delay %q (must not be negative)", s.Delay)
+		}
+		available = now.Add(d)
+	}
+
+	// Fill defaults
+	j.State = "pending"
+	j.Attempts = 0
+	j.CreatedAt = now
+	j.UpdatedAt = now
+	j.AvailableAt = available
+	return j, nil
+}
@@ -0,0 +1,64 @@
+// Package protocol defines the JSON messages remote workers exchange with
+// `queuectl serve` under /worker/. Jobs and attempts travel as model.Job and
+// model.Attempt. Times are always the server's: a remote worker sends
+// durations, never timestamps, so clock skew between hosts does not matter.
+package protocol
+
+// CodeLeaseLost is the ErrorResponse code for a report on a job the worker
+// no longer owns.
+const CodeLeaseLost = "lease_lost"
+
+// ClaimRequest asks for the next runnable job. An empty Queue claims from
+// any queue. A claim with no job available gets 204 No Content.
+type ClaimRequest struct {
+	WorkerID string `json:"worker_id"`
+	LeaseMS  int64  `json:"lease_ms"`
+	Queue    string `json:"queue,omitempty"`
+}
+
+// HeartbeatRequest extends the lease to LeaseMS from now.
+type HeartbeatRequest struct {
+	WorkerID string `json:"worker_id"`
+	LeaseMS  int64  `json:"lease_ms"`
+}
+
+// OwnerRequest identifies the worker reporting on a job: starting an
+// attempt or completing it.
+type OwnerRequest struct {
+	WorkerID string `json:"worker_id"`
+}
+
+// FailRequest reports a failed run. Permanent skips the remaining retries.
+type FailRequest struct {
+	WorkerID   string `json:"worker_id"`
+	Error      string `json:"error"`
+	Permanent  bool   `json:"permanent,omitempty"`
+	Base       int    `json:"backoff_base"`
+	CapSeconds int    `json:"backoff_cap_seconds"`
+}
+
+type FailResponse struct {
+	MovedToDLQ bool `json:"moved_to_dlq"`
+}
+
+// ReapRequest asks the server to return expired leases to the queue.
+type ReapRequest struct {
+	Base       int `json:"backoff_base"`
+	CapSeconds int `json:"backoff_cap_seconds"`
+}
+
+type ReapResponse struct {
+	Reaped int `json:"reaped"`
+}
+
+// ConfigVersionResponse carries the server's config version. Workers poll
+// it and read config again when it moves.
+type ConfigVersionResponse struct {
+	Version int64 `json:"version"`
+}
+
+// ErrorResponse is the body of every non-2xx reply.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"queuectl/internal/metrics"
+	"queuectl/internal/protocol"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenKey is the config key holding the bearer token API clients must send.
+const TokenKey = "api_token"
+
+// maxBody caps request bodies; a job spec is a few KB at most.
+const maxBody = 1 << 20
+
+// Server exposes the queue over HTTP/JSON.
+type Server struct {
+	Store  store.Backend
+	Client *client.Client
+	// AllowNoAuth serves requests without a token while api_token is unset.
+	// Otherwise every request is refused until a token is configured.
+	AllowNoAuth bool
+	// Metrics counts the jobs remote workers claim and report through this
+	// server; GET /metrics adds queue gauges read from the database.
+	Metrics *metrics.Metrics
+
+	mux *http.ServeMux
+}
+
+// New returns a server for the queue in st: a SQLite *store.Store, or any
+// other store.Backend such as an in-memory one.
+func New(st store.Backend) *Server {
+	s := &Server{Store: st, Client: client.NewFromStore(st), Metrics: metrics.New(), mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("POST /jobs", s.enqueue)
+	s.mux.HandleFunc("GET /jobs", s.listJobs)
+	s.mux.HandleFunc("GET /jobs/{id}", s.getJob)
+	s.mux.HandleFunc("POST /jobs/{id}/cancel", s.cancelJob)
+	s.mux.HandleFunc("GET /status", s.status)
+	s.mux.Handle("GET /metrics", metrics.Handler(s.Metrics, st))
+
+	s.mux.HandleFunc("GET /dlq", s.listDLQ)
+	s.mux.HandleFunc("POST /dlq/retry", s.retryDLQWhere)
+	s.mux.HandleFunc("POST /dlq/{id}/retry", s.retryDLQ)
+	s.mux.HandleFunc("DELETE /dlq/{id}", s.purgeDLQ)
+	s.mux.HandleFunc("DELETE /dlq", s.purgeDLQ)
+
+	s.mux.HandleFunc("GET /config", s.listConfig)
+	s.mux.HandleFunc("GET /config/{key}", s.getConfig)
+	s.mux.HandleFunc("PUT /config/{key}", s.setConfig)
+	s.mux.HandleFunc("DELETE /config/{key}", s.unsetConfig)
+
+	s.routeWorkers()
+	return s
+}
+
+// ServeHTTP checks the bearer token, then routes the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="queuectl"`)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorize reads the token on every request so `config set api_token`
+// rotates it without restarting the server.
+func (s *Server) authorize(r *http.Request) error {
+	token, err := s.Store.GetConfig(r.Context(), TokenKey)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", TokenKey, err)
+	}
+	if token == "" {
+		if s.AllowNoAuth {
+			return nil
+		}
+		return fmt.Errorf("%s is not configured", TokenKey)
+	}
+
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return errors.New("missing or invalid bearer token")
+	}
+	return nil
+}
+
+func (s *Server) enqueue(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	j, err := client.ParseJob(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stored, err := s.Client.Enqueue(r.Context(), j)
+	if err != nil {
+		// anything the store rejects that is not a conflict is a bad job:
+		// unknown dependency, invalid queue name and so on
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	if stored.Deduplicated {
+		// nothing was created, the existing job holds the unique key
+		writeJSON(w, http.StatusOK, stored)
+		return
+	}
+	writeJSON(w, http.StatusCreated, stored)
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := client.ListOptions{State: q.Get("state"), Queue: q.Get("queue"), SortBy: q.Get("sort")}
+	if v := q.Get("min_priority"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid min_priority %q", v))
+			return
+		}
+		opts.MinPriority = &n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		opts.Limit = n
+	}
+
+	jobs, err := s.Client.List(r.Context(), opts)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusBadRequest), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	j, err := s.Client.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.Client.Cancel(r.Context(), id); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	s.getJob(w, r)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.Store.QueueStatus(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) listDLQ(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.Client.ListDead(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) retryDLQ(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.Client.RetryDead(r.Context(), id); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	s.getJob(w, r)
+}
+
+// retryDLQWhere retries every DLQ job matching the filter and since
+// (a Go duration) query parameters; with neither it retries the whole DLQ.
+func (s *Server) retryDLQWhere(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := client.DeadFilter{Command: q.Get("filter")}
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q", v))
+			return
+		}
+		f.Since = time.Now().UTC().Add(-d)
+	}
+
+	ids, err := s.Client.RetryDeadWhere(r.Context(), f)
+	if err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"retried": ids})
+}
+
+// purgeDLQ deletes one DLQ job, or all of them on DELETE /dlq.
+func (s *Server) purgeDLQ(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if id := r.PathValue("id"); id != "" {
+		ids = append(ids, id)
+	}
+
+	n, err := s.Client.PurgeDead(r.Context(), ids...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(ids) > 0 && n == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s is not in the DLQ: %w", ids[0], store.ErrNotFound))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+func (s *Server) listConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.Store.AllConfig(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for k := range cfg {
+		if isSecret(k) {
+			cfg[k] = redacted
+		}
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// isSecret reports whether the value of config key must not be sent back.
+func isSecret(key string) bool {
+	s, ok := store.LookupSetting(key)
+	return ok && s.Secret
+}
+
+// redacted replaces the token in config responses.
+const redacted = "********"
+
+type configValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	val, err := s.Store.GetConfig(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if val == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("config %s is not set", key))
+		return
+	}
+	if isSecret(key) {
+		val = redacted
+	}
+	writeJSON(w, http.StatusOK, configValue{Key: key, Value: val})
+}
+
+func (s *Server) setConfig(w http.ResponseWriter, r *http.Request) {
+	var body configValue
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body, want {\"value\":\"...\"}: %w", err))
+		return
+	}
+	key := r.PathValue("key")
+	if err := s.Store.SetConfig(r.Context(), key, body.Value); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	val, err := s.Store.GetConfig(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if isSecret(key) {
+		val = redacted
+	}
+	writeJSON(w, http.StatusOK, configValue{Key: key, Value: val})
+}
+
+// unsetConfig puts a setting back to its default and returns that value.
+func (s *Server) unsetConfig(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if err := s.Store.UnsetConfig(r.Context(), key); err != nil {
+		writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	val, err := s.Store.GetConfig(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if isSecret(key) && val != "" {
+		val = redacted
+	}
+	writeJSON(w, http.StatusOK, configValue{Key: key, Value: val})
+}
+
+// errorStatus maps the store's typed errors to HTTP statuses, falling back
+// to def for anything else.
+func errorStatus(err error, def int) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrDuplicateID), errors.Is(err, store.ErrInvalidState), errors.Is(err, store.ErrLeaseLost):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrInvalidConfig):
+		return http.StatusBadRequest
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	}
+	return def
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	resp := protocol.ErrorResponse{Error: err.Error()}
+	if errors.Is(err, store.ErrLeaseLost) {
+		resp.Code = protocol.CodeLeaseLost
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"queuectl/internal/model"
+	"queuectl/internal/protocol"
+	"queuectl/internal/store"
+	"strconv"
+	"time"
+)
+
+// routeWorkers adds the endpoints remote workers use in place of opening
+// the database themselves.
+func (s *Server) routeWorkers() {
+	s.mux.HandleFunc("POST /worker/claim", s.workerClaim)
+	s.mux.HandleFunc("POST /worker/reap", s.workerReap)
+	s.mux.HandleFunc("GET /worker/config-version", s.workerConfigVersion)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/heartbeat", s.workerHeartbeat)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/complete", s.workerComplete)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/fail", s.workerFail)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/cancelled", s.workerCancelled)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/attempts", s.workerStartAttempt)
+	s.mux.HandleFunc("PUT /worker/jobs/{id}/attempts/{n}/output", s.workerAttemptOutput)
+	s.mux.HandleFunc("POST /worker/jobs/{id}/attempts/{n}/finish", s.workerFinishAttempt)
+}
+
+// reportedError is a failure reported by a remote worker. It still matches
+// store.ErrPermanent when the worker said so.
+type reportedError struct {
+	msg       string
+	permanent bool
+}
+
+func (e reportedError) Error() string { return e.msg }
+
+func (e reportedError) Is(target error) bool {
+	return e.permanent && target == store.ErrPermanent
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return false
+	}
+	return true
+}
+
+func leaseDuration(ms int64) (time.Duration, error) {
+	if ms < 100 {
+		return 0, fmt.Errorf("invalid lease_ms %d", ms)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (s *Server) workerClaim(w http.ResponseWriter, r *http.Request) {
+	var req protocol.ClaimRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	lease, err := leaseDuration(req.LeaseMS)
+	if err != nil || req.WorkerID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("claim needs worker_id and lease_ms"))
+		return
+	}
+
+	job, err := s.Store.Claim(r.Context(), time.Now().UTC(), req.WorkerID, lease, req.Queue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if job == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.Metrics.JobClaimed(job.Queue, time.Since(job.AvailableAt))
+	s.Metrics.SetWorkerBusy(req.WorkerID, true)
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) workerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req protocol.HeartbeatRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	lease, err := leaseDuration(req.LeaseMS)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// a requested cancel still extends the lease; the worker sees
+	// cancel_requested in the reply and stops the job
+	j := &model.Job{ID: r.PathValue("id"), WorkerID: req.WorkerID}
+	err = s.Store.ExtendLease(r.Context(), j, time.Now().UTC().Add(lease))
+	if err != nil && !errors.Is(err, store.ErrCancelRequested) {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) workerComplete(w http.ResponseWriter, r *http.Request) {
+	var req protocol.OwnerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	j, err := s.ownedJob(r, req.WorkerID)
+	if err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	if err := s.Store.Complete(r.Context(), j, time.Now().UTC()); err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	s.Metrics.JobCompleted(j.Queue)
+	s.Metrics.SetWorkerBusy(req.WorkerID, false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workerFail records a failed run. Attempts and the retry limit come from
+// the database, not from the worker.
+func (s *Server) workerFail(w http.ResponseWriter, r *http.Request) {
+	var req protocol.FailRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	j, err := s.ownedJob(r, req.WorkerID)
+	if err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+
+	execErr := reportedError{msg: req.Error, permanent: req.Permanent}
+	moved, err := s.Store.FailRetry(r.Context(), j, time.Now().UTC(), req.Base, req.CapSeconds, execErr)
+	if err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	s.Metrics.JobFailed(j.Queue, moved)
+	s.Metrics.SetWorkerBusy(req.WorkerID, false)
+	writeJSON(w, http.StatusOK, protocol.FailResponse{MovedToDLQ: moved})
+}
+
+func (s *Server) workerCancelled(w http.ResponseWriter, r *http.Request) {
+	var req protocol.OwnerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	j, err := s.ownedJob(r, req.WorkerID)
+	if err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	if err := s.Store.MarkCancelled(r.Context(), j, time.Now().UTC()); err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+	s.Metrics.JobCancelled(j.Queue)
+	s.Metrics.SetWorkerBusy(req.WorkerID, false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) workerReap(w http.ResponseWriter, r *http.Request) {
+	var req protocol.ReapRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	now := time.Now().UTC()
+	// the workers of expired jobs stopped heartbeating; once their job is
+	// reaped they no longer count as busy
+	var expired []model.Job
+	if s.Metrics != nil {
+		processing, err := s.Store.ListJobsFiltered(r.Context(), store.JobFilter{State: "processing"})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, j := range processing {
+			if !j.LeaseExpiresAt.IsZero() && j.LeaseExpiresAt.Before(now) {
+				expired = append(expired, j)
+			}
+		}
+	}
+
+	n, err := s.Store.ReapExpired(r.Context(), now, req.Base, req.CapSeconds)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, j := range expired {
+		cur, err := s.Store.GetJob(r.Context(), j.ID)
+		if err != nil || cur.State != "processing" || cur.WorkerID != j.WorkerID {
+			s.Metrics.SetWorkerBusy(j.WorkerID, false)
+		}
+	}
+	writeJSON(w, http.StatusOK, protocol.ReapResponse{Reaped: n})
+}
+
+func (s *Server) workerConfigVersion(w http.ResponseWriter, r *http.Request) {
+	v, err := s.Store.ConfigVersion(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, protocol.ConfigVersionResponse{Version: v})
+}
+
+func (s *Server) workerStartAttempt(w http.ResponseWriter, r *http.Request) {
+	var req protocol.OwnerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	j, err := s.ownedJob(r, req.WorkerID)
+	if err != nil {
+		s.writeWorkerError(w, req.WorkerID, err)
+		return
+	}
+
+	a, err := s.Store.StartAttempt(r.Context(), j, time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+func (s *Server) workerAttemptOutput(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.reportedAttempt(w, r)
+	if !ok {
+		return
+	}
+	if err := s.Store.UpdateAttemptOutput(r.Context(), a); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) workerFinishAttempt(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.reportedAttempt(w, r)
+	if !ok {
+		return
+	}
+	a.FinishedAt = time.Now().UTC()
+	if err := s.Store.FinishAttempt(r.Context(), a); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if j, err := s.Store.GetJob(r.Context(), a.JobID); err == nil {
+		s.Metrics.JobRan(j.Queue, a.FinishedAt.Sub(a.StartedAt))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeWorkerError reports err to the worker that made the request. A
+// worker that lost its lease is no longer running the job, so it stops
+// counting as busy.
+func (s *Server) writeWorkerError(w http.ResponseWriter, workerID string, err error) {
+	if errors.Is(err, store.ErrLeaseLost) {
+		s.Metrics.SetWorkerBusy(workerID, false)
+	}
+	writeError(w, errorStatus(err, http.StatusInternalServerError), err)
+}
+
+// ownedJob loads the job in the path and checks workerID is processing it.
+// A job that is gone altogether was lost as far as the worker is concerned.
+func (s *Server) ownedJob(r *http.Request, workerID string) (*model.Job, error) {
+	j, err := s.Store.GetJob(r.Context(), r.PathValue("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, store.ErrLeaseLost
+	}
+	if err != nil {
+		return nil, err
+	}
+	if j.State != "processing" || j.WorkerID != workerID {
+		return nil, store.ErrLeaseLost
+	}
+	return j, nil
+}
+
+// reportedAttempt decodes an attempt sent by a worker and checks it is the
+// attempt in the path and that the worker recorded it.
+func (s *Server) reportedAttempt(w http.ResponseWriter, r *http.Request) (*model.Attempt, bool) {
+	var a model.Attempt
+	if !decode(w, r, &a) {
+		return nil, false
+	}
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid attempt %q", r.PathValue("n")))
+		return nil, false
+	}
+
+	stored, err := s.Store.GetAttempt(r.Context(), r.PathValue("id"), n)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if stored == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s has no attempt %d: %w", r.PathValue("id"), n, store.ErrNotFound))
+		return nil, false
+	}
+	if stored.WorkerID != a.WorkerID || stored.Finished() {
+		writeError(w, http.StatusConflict, errors.New("attempt belongs to another worker or already finished"))
+		return nil, false
+	}
+
+	a.JobID, a.Attempt, a.StartedAt = stored.JobID, stored.Attempt, stored.StartedAt
+	return &a, true
+}
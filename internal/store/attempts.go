@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"queuectl/internal/model"
+	"time"
+)
+
+const attemptColumns = `job_id, attempt, worker_id, started_at, finished_at,
+		       exit_code, signal, stdout, stderr, error`
+
+func scanAttempt(r rowScanner) (*model.Attempt, error) {
+	var a model.Attempt
+	var startedAtStr, finishedAtStr string
+
+	err := r.Scan(
+		&a.JobID,
+		&a.Attempt,
+		&a.WorkerID,
+		&startedAtStr,
+		&finishedAtStr,
+		&a.ExitCode,
+		&a.Signal,
+		&a.Stdout,
+		&a.Stderr,
+		&a.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.StartedAt = parseTime(startedAtStr)
+	if finishedAtStr != "" {
+		a.FinishedAt = parseTime(finishedAtStr)
+	}
+	return &a, nil
+}
+
+// StartAttempt opens a new attempt row for a claimed job and returns it.
+// Attempt numbers keep counting up across DLQ retries.
+func (s *Store) StartAttempt(ctx context.Context, j *model.Job, now time.Time) (*model.Attempt, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO job_attempts (job_id, attempt, worker_id, started_at)
+		SELECT ?, COALESCE(MAX(attempt), 0) + 1, ?, ?
+		FROM job_attempts WHERE job_id=?
+		RETURNING attempt
+	`, j.ID, j.WorkerID, formatTime(now), j.ID).Scan(&n)
+	if err != nil {
+		return nil, fmt.Errorf("start attempt: %w", err)
+	}
+
+	return &model.Attempt{
+		JobID:     j.ID,
+		Attempt:   n,
+		WorkerID:  j.WorkerID,
+		StartedAt: now,
+		ExitCode:  -1,
+	}, nil
+}
+
+// UpdateAttemptOutput stores the output captured so far for a running attempt.
+func (s *Store) UpdateAttemptOutput(ctx context.Context, a *model.Attempt) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE job_attempts SET stdout=?, stderr=?
+		WHERE job_id=? AND attempt=? AND finished_at=''
+	`, a.Stdout, a.Stderr, a.JobID, a.Attempt)
+	return err
+}
+
+// FinishAttempt records the outcome of an attempt.
+func (s *Store) FinishAttempt(ctx context.Context, a *model.Attempt) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE job_attempts
+		SET finished_at=?, exit_code=?, signal=?, stdout=?, stderr=?, error=?
+		WHERE job_id=? AND attempt=?
+	`, formatTime(a.FinishedAt), a.ExitCode, a.Signal, a.Stdout, a.Stderr, a.Error,
+		a.JobID, a.Attempt)
+	return err
+}
+
+// abandonAttempts closes attempts of a job that will never report back.
+func (s *Store) abandonAttempts(ctx context.Context, jobID string, now time.Time, reason string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE job_attempts SET finished_at=?, error=?
+		WHERE job_id=? AND finished_at=''
+	`, formatTime(now), reason, jobID)
+	return err
+}
+
+// ListAttempts returns every recorded attempt of a job, oldest first.
+func (s *Store) ListAttempts(ctx context.Context, jobID string) ([]model.Attempt, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+attemptColumns+`
+		FROM job_attempts
+		WHERE job_id=?
+		ORDER BY attempt ASC
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Attempt
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+	return result, rows.Err()
+}
+
+// GetAttempt returns attempt n of a job, or the latest one when n is 0.
+// It returns nil if there is no such attempt.
+func (s *Store) GetAttempt(ctx context.Context, jobID string, n int) (*model.Attempt, error) {
+	q := `SELECT ` + attemptColumns + ` FROM job_attempts WHERE job_id=?`
+	args := []any{jobID}
+	if n > 0 {
+		q += ` AND attempt=?`
+		args = append(args, n)
+	} else {
+		q += ` ORDER BY attempt DESC LIMIT 1`
+	}
+
+	a, err := scanAttempt(s.DB.QueryRowContext(ctx, q, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"queuectl/internal/model"
+	"time"
+)
+
+// Backend is the storage a queue runs on: enqueueing and listing jobs, the
+// worker lifecycle from claim to completion or the DLQ, attempt logs, the
+// DLQ itself and config. *Store keeps everything in SQLite; *MemStore keeps
+// it in memory. Every backend must pass the conformance suite in
+// internal/tests/backend_test.go.
+//
+// Schedules, queue limits, retention and migrations are SQLite features
+// and stay on *Store.
+type Backend interface {
+	EnqueueJob(ctx context.Context, j model.Job) (EnqueueResult, error)
+	EnqueueBatch(ctx context.Context, jobs []model.Job, atomic bool) ([]BatchResult, error)
+	GetJob(ctx context.Context, id string) (*model.Job, error)
+	ListJobsFiltered(ctx context.Context, f JobFilter) ([]model.Job, error)
+	QueueStatus(ctx context.Context) (map[string]int, error)
+	CancelJob(ctx context.Context, id string, now time.Time) (bool, error)
+	BlockedOn(ctx context.Context) (map[string][]string, error)
+
+	Claim(ctx context.Context, now time.Time, workerID string, lease time.Duration, queue string) (*model.Job, error)
+	ExtendLease(ctx context.Context, j *model.Job, until time.Time) error
+	Complete(ctx context.Context, j *model.Job, now time.Time) error
+	FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error)
+	MarkCancelled(ctx context.Context, j *model.Job, now time.Time) error
+	ReapExpired(ctx context.Context, now time.Time, base, capSeconds int) (int, error)
+
+	StartAttempt(ctx context.Context, j *model.Job, now time.Time) (*model.Attempt, error)
+	UpdateAttemptOutput(ctx context.Context, a *model.Attempt) error
+	FinishAttempt(ctx context.Context, a *model.Attempt) error
+	ListAttempts(ctx context.Context, jobID string) ([]model.Attempt, error)
+	GetAttempt(ctx context.Context, jobID string, n int) (*model.Attempt, error)
+
+	ListDLQ(ctx context.Context) ([]model.Job, error)
+	GetDLQJob(ctx context.Context, id string) (*model.Job, error)
+	RetryDLQ(ctx context.Context, jobID string) error
+	RetryDLQWhere(ctx context.Context, f DLQFilter) ([]string, error)
+	PurgeDLQ(ctx context.Context, ids ...string) (int, error)
+	PurgeDLQWhere(ctx context.Context, f DLQFilter) (int, error)
+
+	SetConfig(ctx context.Context, key, value string) error
+	UnsetConfig(ctx context.Context, key string) error
+	GetConfig(ctx context.Context, key string) (string, error)
+	ConfigOverrides(ctx context.Context) (map[string]string, error)
+	AllConfig(ctx context.Context) (map[string]string, error)
+	ConfigVersion(ctx context.Context) (int64, error)
+	MustGetInt(key string, defaultVal int) int
+
+	Close() error
+}
+
+var (
+	_ Backend = (*Store)(nil)
+	_ Backend = (*MemStore)(nil)
+)
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"queuectl/internal/model"
+)
+
+// DefaultBatchSize is how many jobs EnqueueBatch commits per transaction in
+// best-effort mode.
+const DefaultBatchSize = 500
+
+// BatchResult is the outcome of one job passed to EnqueueBatch.
+type BatchResult struct {
+	EnqueueResult
+	// Err is why the job was not enqueued; nil when it was, or when it was
+	// deduplicated.
+	Err error
+}
+
+// EnqueueBatch adds jobs in order and returns one result per job. A job may
+// depend on jobs earlier in the same batch.
+//
+// With atomic set all jobs go in one transaction: the first job that fails
+// has its error recorded in its result, everything is rolled back and the
+// returned error says which job it was. Otherwise jobs are committed
+// DefaultBatchSize at a time, a failing job is skipped with its error in its
+// result and the rest still go in. The returned error is then only set when
+// the database itself fails.
+func (s *Store) EnqueueBatch(ctx context.Context, jobs []model.Job, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(jobs))
+	size := DefaultBatchSize
+	if atomic {
+		size = len(jobs)
+	}
+
+	for start := 0; start < len(jobs); start += size {
+		end := min(start+size, len(jobs))
+		if err := s.enqueueChunk(ctx, jobs[start:end], results[start:end], atomic); err != nil {
+			// the chunk was rolled back, so none of its jobs went in
+			for i := start; i < end; i++ {
+				results[i].EnqueueResult = EnqueueResult{}
+			}
+			if atomic {
+				return results, err
+			}
+			return results, fmt.Errorf("enqueue batch: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// enqueueChunk enqueues jobs in one transaction, each under its own
+// savepoint so a failing job leaves none of its rows behind.
+func (s *Store) enqueueChunk(ctx context.Context, jobs []model.Job, results []BatchResult, atomic bool) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, j := range jobs {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT enqueue_job`); err != nil {
+			return err
+		}
+		res, err := s.enqueue(ctx, tx, j)
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				return fmt.Errorf("job %d: %w (nothing was enqueued)", i+1, err)
+			}
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO enqueue_job`); err != nil {
+				return err
+			}
+		} else {
+			results[i].EnqueueResult = res
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE enqueue_job`); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx commit: %w", err)
+	}
+	return nil
+}
@@ -3,28 +3,220 @@ package store
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
-// file for config cli functions
+// Setting types.
+const (
+	SettingInt    = "int"
+	SettingEnum   = "enum"
+	SettingAge    = "age" // a ParseAge duration such as 7d
+	SettingString = "string"
+)
+
+// Setting describes a config key queuectl reads: its type, default and
+// allowed values. The database only stores values that differ from the
+// default.
+type Setting struct {
+	Key     string
+	Type    string
+	Default string
+	// Min and Max bound int settings.
+	Min, Max int
+	// Values lists what an enum setting accepts.
+	Values      []string
+	Description string
+	// Secret values are redacted wherever config is shown.
+	Secret bool
+}
+
+// settings is the config registry, sorted by key.
+var settings = []Setting{
+	{Key: "api_token", Type: SettingString, Secret: true,
+		Description: "Bearer token required by `queuectl serve`; not set by default"},
+	{Key: "backoff_base", Type: SettingInt, Default: "2", Min: 1, Max: 60,
+		Description: "Exponential retry growth: a job waits backoff_base^attempts seconds before its next run"},
+	{Key: "backoff_cap_seconds", Type: SettingInt, Default: "60", Min: 1, Max: 7 * 24 * 3600,
+		Description: "Maximum backoff delay in seconds"},
+	{Key: "completed_retention", Type: SettingAge, Default: "0",
+		Description: "Completed and cancelled jobs are deleted this long after they finished, e.g. 7d or 12h (0 = keep forever)"},
+	{Key: "dependency_failure", Type: SettingEnum, Default: "block", Values: []string{"block", "cascade"},
+		Description: "What happens to blocked children when a parent lands in the DLQ or is cancelled: block (wait for dlq retry) or cascade (move them to the DLQ too)"},
+	{Key: "dlq_max_age_days", Type: SettingInt, Default: "0", Min: 0, Max: 36500,
+		Description: "DLQ jobs that failed longer ago than this are deleted (0 = keep forever)"},
+	{Key: "dlq_max_count", Type: SettingInt, Default: "0", Min: 0, Max: math.MaxInt32,
+		Description: "Only this many of the most recent DLQ jobs are kept (0 = no limit)"},
+	{Key: "job_timeout_seconds", Type: SettingInt, Default: "0", Min: 0, Max: 7 * 24 * 3600,
+		Description: "Default timeout for jobs enqueued without one (0 = no limit)"},
+	{Key: "lease_seconds", Type: SettingInt, Default: "30", Min: 1, Max: 3600,
+		Description: "How long a claim stays valid without a heartbeat; expired claims are returned to the queue as a failed attempt"},
+	{Key: "max_retries", Type: SettingInt, Default: "3", Min: 1, Max: 1000,
+		Description: "Runs a job gets before it moves to the DLQ, for jobs enqueued without max_retries"},
+	{Key: "output_max_bytes", Type: SettingInt, Default: "65536", Min: 0, Max: 64 << 20,
+		Description: "Bytes of stdout and stderr kept per attempt; the rest is replaced by a truncation marker"},
+	{Key: "poll_interval_ms", Type: SettingInt, Default: "200", Min: 10, Max: 5000,
+		Description: "How long an idle worker waits before looking for work again, plus up to as much random jitter"},
+	{Key: "timeout_grace_seconds", Type: SettingInt, Default: "10", Min: 0, Max: 3600,
+		Description: "Time between SIGTERM and SIGKILL when a job times out or is cancelled"},
+	{Key: "worker_concurrency", Type: SettingInt, Default: "1", Min: 1, Max: 256,
+		Description: "Jobs each `queuectl worker start` runs at once, unless started with --count"},
+}
+
+// Settings returns every known setting, sorted by key.
+func Settings() []Setting {
+	return slices.Clone(settings)
+}
+
+// LookupSetting returns the setting registered for key.
+func LookupSetting(key string) (Setting, bool) {
+	i, ok := slices.BinarySearchFunc(settings, key, func(s Setting, key string) int {
+		return strings.Compare(s.Key, key)
+	})
+	if !ok {
+		return Setting{}, false
+	}
+	return settings[i], true
+}
+
+// Allowed describes the values a setting accepts, for help output.
+func (s Setting) Allowed() string {
+	switch s.Type {
+	case SettingInt:
+		return fmt.Sprintf("%d..%d", s.Min, s.Max)
+	case SettingEnum:
+		return strings.Join(s.Values, " | ")
+	case SettingAge:
+		return "0 or a duration such as 30m, 12h, 7d"
+	}
+	return "any string"
+}
+
+// Validate checks value against the setting and returns it in canonical
+// form. Errors wrap ErrInvalidConfig.
+func (s Setting) Validate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch s.Type {
+	case SettingInt:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < s.Min || n > s.Max {
+			return "", fmt.Errorf("%s must be an integer in %s, got %q: %w", s.Key, s.Allowed(), value, ErrInvalidConfig)
+		}
+		return strconv.Itoa(n), nil
+	case SettingEnum:
+		if !slices.Contains(s.Values, value) {
+			return "", fmt.Errorf("%s must be one of %s, got %q: %w", s.Key, s.Allowed(), value, ErrInvalidConfig)
+		}
+	case SettingAge:
+		if _, err := ParseAge(value); err != nil {
+			return "", fmt.Errorf("%s: %v: %w", s.Key, err, ErrInvalidConfig)
+		}
+	}
+	return value, nil
+}
+
+// ValidateConfig checks that key is a known setting and value suits it, and
+// returns the value to store.
+func ValidateConfig(key, value string) (string, error) {
+	s, ok := LookupSetting(key)
+	if !ok {
+		return "", fmt.Errorf("unknown config key %q: %w", key, ErrInvalidConfig)
+	}
+	return s.Validate(value)
+}
+
+// effectiveConfig merges the stored overrides over the registry defaults.
+// Unknown keys found in the database are kept. Settings without a default
+// are left out until they are set.
+func effectiveConfig(overrides map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, s := range settings {
+		if s.Default != "" {
+			result[s.Key] = s.Default
+		}
+	}
+	for k, v := range overrides {
+		result[k] = v
+	}
+	return result
+}
+
+// configInt parses the stored value of key, or its default when value is
+// "". A value that is not a valid int for the setting, left by an older
+// queuectl that did not validate, falls back to the default with a warning.
+// defaultVal is used for keys that are not int settings.
+func configInt(key, value string, defaultVal int) int {
+	s, ok := LookupSetting(key)
+	if !ok || s.Type != SettingInt {
+		if value == "" {
+			value = s.Default
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultVal
+		}
+		return n
+	}
+	def, _ := strconv.Atoi(s.Default)
+	if value == "" {
+		return def
+	}
+	valid, err := s.Validate(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring config %v, using the default %s\n", err, s.Default)
+		return def
+	}
+	n, _ := strconv.Atoi(valid)
+	return n
+}
+
+// SetConfig validates value against the key's setting and stores it.
 func (s *Store) SetConfig(ctx context.Context, key, value string) error {
-	_, err := s.DB.ExecContext(ctx, `
+	value, err := ValidateConfig(key, value)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, `
 		INSERT INTO config (key, value) VALUES (?, ?)
 		ON CONFLICT(key) DO UPDATE SET value=excluded.value
 	`, key, value)
 	return err
 }
 
+// UnsetConfig removes the value set for key, so it reads as its default
+// again. Keys the registry does not know can be unset only if they are set.
+func (s *Store) UnsetConfig(ctx context.Context, key string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM config WHERE key=?`, key)
+	if err != nil {
+		return err
+	}
+	if _, known := LookupSetting(key); !known {
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("unknown config key %q: %w", key, ErrInvalidConfig)
+		}
+	}
+	return nil
+}
+
+// GetConfig returns the value of key: the one set in the database, or the
+// setting's default. Unknown keys that are not set read as "".
 func (s *Store) GetConfig(ctx context.Context, key string) (string, error) {
 	var val string
 	err := s.DB.QueryRowContext(ctx, `SELECT value FROM config WHERE key=?`, key).Scan(&val)
 	if err == sql.ErrNoRows {
-		return "", nil
+		setting, _ := LookupSetting(key)
+		return setting.Default, nil
 	}
 	return val, err
 }
 
-func (s *Store) AllConfig(ctx context.Context) (map[string]string, error) {
+// ConfigOverrides returns the values set in the database, including keys
+// the registry does not know.
+func (s *Store) ConfigOverrides(ctx context.Context) (map[string]string, error) {
 	rows, err := s.DB.QueryContext(ctx, `SELECT key, value FROM config`)
 	if err != nil {
 		return nil, err
@@ -34,20 +226,43 @@ func (s *Store) AllConfig(ctx context.Context) (map[string]string, error) {
 	result := map[string]string{}
 	for rows.Next() {
 		var k, v string
-		_ = rows.Scan(&k, &v)
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
 		result[k] = v
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
-func (s *Store) MustGetInt(key string, defaultVal int) int {
-	val, err := s.GetConfig(context.Background(), key)
-	if err != nil || val == "" {
-		return defaultVal
+// AllConfig returns the value of every setting, default or set, plus any
+// unknown keys set in the database.
+func (s *Store) AllConfig(ctx context.Context) (map[string]string, error) {
+	overrides, err := s.ConfigOverrides(ctx)
+	if err != nil {
+		return nil, err
 	}
-	n, err := strconv.Atoi(val)
+	return effectiveConfig(overrides), nil
+}
+
+// ConfigVersion returns a counter that moves on every config change, so
+// callers can tell whether config needs reading again.
+func (s *Store) ConfigVersion(ctx context.Context) (int64, error) {
+	var v int64
+	err := s.DB.QueryRowContext(ctx, `SELECT version FROM config_version WHERE id=1`).Scan(&v)
 	if err != nil {
+		return 0, fmt.Errorf("read config version: %w", err)
+	}
+	return v, nil
+}
+
+// MustGetInt returns the int value of key, or its default when it is unset
+// or invalid. defaultVal is only used for keys that are not int settings
+// and when the database cannot be read.
+func (s *Store) MustGetInt(key string, defaultVal int) int {
+	var val string
+	err := s.DB.QueryRow(`SELECT value FROM config WHERE key=?`, key).Scan(&val)
+	if err != nil && err != sql.ErrNoRows {
 		return defaultVal
 	}
-	return n
+	return configInt(key, val, defaultVal)
 }
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -12,44 +15,145 @@ type Store struct {
 	DB *sql.DB
 }
 
+// connPragmas are applied to every connection the pool opens.
+const connPragmas = `_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)`
+
+// NewStore opens the database at path, creating it if needed, and migrates
+// it to the latest schema. It returns ErrSchemaTooNew when a newer queuectl
+// has already migrated it further.
 func NewStore(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+	s, err := OpenStore(path)
 	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
+		return nil, err
 	}
+	if err := s.Migrate(context.Background()); err != nil {
+		s.DB.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return s, nil
+}
 
-	if _, err := db.Exec(`PRAGMA journal_mode = WAL;`); err != nil {
-		return nil, fmt.Errorf("enable WAL: %w", err)
+// OpenStore opens the database at path without migrating it, for tools that
+// inspect or change the schema version themselves.
+func OpenStore(path string) (*Store, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", path+sep+connPragmas)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	if err := initDB(db); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return &Store{DB: db}, nil
+}
 
-	if _, err := db.Exec(`PRAGMA busy_timeout = 5000;`); err != nil {
-		return nil, fmt.Errorf("set busy_timeout: %w", err)
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// initDB sets the pragmas stored in the database file itself.
+func initDB(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
 	}
+	defer conn.Close()
 
-	if _, err := db.Exec(`PRAGMA synchronous = NORMAL;`); err != nil {
-		return nil, fmt.Errorf("set synchronous: %w", err)
+	// auto_vacuum only takes effect on a new database, so it goes before
+	// anything writes the file; gc --vacuum converts older ones. Setting it
+	// on an existing database rewrites its header, which would break the
+	// snapshots of other connections' transactions.
+	var pages int
+	if err := conn.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	if pages == 0 {
+		if _, err := conn.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL;`); err != nil {
+			return fmt.Errorf("set auto_vacuum: %w", err)
+		}
 	}
 
-	// Migrate schema
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("migrate: %w", err)
+	if err := enableWAL(ctx, conn); err != nil {
+		return fmt.Errorf("enable WAL: %w", err)
 	}
+	return nil
+}
 
-	return &Store{DB: db}, nil
+// enableWAL switches the database to WAL unless it already is. The switch
+// needs the database to itself, and SQLite reports SQLITE_BUSY at once
+// instead of waiting out busy_timeout, so processes starting together retry
+// the way beginImmediate does.
+func enableWAL(ctx context.Context, conn *sql.Conn) error {
+	deadline := time.Now().Add(migrationLockWait)
+	for {
+		var mode string
+		err := conn.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&mode)
+		if err == nil && strings.EqualFold(mode, "wal") {
+			return nil
+		}
+		if err == nil {
+			err = conn.QueryRowContext(ctx, `PRAGMA journal_mode = WAL`).Scan(&mode)
+			if err == nil && strings.EqualFold(mode, "wal") {
+				return nil
+			}
+		}
+		if err != nil && !isBusy(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("journal mode is still %s", mode)
+			}
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 }
 
-func runMigrations(db *sql.DB) error {
-	schema := `
-CREATE TABLE IF NOT EXISTS jobs (
+// jobStates lists every state the code writes to jobs.state. Older databases
+// whose CHECK constraint lags behind are rebuilt by ensureJobStates.
+const jobStates = `'pending','processing','completed','failed','dead','blocked','cancelled'`
+
+func jobsTableDDL(name string) string {
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
   id TEXT PRIMARY KEY,
   command TEXT NOT NULL,
-  state TEXT NOT NULL CHECK (state IN ('pending','processing','completed','failed','dead')),
+  args TEXT NOT NULL DEFAULT '',
+  env TEXT NOT NULL DEFAULT '',
+  workdir TEXT NOT NULL DEFAULT '',
+  type TEXT NOT NULL DEFAULT '',
+  payload TEXT NOT NULL DEFAULT '',
+  queue TEXT NOT NULL DEFAULT 'default',
+  state TEXT NOT NULL CHECK (state IN (%s)),
   attempts INTEGER NOT NULL DEFAULT 0,
   max_retries INTEGER NOT NULL DEFAULT 3,
+  priority INTEGER NOT NULL DEFAULT 0,
   created_at TEXT NOT NULL,
   updated_at TEXT NOT NULL,
-  available_at TEXT NOT NULL
-);
+  available_at TEXT NOT NULL,
+  timeout_seconds INTEGER NOT NULL DEFAULT 0,
+  worker_id TEXT NOT NULL DEFAULT '',
+  lease_expires_at TEXT NOT NULL DEFAULT '',
+  cancel_requested INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  unique_key TEXT NOT NULL DEFAULT '',
+  unique_for INTEGER NOT NULL DEFAULT 0
+);`, name, jobStates)
+}
+
+// migrateBaseline brings a new database, or one created before migrations
+// were versioned, to the version 1 schema. Every step is idempotent: tables
+// are created if missing, and columns and constraints that older releases
+// lacked are added to existing tables.
+func migrateBaseline(ctx context.Context, q querier) error {
+	schema := jobsTableDDL("jobs") + `
 
 CREATE TABLE IF NOT EXISTS dlq (
   id TEXT PRIMARY KEY,
@@ -59,7 +163,59 @@ CREATE TABLE IF NOT EXISTS dlq (
   last_error TEXT,
   failed_at TEXT NOT NULL,
   created_at TEXT NOT NULL,
-  updated_at TEXT NOT NULL
+  updated_at TEXT NOT NULL,
+  args TEXT NOT NULL DEFAULT '',
+  env TEXT NOT NULL DEFAULT '',
+  workdir TEXT NOT NULL DEFAULT '',
+  queue TEXT NOT NULL DEFAULT 'default',
+  priority INTEGER NOT NULL DEFAULT 0,
+  timeout_seconds INTEGER NOT NULL DEFAULT 0,
+  type TEXT NOT NULL DEFAULT '',
+  payload TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS job_dependencies (
+  job_id TEXT NOT NULL,
+  depends_on TEXT NOT NULL,
+  PRIMARY KEY (job_id, depends_on)
+);
+
+CREATE TABLE IF NOT EXISTS job_attempts (
+  job_id TEXT NOT NULL,
+  attempt INTEGER NOT NULL,
+  worker_id TEXT NOT NULL DEFAULT '',
+  started_at TEXT NOT NULL,
+  finished_at TEXT NOT NULL DEFAULT '',
+  exit_code INTEGER NOT NULL DEFAULT -1,
+  signal TEXT NOT NULL DEFAULT '',
+  stdout TEXT NOT NULL DEFAULT '',
+  stderr TEXT NOT NULL DEFAULT '',
+  error TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (job_id, attempt)
+);
+
+CREATE TABLE IF NOT EXISTS queues (
+  name TEXT PRIMARY KEY,
+  max_processing INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+  name TEXT PRIMARY KEY,
+  cron TEXT NOT NULL,
+  template TEXT NOT NULL,
+  paused INTEGER NOT NULL DEFAULT 0,
+  catchup TEXT NOT NULL DEFAULT 'one',
+  next_run_at TEXT NOT NULL,
+  last_run_at TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS job_stats_daily (
+  day TEXT NOT NULL,
+  queue TEXT NOT NULL,
+  state TEXT NOT NULL,
+  count INTEGER NOT NULL,
+  PRIMARY KEY (day, queue, state)
 );
 
 CREATE TABLE IF NOT EXISTS config (
@@ -70,7 +226,193 @@ CREATE TABLE IF NOT EXISTS config (
 INSERT OR IGNORE INTO config(key,value) VALUES ('max_retries','3');
 INSERT OR IGNORE INTO config(key,value) VALUES ('backoff_base','2');
 INSERT OR IGNORE INTO config(key,value) VALUES ('backoff_cap_seconds','60');
+INSERT OR IGNORE INTO config(key,value) VALUES ('lease_seconds','30');
+INSERT OR IGNORE INTO config(key,value) VALUES ('output_max_bytes','65536');
+INSERT OR IGNORE INTO config(key,value) VALUES ('job_timeout_seconds','0');
+INSERT OR IGNORE INTO config(key,value) VALUES ('timeout_grace_seconds','10');
+INSERT OR IGNORE INTO config(key,value) VALUES ('dependency_failure','block');
+INSERT OR IGNORE INTO config(key,value) VALUES ('dlq_max_age_days','0');
+INSERT OR IGNORE INTO config(key,value) VALUES ('dlq_max_count','0');
+INSERT OR IGNORE INTO config(key,value) VALUES ('completed_retention','0');
 `
-	_, err := db.Exec(schema)
+	if _, err := q.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	// columns added after the first release; CREATE TABLE IF NOT EXISTS
+	// never touches an existing table, so older databases get them here.
+	columns := []struct{ table, name, ddl string }{
+		{"jobs", "worker_id", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "lease_expires_at", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "timeout_seconds", `INTEGER NOT NULL DEFAULT 0`},
+		{"jobs", "priority", `INTEGER NOT NULL DEFAULT 0`},
+		{"jobs", "queue", `TEXT NOT NULL DEFAULT 'default'`},
+		{"jobs", "args", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "env", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "workdir", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "type", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "payload", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "cancel_requested", `INTEGER NOT NULL DEFAULT 0`},
+		{"jobs", "last_error", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "unique_key", `TEXT NOT NULL DEFAULT ''`},
+		{"jobs", "unique_for", `INTEGER NOT NULL DEFAULT 0`},
+		{"dlq", "args", `TEXT NOT NULL DEFAULT ''`},
+		{"dlq", "env", `TEXT NOT NULL DEFAULT ''`},
+		{"dlq", "workdir", `TEXT NOT NULL DEFAULT ''`},
+		{"dlq", "queue", `TEXT NOT NULL DEFAULT 'default'`},
+		{"dlq", "priority", `INTEGER NOT NULL DEFAULT 0`},
+		{"dlq", "timeout_seconds", `INTEGER NOT NULL DEFAULT 0`},
+		{"dlq", "type", `TEXT NOT NULL DEFAULT ''`},
+		{"dlq", "payload", `TEXT NOT NULL DEFAULT ''`},
+	}
+	for _, c := range columns {
+		if err := ensureColumn(ctx, q, c.table, c.name, c.ddl); err != nil {
+			return err
+		}
+	}
+
+	if err := ensureJobStates(ctx, q); err != nil {
+		return err
+	}
+
+	if err := normalizeTimestamps(ctx, q); err != nil {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, `
+CREATE INDEX IF NOT EXISTS idx_jobs_dependents ON job_dependencies(depends_on);
+CREATE INDEX IF NOT EXISTS idx_jobs_lease ON jobs(state, lease_expires_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs(state, priority DESC, created_at ASC);
+CREATE INDEX IF NOT EXISTS idx_jobs_queue_claim ON jobs(state, queue, priority DESC, created_at ASC);
+CREATE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs(unique_key) WHERE unique_key != '';
+`)
 	return err
 }
+
+// ensureColumn adds table.name when it is missing.
+func ensureColumn(ctx context.Context, q querier, table, name, ddl string) error {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid      int
+			col, typ string
+			notNull  int
+			dflt     sql.NullString
+			pk       int
+		)
+		if err := rows.Scan(&cid, &col, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if col == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = q.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, name, ddl))
+	if err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, name, err)
+	}
+	return nil
+}
+
+// ensureJobStates rebuilds the jobs table when its state CHECK constraint
+// predates jobStates. SQLite cannot alter a constraint in place.
+func ensureJobStates(ctx context.Context, q querier) error {
+	var ddl string
+	err := q.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE type='table' AND name='jobs'`).Scan(&ddl)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(ddl, "("+jobStates+")") {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT name FROM pragma_table_info('jobs')`)
+	if err != nil {
+		return err
+	}
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			rows.Close()
+			return err
+		}
+		cols = append(cols, c)
+	}
+	rows.Close()
+	colList := strings.Join(cols, ", ")
+
+	stmts := []string{
+		`DROP TABLE IF EXISTS jobs_rebuild`,
+		jobsTableDDL("jobs_rebuild"),
+		fmt.Sprintf(`INSERT INTO jobs_rebuild (%s) SELECT %s FROM jobs`, colList, colList),
+		`DROP TABLE jobs`,
+		`ALTER TABLE jobs_rebuild RENAME TO jobs`,
+	}
+	for _, stmt := range stmts {
+		if _, err := q.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("rebuild jobs table: %w", err)
+		}
+	}
+	return nil
+}
+
+// timestampColumns lists every column holding a time, for normalizeTimestamps.
+var timestampColumns = []struct{ table, name string }{
+	{"jobs", "created_at"},
+	{"jobs", "updated_at"},
+	{"jobs", "available_at"},
+	{"jobs", "lease_expires_at"},
+	{"dlq", "failed_at"},
+	{"dlq", "created_at"},
+	{"dlq", "updated_at"},
+	{"job_attempts", "started_at"},
+	{"job_attempts", "finished_at"},
+	{"schedules", "next_run_at"},
+	{"schedules", "last_run_at"},
+	{"schedules", "created_at"},
+}
+
+// normalizeTimestamps rewrites times stored by older versions, which used
+// RFC3339Nano (variable width) or SQLite's datetime('now'), in TimeLayout so
+// they compare correctly as text.
+func normalizeTimestamps(ctx context.Context, q querier) error {
+	width := len(formatTime(time.Time{}))
+	for _, c := range timestampColumns {
+		rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT rowid, %s FROM %s WHERE %s != '' AND length(%s) != ?`,
+			c.name, c.table, c.name, c.name), width)
+		if err != nil {
+			return fmt.Errorf("normalize %s.%s: %w", c.table, c.name, err)
+		}
+		fixed := map[int64]string{}
+		for rows.Next() {
+			var rowid int64
+			var v string
+			if err := rows.Scan(&rowid, &v); err != nil {
+				rows.Close()
+				return err
+			}
+			if t := parseTime(v); !t.IsZero() {
+				fixed[rowid] = formatTime(t)
+			}
+		}
+		rows.Close()
+
+		for rowid, v := range fixed {
+			if _, err := q.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET %s=? WHERE rowid=?`, c.table, c.name), v, rowid); err != nil {
+				return fmt.Errorf("normalize %s.%s: %w", c.table, c.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -2,13 +2,31 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"queuectl/internal/model"
+	"strings"
 	"time"
 )
 
+// dlqColumns selects a DLQ row in the shape scanJob reads, with failed_at
+// where available_at goes; scanDLQJob moves it to FailedAt.
+const dlqColumns = `id, command, args, env, workdir, type, payload, queue, 'dead', attempts, max_retries, priority,
+		       created_at, updated_at, failed_at, timeout_seconds, '', '', 0, COALESCE(last_error, ''), '', 0`
+
+func scanDLQJob(r rowScanner) (*model.Job, error) {
+	j, err := scanJob(r)
+	if err != nil {
+		return nil, err
+	}
+	j.FailedAt, j.AvailableAt = j.AvailableAt, time.Time{}
+	return j, nil
+}
+
+// ListDLQ returns every DLQ job, most recently failed first.
 func (s *Store) ListDLQ(ctx context.Context) ([]model.Job, error) {
 	rows, err := s.DB.QueryContext(ctx, `
-		SELECT id, command, attempts, max_retries, created_at, updated_at
+		SELECT `+dlqColumns+`
 		FROM dlq
 		ORDER BY failed_at DESC
 	`)
@@ -18,45 +36,232 @@ func (s *Store) ListDLQ(ctx context.Context) ([]model.Job, error) {
 	defer rows.Close()
 
 	var jobs []model.Job
-
 	for rows.Next() {
-		var j model.Job
-		var createdAtStr, updatedAtStr string
-
-		err := rows.Scan(
-			&j.ID,
-			&j.Command,
-			&j.Attempts,
-			&j.MaxRetries,
-			&createdAtStr,
-			&updatedAtStr,
-		)
+		j, err := scanDLQJob(rows)
 		if err != nil {
 			return nil, err
 		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
 
-		j.State = "dead"
-		j.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
-		j.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAtStr)
-
-		jobs = append(jobs, j)
+// GetDLQJob returns a job from the DLQ, or ErrNotFound when it is not there.
+func (s *Store) GetDLQJob(ctx context.Context, id string) (*model.Job, error) {
+	j, err := scanDLQJob(s.DB.QueryRowContext(ctx, `SELECT `+dlqColumns+` FROM dlq WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job %s is not in the DLQ: %w", id, ErrNotFound)
 	}
+	return j, err
+}
+
+// DLQFilter selects DLQ jobs for bulk operations. The zero value matches
+// every job.
+type DLQFilter struct {
+	// IDs, when set, limits the filter to these jobs.
+	IDs []string
+	// Command is a GLOB pattern (case sensitive, * matches anything) tried
+	// against the job's command, its args json and its handler type.
+	Command string
+	// Since keeps jobs that failed at or after this time.
+	Since time.Time
+	// Before keeps jobs that failed before this time.
+	Before time.Time
+}
 
-	return jobs, nil
+func (f DLQFilter) where() (string, []any) {
+	var where []string
+	var args []any
+	if len(f.IDs) > 0 {
+		where = append(where, `id IN (?`+strings.Repeat(`, ?`, len(f.IDs)-1)+`)`)
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	if f.Command != "" {
+		where = append(where, `(command GLOB ? OR args GLOB ? OR type GLOB ?)`)
+		args = append(args, f.Command, f.Command, f.Command)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, `failed_at >= ?`)
+		args = append(args, formatTime(f.Since))
+	}
+	if !f.Before.IsZero() {
+		where = append(where, `failed_at < ?`)
+		args = append(args, formatTime(f.Before))
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(where, ` AND `), args
 }
 
+// RetryDLQ moves a job from the DLQ back to the queue with its attempts
+// reset. It returns ErrNotFound when the job is not in the DLQ.
 func (s *Store) RetryDLQ(ctx context.Context, jobID string) error {
-	// Move job back with attempts reset
-	_, err := s.DB.ExecContext(ctx, `
-		INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, available_at)
-		SELECT id, command, 'pending', 0, max_retries, created_at, datetime('now'), datetime('now')
-		FROM dlq WHERE id=?;
-	`, jobID)
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := retryDLQ(ctx, tx, jobID, time.Now().UTC()); err != nil {
 		return err
 	}
+	return tx.Commit()
+}
+
+// RetryDLQWhere moves every DLQ job matching f back to the queue and
+// returns their ids, oldest failure first. It is all or nothing: if one job
+// cannot be retried, none are.
+func (s *Store) RetryDLQWhere(ctx context.Context, f DLQFilter) ([]string, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := f.where()
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM dlq`+where+` ORDER BY failed_at ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if err := retryDLQ(ctx, tx, id, now); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// retryDLQ moves one job back inside tx. The DLQ's last_error is kept on the
+// job; the attempts table already holds the rest of its history. It waits
+// again for parents still queued or in the DLQ; rows for parents that
+// completed or are gone are dropped.
+func retryDLQ(ctx context.Context, tx *sql.Tx, jobID string, now time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM job_dependencies
+		WHERE job_id=?
+		  AND NOT EXISTS (SELECT 1 FROM jobs p WHERE p.id=depends_on AND p.state != 'completed')
+		  AND NOT EXISTS (SELECT 1 FROM dlq q WHERE q.id=depends_on)
+	`, jobID)
+	if err != nil {
+		return fmt.Errorf("retry %s: %w", jobID, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, available_at, last_error, `+dlqPayloadColumns+`)
+		SELECT id, command,
+		       CASE WHEN EXISTS (SELECT 1 FROM job_dependencies WHERE job_id=dlq.id) THEN 'blocked' ELSE 'pending' END,
+		       0, max_retries, created_at, ?, ?, COALESCE(last_error, ''), `+dlqPayloadColumns+`
+		FROM dlq WHERE id=?
+	`, formatTime(now), formatTime(now), jobID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("retry %s: %w", jobID, ErrDuplicateID)
+	}
+	if err != nil {
+		return fmt.Errorf("retry %s: %w", jobID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("job %s is not in the DLQ: %w", jobID, ErrNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dlq WHERE id=?`, jobID); err != nil {
+		return fmt.Errorf("retry %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// PurgeDLQ deletes the given jobs from the DLQ, or every DLQ job when no ids
+// are given, and reports how many were removed.
+func (s *Store) PurgeDLQ(ctx context.Context, ids ...string) (int, error) {
+	return s.PurgeDLQWhere(ctx, DLQFilter{IDs: ids})
+}
+
+// PurgeDLQWhere deletes the DLQ jobs matching f and reports how many were
+// removed.
+func (s *Store) PurgeDLQWhere(ctx context.Context, f DLQFilter) (int, error) {
+	where, args := f.where()
+	return s.purgeDLQ(ctx, `SELECT id FROM dlq`+where, args...)
+}
+
+// purgeDLQ deletes the DLQ jobs whose ids sel returns, with their attempts
+// and the dependencies they were waiting on, so a job enqueued later under
+// the same id starts clean. Jobs still blocked on a purged job could never
+// run, so they move to the DLQ in its place.
+func (s *Store) purgeDLQ(ctx context.Context, sel string, args ...any) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, sel, args...)
+	if err != nil {
+		return 0, fmt.Errorf("purge dlq: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
 
-	// Remove from DLQ
-	_, err = s.DB.ExecContext(ctx, `DELETE FROM dlq WHERE id=?`, jobID)
-	return err
+	now := time.Now().UTC()
+	for _, id := range ids {
+		for _, q := range []string{
+			`DELETE FROM job_attempts WHERE job_id=?`,
+			`DELETE FROM job_dependencies WHERE job_id=?`,
+			`DELETE FROM dlq WHERE id=?`,
+		} {
+			if _, err := tx.ExecContext(ctx, q, id); err != nil {
+				return 0, fmt.Errorf("purge %s: %w", id, err)
+			}
+		}
+		if err := cascadeToDLQ(ctx, tx, id, "was purged", now); err != nil {
+			return 0, fmt.Errorf("purge %s: %w", id, err)
+		}
+	}
+	return len(ids), tx.Commit()
+}
+
+// TrimDLQ applies the DLQ retention policy: it deletes jobs that failed more
+// than maxAge before now, then all but the maxCount most recent failures.
+// A zero maxAge or maxCount leaves that limit off.
+func (s *Store) TrimDLQ(ctx context.Context, now time.Time, maxAge time.Duration, maxCount int) (int, error) {
+	removed := 0
+	if maxAge > 0 {
+		n, err := s.PurgeDLQWhere(ctx, DLQFilter{Before: now.Add(-maxAge)})
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	if maxCount > 0 {
+		n, err := s.purgeDLQ(ctx, `SELECT id FROM dlq ORDER BY failed_at DESC, id DESC LIMIT -1 OFFSET ?`, maxCount)
+		if err != nil {
+			return removed, fmt.Errorf("trim dlq: %w", err)
+		}
+		removed += n
+	}
+	return removed, nil
 }
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	// ErrNotFound is returned when no job, in the queue or the DLQ, has the
+	// requested id.
+	ErrNotFound = errors.New("job not found")
+	// ErrDuplicateID is returned by Enqueue when the id is already taken.
+	ErrDuplicateID = errors.New("job id already exists")
+	// ErrInvalidState is returned when a job's current state does not allow
+	// the operation, such as cancelling a completed job.
+	ErrInvalidState = errors.New("invalid job state")
+	// ErrSchemaTooNew is returned when the database was migrated by a newer
+	// queuectl than this one.
+	ErrSchemaTooNew = errors.New("database schema is newer than this queuectl")
+	// ErrInvalidConfig is returned when setting an unknown config key or a
+	// value its setting does not allow.
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
+// isUniqueViolation reports whether err is SQLite rejecting a duplicate key.
+func isUniqueViolation(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// isBusy reports whether err is SQLite giving up on a lock another
+// connection holds.
+func isBusy(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code()&0xff == sqlite3.SQLITE_BUSY
+}
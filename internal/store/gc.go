@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAge reads a retention period: a Go duration like 12h, or whole days
+// like 30d. Empty and "0" mean no limit and return 0.
+func ParseAge(s string) (time.Duration, error) {
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q is negative", s)
+	}
+	return d, nil
+}
+
+// DeleteFinishedJobs deletes completed and cancelled jobs last updated before
+// cutoff, with their attempts and dependency rows, and reports how many
+// went. Before deleting, their counts are added to job_stats_daily so
+// DailyStats still sees them. A cancelled job that still has blocked
+// children is kept: they are waiting on it, and show why.
+func (s *Store) DeleteFinishedJobs(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	const finished = `state IN ('completed','cancelled') AND updated_at < ?
+		AND NOT EXISTS (
+			SELECT 1 FROM job_dependencies d JOIN jobs c ON c.id = d.job_id
+			WHERE d.depends_on = jobs.id AND c.state = 'blocked'
+		)`
+	c := formatTime(cutoff)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO job_stats_daily (day, queue, state, count)
+		SELECT substr(updated_at, 1, 10), queue, state, COUNT(*)
+		FROM jobs WHERE `+finished+`
+		GROUP BY 1, 2, 3
+		ON CONFLICT (day, queue, state) DO UPDATE SET count = count + excluded.count
+	`, c)
+	if err != nil {
+		return 0, fmt.Errorf("roll up jobs: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM job_attempts WHERE job_id IN (SELECT id FROM jobs WHERE `+finished+`)
+	`, c)
+	if err != nil {
+		return 0, fmt.Errorf("delete attempts: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM job_dependencies
+		WHERE job_id IN (SELECT id FROM jobs WHERE `+finished+`)
+		   OR depends_on IN (SELECT id FROM jobs WHERE `+finished+`)
+	`, c, c)
+	if err != nil {
+		return 0, fmt.Errorf("delete dependencies: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE `+finished, c)
+	if err != nil {
+		return 0, fmt.Errorf("delete jobs: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), tx.Commit()
+}
+
+// DayStats counts the jobs of one queue that finished in a state on a day
+// (UTC, YYYY-MM-DD).
+type DayStats struct {
+	Day   string
+	Queue string
+	State string
+	Count int
+}
+
+// DailyStats counts completed and cancelled jobs per day, queue and state
+// since the given day, oldest first. It adds the rollup of deleted jobs to
+// the ones still in the table, so the numbers do not change when gc runs.
+func (s *Store) DailyStats(ctx context.Context, since time.Time) ([]DayStats, error) {
+	day := since.UTC().Format(time.DateOnly)
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT day, queue, state, SUM(count) FROM (
+			SELECT day, queue, state, count FROM job_stats_daily WHERE day >= ?
+			UNION ALL
+			SELECT substr(updated_at, 1, 10), queue, state, COUNT(*) FROM jobs
+			WHERE state IN ('completed','cancelled') AND substr(updated_at, 1, 10) >= ?
+			GROUP BY 1, 2, 3
+		)
+		GROUP BY day, queue, state
+		ORDER BY day, queue, state
+	`, day, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []DayStats
+	for rows.Next() {
+		var d DayStats
+		if err := rows.Scan(&d.Day, &d.Queue, &d.State, &d.Count); err != nil {
+			return nil, err
+		}
+		stats = append(stats, d)
+	}
+	return stats, rows.Err()
+}
+
+// Compact returns free pages to the filesystem and checkpoints the WAL
+// without waiting on readers. Freed pages are only released on databases
+// created with incremental auto_vacuum; older ones need Vacuum once.
+func (s *Store) Compact(ctx context.Context) error {
+	// incremental_vacuum frees one page per step, so every row has to be
+	// read; Exec would step it only once
+	rows, err := s.DB.QueryContext(ctx, `PRAGMA incremental_vacuum`)
+	if err != nil {
+		return fmt.Errorf("incremental_vacuum: %w", err)
+	}
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("incremental_vacuum: %w", err)
+	}
+	var busy, logFrames, checkpointed int
+	if err := s.DB.QueryRowContext(ctx, `PRAGMA wal_checkpoint(PASSIVE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return fmt.Errorf("wal_checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file, switching it to incremental
+// auto_vacuum so later Compact calls can shrink it. It needs exclusive
+// access for as long as it runs.
+func (s *Store) Vacuum(ctx context.Context) error {
+	if _, err := s.DB.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+		return fmt.Errorf("set auto_vacuum: %w", err)
+	}
+	if _, err := s.DB.ExecContext(ctx, `VACUUM`); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
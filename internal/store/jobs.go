@@ -3,15 +3,151 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"queuectl/internal/model"
+	"queuectl/internal/ulid"
 	"time"
 )
 
+// DefaultLease is how long a claim made through ClaimOne stays valid
+// without a heartbeat.
+const DefaultLease = 30 * time.Second
+
+// ErrLeaseLost is returned when a worker reports on a job it no longer owns,
+// usually because its lease expired and the job was reaped.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// ErrCancelRequested is returned by ExtendLease when someone asked for the
+// job to be cancelled. The lease is still extended so the worker can stop
+// the job and report it with MarkCancelled.
+var ErrCancelRequested = errors.New("job cancel requested")
+
+// ErrPermanent marks a failure that retrying cannot fix. FailRetry moves a
+// job whose error wraps it straight to the DLQ.
+var ErrPermanent = errors.New("permanent failure")
+
+const jobColumns = `id, command, args, env, workdir, type, payload, queue, state, attempts, max_retries, priority,
+		       created_at, updated_at, available_at, timeout_seconds,
+		       worker_id, lease_expires_at, cancel_requested, last_error, unique_key, unique_for`
+
+// dlqPayloadColumns are the job columns the DLQ keeps so a retried job runs
+// exactly as it was enqueued.
+const dlqPayloadColumns = `args, env, workdir, type, payload, queue, priority, timeout_seconds`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(r rowScanner) (*model.Job, error) {
+	var j model.Job
+	var createdAtStr, updatedAtStr, availableAtStr, leaseStr string
+	var argsStr, envStr, payloadStr string
+
+	err := r.Scan(
+		&j.ID,
+		&j.Command,
+		&argsStr,
+		&envStr,
+		&j.Workdir,
+		&j.Type,
+		&payloadStr,
+		&j.Queue,
+		&j.State,
+		&j.Attempts,
+		&j.MaxRetries,
+		&j.Priority,
+		&createdAtStr,
+		&updatedAtStr,
+		&availableAtStr,
+		&j.Timeout,
+		&j.WorkerID,
+		&leaseStr,
+		&j.CancelRequested,
+		&j.LastError,
+		&j.UniqueKey,
+		&j.UniqueFor,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse timestamps
+	j.CreatedAt = parseTime(createdAtStr)
+	j.UpdatedAt = parseTime(updatedAtStr)
+	j.AvailableAt = parseTime(availableAtStr)
+	if leaseStr != "" {
+		j.LeaseExpiresAt = parseTime(leaseStr)
+	}
+	if argsStr != "" {
+		if err := json.Unmarshal([]byte(argsStr), &j.Args); err != nil {
+			return nil, fmt.Errorf("job %s args: %w", j.ID, err)
+		}
+	}
+	if envStr != "" {
+		if err := json.Unmarshal([]byte(envStr), &j.Env); err != nil {
+			return nil, fmt.Errorf("job %s env: %w", j.ID, err)
+		}
+	}
+	if payloadStr != "" {
+		j.Payload = json.RawMessage(payloadStr)
+	}
+	return &j, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Enqueue adds j to the queue. See EnqueueJob for ids and unique keys.
 func (s *Store) Enqueue(ctx context.Context, j model.Job) error {
+	_, err := s.EnqueueJob(ctx, j)
+	return err
+}
+
+// EnqueueResult says which job an enqueue ended up with.
+type EnqueueResult struct {
+	ID string
+	// Deduplicated is set when a job holding the same unique key already
+	// existed; ID is then that job's id and nothing was added.
+	Deduplicated bool
+}
+
+// EnqueueJob adds j to the queue and returns its id, generating a ULID when
+// j.ID is empty. An id already used by a queued or DLQ job returns
+// ErrDuplicateID. A job whose unique key is still held by another job is
+// not added; the result names the existing job instead.
+func (s *Store) EnqueueJob(ctx context.Context, j model.Job) (EnqueueResult, error) {
+	if len(j.DependsOn) == 0 {
+		return s.enqueue(ctx, s.DB, j)
+	}
+
+	// the job row and its dependency rows go in together
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return EnqueueResult{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := s.enqueue(ctx, tx, j)
+	if err != nil {
+		return EnqueueResult{}, err
+	}
+	return res, tx.Commit()
+}
+
+// enqueue fills defaults and inserts j through db, so callers holding a
+// transaction can enqueue as part of it.
+func (s *Store) enqueue(ctx context.Context, db execer, j model.Job) (EnqueueResult, error) {
 	now := time.Now().UTC()
 
+	if j.ID == "" {
+		j.ID = ulid.NewAt(now)
+	}
 	if j.CreatedAt.IsZero() {
 		j.CreatedAt = now
 	}
@@ -24,141 +160,562 @@ func (s *Store) Enqueue(ctx context.Context, j model.Job) error {
 	if j.State == "" {
 		j.State = "pending"
 	}
+	if j.Queue == "" {
+		j.Queue = DefaultQueue
+	}
+	if err := ValidateQueueName(j.Queue); err != nil {
+		return EnqueueResult{}, err
+	}
+
+	// a DLQ job keeps its id so it can be retried
+	var inDLQ bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM dlq WHERE id=?)`, j.ID).Scan(&inDLQ); err != nil {
+		return EnqueueResult{}, fmt.Errorf("enqueue failed: %w", err)
+	}
+	if inDLQ {
+		return EnqueueResult{}, fmt.Errorf("enqueue %s: job is in the DLQ: %w", j.ID, ErrDuplicateID)
+	}
+
+	waiting, err := unmetDependencies(ctx, db, j)
+	if err != nil {
+		return EnqueueResult{}, err
+	}
+	if len(waiting) > 0 && j.State == "pending" {
+		j.State = "blocked"
+	}
 	if j.MaxRetries == 0 {
-		// read from config if needed later, for now default to 3
-		j.MaxRetries = 3
+		j.MaxRetries = s.MustGetInt("max_retries", 3)
+	}
+	if j.Timeout == 0 {
+		j.Timeout = s.MustGetInt("job_timeout_seconds", 0)
 	}
 
-	_, err := s.DB.ExecContext(ctx, `
-INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, available_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-`, j.ID, j.Command, j.State, j.Attempts, j.MaxRetries,
-		j.CreatedAt.Format(time.RFC3339Nano),
-		j.UpdatedAt.Format(time.RFC3339Nano),
-		j.AvailableAt.Format(time.RFC3339Nano),
-	)
+	argsStr, envStr, err := encodePayload(j)
+	if err != nil {
+		return EnqueueResult{}, err
+	}
+
+	// the unique key check and the insert are one statement, so two
+	// enqueues racing for the same key cannot both get in
+	held, heldArgs := uniqueKeyHeld(j, now)
+	args := []any{j.ID, j.Command, argsStr, envStr, j.Workdir, j.Type, string(j.Payload), j.Queue, j.State, j.Attempts, j.MaxRetries, j.Priority,
+		formatTime(j.CreatedAt),
+		formatTime(j.UpdatedAt),
+		formatTime(j.AvailableAt),
+		j.Timeout,
+		j.UniqueKey,
+		j.UniqueFor,
+	}
+	res, err := db.ExecContext(ctx, `
+INSERT INTO jobs (id, command, args, env, workdir, type, payload, queue, state, attempts, max_retries, priority, created_at, updated_at, available_at, timeout_seconds, unique_key, unique_for)
+SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+WHERE NOT EXISTS (SELECT 1 FROM jobs WHERE `+held+`)
+`, append(args, heldArgs...)...)
+
+	if isUniqueViolation(err) {
+		return EnqueueResult{}, fmt.Errorf("enqueue %s: %w", j.ID, ErrDuplicateID)
+	}
+	if err != nil {
+		return EnqueueResult{}, fmt.Errorf("enqueue failed: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var existing string
+		err := db.QueryRowContext(ctx, `SELECT id FROM jobs WHERE `+held+` ORDER BY created_at DESC LIMIT 1`, heldArgs...).Scan(&existing)
+		if err != nil {
+			return EnqueueResult{}, fmt.Errorf("enqueue %s: find job holding unique key %q: %w", j.ID, j.UniqueKey, err)
+		}
+		return EnqueueResult{ID: existing, Deduplicated: true}, nil
+	}
+
+	for _, parent := range waiting {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO job_dependencies (job_id, depends_on) VALUES (?, ?)
+		`, j.ID, parent)
+		if err != nil {
+			return EnqueueResult{}, fmt.Errorf("enqueue dependency: %w", err)
+		}
+	}
+	return EnqueueResult{ID: j.ID}, nil
+}
+
+// uniqueKeyHeld returns the condition matching jobs that hold j's unique
+// key: unfinished ones, or with UniqueFor set, ones created within that
+// window. Without a key it matches nothing.
+func uniqueKeyHeld(j model.Job, now time.Time) (string, []any) {
+	switch {
+	case j.UniqueKey == "":
+		return `0`, nil
+	case j.UniqueFor > 0:
+		since := now.Add(-time.Duration(j.UniqueFor) * time.Second)
+		return `unique_key=? AND created_at >= ?`, []any{j.UniqueKey, formatTime(since)}
+	}
+	return `unique_key=? AND state IN ('pending','blocked','processing')`, []any{j.UniqueKey}
+}
+
+// encodePayload returns the json stored for a job's args and env, or ""
+// when they are empty.
+func encodePayload(j model.Job) (string, string, error) {
+	var argsStr, envStr string
+	if len(j.Args) > 0 {
+		b, err := json.Marshal(j.Args)
+		if err != nil {
+			return "", "", fmt.Errorf("encode args: %w", err)
+		}
+		argsStr = string(b)
+	}
+	if len(j.Env) > 0 {
+		b, err := json.Marshal(j.Env)
+		if err != nil {
+			return "", "", fmt.Errorf("encode env: %w", err)
+		}
+		envStr = string(b)
+	}
+	return argsStr, envStr, nil
+}
+
+// unmetDependencies checks j.DependsOn and returns the parents that have not
+// completed yet. Parents must already exist, in jobs or in the DLQ, which
+// also rules out cycles.
+func unmetDependencies(ctx context.Context, db execer, j model.Job) ([]string, error) {
+	var waiting []string
+	seen := map[string]bool{}
+
+	for _, parent := range j.DependsOn {
+		if parent == j.ID {
+			return nil, fmt.Errorf("job %s cannot depend on itself", j.ID)
+		}
+		if seen[parent] {
+			continue
+		}
+		seen[parent] = true
 
+		var state string
+		err := db.QueryRowContext(ctx, `
+			SELECT state FROM jobs WHERE id=?
+			UNION ALL
+			SELECT 'dead' FROM dlq WHERE id=?
+		`, parent, parent).Scan(&state)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("unknown dependency %q", parent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if state != "completed" {
+			waiting = append(waiting, parent)
+		}
+	}
+	return waiting, nil
+}
+
+// GetJob returns the job with the given id, looking in the DLQ when it is
+// no longer queued. DLQ jobs come back in the "dead" state.
+func (s *Store) GetJob(ctx context.Context, id string) (*model.Job, error) {
+	j, err := scanJob(s.DB.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id=?`, id))
+	if err == nil {
+		return j, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	j, err = s.GetDLQJob(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	return j, err
+}
+
+// CancelJob cancels a job that has not finished. Jobs that have not started
+// are cancelled at once. For a processing job it only records the request
+// and returns true; the owning worker notices on its next heartbeat, stops
+// the job and marks it cancelled. Finished jobs return ErrInvalidState.
+func (s *Store) CancelJob(ctx context.Context, id string, now time.Time) (bool, error) {
+	n, err := s.cancelWhere(ctx, now, `id=? AND state IN ('pending','blocked')`, id)
 	if err != nil {
-		return fmt.Errorf("enqueue failed: %w", err)
+		return false, err
+	}
+	if n == 1 {
+		return false, nil
+	}
+
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE jobs SET cancel_requested=1, updated_at=?
+		WHERE id=? AND state='processing'
+	`, formatTime(now), id)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return true, nil
+	}
+
+	j, err := s.GetJob(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("cannot cancel %s job %s: %w", j.State, id, ErrInvalidState)
+}
+
+// MarkCancelled records that the worker owning j stopped it after a cancel
+// request. The job does not count as failed and is not retried.
+func (s *Store) MarkCancelled(ctx context.Context, j *model.Job, now time.Time) error {
+	n, err := s.cancelWhere(ctx, now, `id=? AND state='processing' AND worker_id=?`, j.ID, j.WorkerID)
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrLeaseLost
 	}
 	return nil
 }
 
-func (s *Store) ClaimOne(ctx context.Context, now time.Time) (*model.Job, error) {
-	// SERIALIZABLE = does the safe row-locking we need in SQLite
-	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+// cancelWhere marks the jobs matching cond cancelled. Their blocked
+// dependents follow dependency_failure as for a dead parent: under cascade
+// they move to the DLQ, under block they stay blocked, waiting on a
+// cancelled parent. It returns how many jobs were cancelled.
+func (s *Store) cancelWhere(ctx context.Context, now time.Time, cond string, args ...any) (int, error) {
+	policy, _ := s.GetConfig(ctx, "dependency_failure")
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("begin tx: %w", err)
+		return 0, fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	var id string
-	err = tx.QueryRowContext(ctx, `
-		SELECT id 
-		FROM jobs
-		WHERE state='pending'
-		  AND available_at <= ?
-		ORDER BY created_at ASC
-		LIMIT 1
-	`, now.Format(time.RFC3339Nano)).Scan(&id)
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE jobs SET state='cancelled', updated_at=?, lease_expires_at=''
+		WHERE `+cond+`
+		RETURNING id
+	`, append([]any{formatTime(now)}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
 
+	if policy == "cascade" {
+		for _, id := range ids {
+			if err := cascadeToDLQ(ctx, tx, id, "was cancelled", now); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(ids), tx.Commit()
+}
+
+// ClaimOne claims the next runnable job from any queue with an anonymous
+// DefaultLease.
+func (s *Store) ClaimOne(ctx context.Context, now time.Time) (*model.Job, error) {
+	return s.Claim(ctx, now, "", DefaultLease, "")
+}
+
+// Claim marks the next runnable job as processing and owned by workerID
+// until now+lease. An empty queue claims from any queue. Queues already at
+// their max_processing limit are skipped.
+//
+// The owner must call ExtendLease before the lease runs out, otherwise
+// ReapExpired hands the job back to the queue.
+func (s *Store) Claim(ctx context.Context, now time.Time, workerID string, lease time.Duration, queue string) (*model.Job, error) {
+	// One statement picks and claims the job. SQLite takes the write lock
+	// before it runs, so concurrent claims wait for each other (up to
+	// busy_timeout) instead of failing when a read transaction cannot be
+	// upgraded.
+	args := []any{formatTime(now), workerID, formatTime(now.Add(lease)), formatTime(now)}
+	queueFilter := ``
+	if queue != "" {
+		queueFilter = `AND queue = ?`
+		args = append(args, queue)
+	}
+	q := `
+		UPDATE jobs
+		SET state='processing', updated_at=?, worker_id=?, lease_expires_at=?
+		WHERE state='pending' AND id = (
+		  SELECT id
+		  FROM jobs j
+		  WHERE state='pending'
+		    AND available_at <= ?
+		    AND NOT EXISTS (
+		      SELECT 1 FROM queues q
+		      WHERE q.name = j.queue
+		        AND q.max_processing > 0
+		        AND q.max_processing <= (
+		          SELECT COUNT(*) FROM jobs p
+		          WHERE p.state='processing' AND p.queue = j.queue))
+		    ` + queueFilter + `
+		  ORDER BY priority DESC, created_at ASC
+		  LIMIT 1)
+		RETURNING ` + jobColumns
+
+	j, err := scanJob(s.DB.QueryRowContext(ctx, q, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil // no job available
 	}
 	if err != nil {
-		return nil, fmt.Errorf("select pending job: %w", err)
+		return nil, fmt.Errorf("claim job: %w", err)
 	}
+	return j, nil
+}
 
-	// Try to mark job as processing — this is the *claim* step
-	res, err := tx.ExecContext(ctx, `
-		UPDATE jobs
-		SET state='processing', updated_at=?
-		WHERE id=? AND state='pending'
-	`, now.Format(time.RFC3339Nano), id)
+// ExtendLease pushes the lease on a processing job out to until. It returns
+// ErrLeaseLost if the job is no longer processing under j.WorkerID, and
+// ErrCancelRequested once the job has been asked to stop.
+func (s *Store) ExtendLease(ctx context.Context, j *model.Job, until time.Time) error {
+	var cancelRequested bool
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE jobs SET lease_expires_at=?
+		WHERE id=? AND state='processing' AND worker_id=?
+		RETURNING cancel_requested
+	`, formatTime(until), j.ID, j.WorkerID).Scan(&cancelRequested)
+	if err == sql.ErrNoRows {
+		return ErrLeaseLost
+	}
 	if err != nil {
-		return nil, fmt.Errorf("claim update: %w", err)
+		return err
 	}
-
-	// If 0 rows were updated -> another worker grabbed it first
-	rows, _ := res.RowsAffected()
-	if rows != 1 {
-		return nil, nil
+	j.LeaseExpiresAt = until
+	if cancelRequested {
+		j.CancelRequested = true
+		return ErrCancelRequested
 	}
+	return nil
+}
 
-	// Load job fields
-	var createdAtStr, updatedAtStr, availableAtStr string
-	j := &model.Job{}
+// Complete marks a job completed and releases any children whose last
+// unmet dependency it was.
+func (s *Store) Complete(ctx context.Context, j *model.Job, now time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
 
-	err = tx.QueryRowContext(ctx, `
-		SELECT id, command, state, attempts, max_retries,
-		       created_at, updated_at, available_at
-		FROM jobs
-		WHERE id=?
-	`, id).Scan(
-		&j.ID,
-		&j.Command,
-		&j.State,
-		&j.Attempts,
-		&j.MaxRetries,
-		&createdAtStr,
-		&updatedAtStr,
-		&availableAtStr,
-	)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE jobs SET state='completed', updated_at=?, lease_expires_at=''
+		WHERE id=? AND state='processing' AND worker_id=?
+	`, formatTime(now), j.ID, j.WorkerID)
 	if err != nil {
-		return nil, fmt.Errorf("reload job after claim: %w", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrLeaseLost
 	}
 
-	// Parse timestamps
-	j.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
-	j.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAtStr)
-	j.AvailableAt, _ = time.Parse(time.RFC3339Nano, availableAtStr)
+	children, err := tx.QueryContext(ctx, `SELECT job_id FROM job_dependencies WHERE depends_on=?`, j.ID)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for children.Next() {
+		var id string
+		if err := children.Scan(&id); err != nil {
+			children.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	children.Close()
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("tx commit: %w", err)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM job_dependencies WHERE depends_on=?`, j.ID); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE jobs SET state='pending', updated_at=?
+			WHERE id=? AND state='blocked'
+			  AND NOT EXISTS (SELECT 1 FROM job_dependencies WHERE job_id=?)
+		`, formatTime(now), id, id)
+		if err != nil {
+			return err
+		}
 	}
 
-	return j, nil
+	return tx.Commit()
 }
 
-func (s *Store) Complete(ctx context.Context, id string, now time.Time) error {
-	_, err := s.DB.ExecContext(ctx, `
-		UPDATE jobs SET state='completed', updated_at=?
-		WHERE id=? AND state='processing'
-	`, now.Format(time.RFC3339Nano), id)
-	return err
+func (s *Store) FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error) {
+	return s.failRetry(ctx, j, now, base, capSeconds, execErr, false)
 }
 
-func (s *Store) FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error) {
+// failRetry records a failed attempt on a job still owned by j.WorkerID.
+// With expiredOnly set the lease must also have run out by now, so a
+// heartbeat that lands while the reaper is working wins.
+func (s *Store) failRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error, expiredOnly bool) (bool, error) {
+	policy, _ := s.GetConfig(ctx, "dependency_failure")
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	owned := `id=? AND state='processing' AND worker_id=?`
+	ownedArgs := []any{j.ID, j.WorkerID}
+	if expiredOnly {
+		owned += ` AND lease_expires_at != '' AND lease_expires_at < ?`
+		ownedArgs = append(ownedArgs, formatTime(now))
+	}
+
 	newAttempts := j.Attempts + 1
-	if newAttempts >= j.MaxRetries {
+	if newAttempts >= j.MaxRetries || errors.Is(execErr, ErrPermanent) {
 		// Move to DLQ
-		_, err := s.DB.ExecContext(ctx, `
-			INSERT INTO dlq(id, command, attempts, max_retries, last_error, failed_at, created_at, updated_at)
-			SELECT id, command, ?, max_retries, ?, ?, created_at, ?
-			FROM jobs WHERE id=?;
-		`, newAttempts, execErr.Error(), now.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano), j.ID)
+		args := append([]any{newAttempts, execErr.Error(), formatTime(now), formatTime(now)}, ownedArgs...)
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO dlq(id, command, attempts, max_retries, last_error, failed_at, created_at, updated_at, `+dlqPayloadColumns+`)
+			SELECT id, command, ?, max_retries, ?, ?, created_at, ?, `+dlqPayloadColumns+`
+			FROM jobs WHERE `+owned, args...)
 		if err != nil {
 			return false, err
 		}
-		_, err = s.DB.ExecContext(ctx, `DELETE FROM jobs WHERE id=?`, j.ID)
-		return true, err
+		if n, _ := res.RowsAffected(); n != 1 {
+			return false, ErrLeaseLost
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE id=?`, j.ID); err != nil {
+			return false, err
+		}
+		if policy == "cascade" {
+			if err := cascadeToDLQ(ctx, tx, j.ID, "failed", now); err != nil {
+				return false, err
+			}
+		}
+		return true, tx.Commit()
 	}
 
-	// Retry with exponential backoff: delay = base^attempts
-	delay := time.Duration(int(math.Pow(float64(base), float64(newAttempts)))) * time.Second
+	available := now.Add(backoff(base, capSeconds, newAttempts))
 
-	capDur := time.Duration(capSeconds) * time.Second
-	if delay > capDur {
-		delay = capDur
+	args := append([]any{newAttempts, formatTime(available), formatTime(now), execErr.Error()}, ownedArgs...)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE jobs
+		SET attempts=?, state='pending', available_at=?, updated_at=?, lease_expires_at='', last_error=?
+		WHERE `+owned, args...)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return false, ErrLeaseLost
 	}
+	return false, tx.Commit()
+}
 
-	available := now.Add(delay)
+// backoff is the delay before retrying a job that has failed attempts
+// times: base^attempts seconds, at most capSeconds. The cap is applied
+// before converting, so a large power cannot overflow into a short delay.
+func backoff(base, capSeconds, attempts int) time.Duration {
+	delay := math.Min(math.Pow(float64(base), float64(attempts)), float64(capSeconds))
+	return time.Duration(delay) * time.Second
+}
 
-	_, err := s.DB.ExecContext(ctx, `
-		UPDATE jobs
-		SET attempts=?, state='pending', available_at=?, updated_at=?
-		WHERE id=? AND state='processing'
-	`, newAttempts, available.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano), j.ID)
+// cascadeToDLQ moves every blocked descendant of a dead, cancelled or
+// purged job to the DLQ; why says what happened to it, for its children's last_error.
+// Their dependency rows stay, so a retried child waits for its parent again
+// if that is still queued or in the DLQ; retryDLQ drops the others.
+func cascadeToDLQ(ctx context.Context, tx *sql.Tx, deadID, why string, now time.Time) error {
+	queue := []string{deadID}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		reason := fmt.Sprintf("dependency %s failed", parent)
+		if parent == deadID {
+			reason = fmt.Sprintf("dependency %s %s", parent, why)
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT j.id FROM job_dependencies d
+			JOIN jobs j ON j.id = d.job_id
+			WHERE d.depends_on=? AND j.state='blocked'
+		`, parent)
+		if err != nil {
+			return err
+		}
+		var children []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			children = append(children, id)
+		}
+		rows.Close()
+
+		for _, child := range children {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO dlq(id, command, attempts, max_retries, last_error, failed_at, created_at, updated_at, `+dlqPayloadColumns+`)
+				SELECT id, command, attempts, max_retries, ?, ?, created_at, ?, `+dlqPayloadColumns+`
+				FROM jobs WHERE id=?
+			`, reason, formatTime(now), formatTime(now), child)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE id=?`, child); err != nil {
+				return err
+			}
+			queue = append(queue, child)
+		}
+	}
+	return nil
+}
+
+// ReapExpired returns processing jobs whose lease ran out before now to the
+// queue, counting the lost run as a failed attempt. It reports how many jobs
+// were reaped.
+func (s *Store) ReapExpired(ctx context.Context, now time.Time, base, capSeconds int) (int, error) {
+	// a job whose worker died after being asked to cancel is done, not failed
+	nCancelled, err := s.cancelWhere(ctx, now, `
+		state='processing' AND cancel_requested=1
+		AND lease_expires_at != '' AND lease_expires_at < ?
+	`, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
 
-	return false, err
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE state='processing'
+		  AND lease_expires_at != ''
+		  AND lease_expires_at < ?
+	`, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []*model.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, j)
+	}
+	rows.Close()
+
+	reaped := nCancelled
+	for _, j := range expired {
+		reason := fmt.Errorf("lease expired: worker %q stopped heartbeating", j.WorkerID)
+		_, err := s.failRetry(ctx, j, now, base, capSeconds, reason, true)
+		if errors.Is(err, ErrLeaseLost) {
+			continue // heartbeat or another reaper got there first
+		}
+		if err != nil {
+			return reaped, err
+		}
+		if err := s.abandonAttempts(ctx, j.ID, now, reason.Error()); err != nil {
+			return reaped, err
+		}
+		reaped++
+	}
+	return reaped, nil
 }
@@ -0,0 +1,964 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"queuectl/internal/model"
+	"queuectl/internal/ulid"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemStore is a Backend that keeps everything in memory, for embedding
+// queuectl in another program and for fast tests. It behaves like *Store
+// except that nothing survives the process and queue limits are not
+// enforced. It is safe for concurrent use; one mutex serialises every
+// operation the way SQLite's write lock does.
+type MemStore struct {
+	mu       sync.Mutex
+	seq      uint64               // insertion order, breaks created_at ties
+	jobs     map[string]*memJob   // the jobs table
+	dlq      map[string]model.Job // as GetDLQJob returns them
+	deps     map[string][]string  // job id -> parents it still waits for
+	attempts map[string][]model.Attempt
+	config   map[string]string // overrides only, like the config table
+	version  int64             // bumped on every config write
+}
+
+type memJob struct {
+	job model.Job
+	seq uint64
+}
+
+// NewMemStore returns an empty in-memory store with every setting at its
+// default.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		jobs:     map[string]*memJob{},
+		dlq:      map[string]model.Job{},
+		deps:     map[string][]string{},
+		attempts: map[string][]model.Attempt{},
+		config:   map[string]string{},
+	}
+}
+
+// cloneJob returns a copy of j sharing no memory with it, shaped the way
+// scanJob reads it back: empty payload fields are nil and DependsOn, which
+// is not stored on the job, is dropped.
+func cloneJob(j model.Job) model.Job {
+	j.Args = slices.Clone(j.Args)
+	if len(j.Args) == 0 {
+		j.Args = nil
+	}
+	j.Env = maps.Clone(j.Env)
+	if len(j.Env) == 0 {
+		j.Env = nil
+	}
+	j.Payload = json.RawMessage(slices.Clone([]byte(j.Payload)))
+	if len(j.Payload) == 0 {
+		j.Payload = nil
+	}
+	j.DependsOn = nil
+	return j
+}
+
+// EnqueueJob adds j to the queue. See (*Store).EnqueueJob.
+func (m *MemStore) EnqueueJob(ctx context.Context, j model.Job) (EnqueueResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.enqueue(j, time.Now().UTC())
+}
+
+// enqueue is EnqueueJob with m.mu held.
+func (m *MemStore) enqueue(j model.Job, now time.Time) (EnqueueResult, error) {
+	if j.ID == "" {
+		j.ID = ulid.NewAt(now)
+	}
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = now
+	}
+	if j.UpdatedAt.IsZero() {
+		j.UpdatedAt = now
+	}
+	if j.AvailableAt.IsZero() {
+		j.AvailableAt = now
+	}
+	j.CreatedAt, j.UpdatedAt, j.AvailableAt = j.CreatedAt.UTC(), j.UpdatedAt.UTC(), j.AvailableAt.UTC()
+	if j.State == "" {
+		j.State = "pending"
+	}
+	if j.Queue == "" {
+		j.Queue = DefaultQueue
+	}
+	if err := ValidateQueueName(j.Queue); err != nil {
+		return EnqueueResult{}, err
+	}
+
+	if _, ok := m.dlq[j.ID]; ok {
+		return EnqueueResult{}, fmt.Errorf("enqueue %s: job is in the DLQ: %w", j.ID, ErrDuplicateID)
+	}
+
+	var waiting []string
+	seen := map[string]bool{}
+	for _, parent := range j.DependsOn {
+		if parent == j.ID {
+			return EnqueueResult{}, fmt.Errorf("job %s cannot depend on itself", j.ID)
+		}
+		if seen[parent] {
+			continue
+		}
+		seen[parent] = true
+
+		state := "dead"
+		if p, ok := m.jobs[parent]; ok {
+			state = p.job.State
+		} else if _, ok := m.dlq[parent]; !ok {
+			return EnqueueResult{}, fmt.Errorf("unknown dependency %q", parent)
+		}
+		if state != "completed" {
+			waiting = append(waiting, parent)
+		}
+	}
+	if len(waiting) > 0 && j.State == "pending" {
+		j.State = "blocked"
+	}
+	if j.MaxRetries == 0 {
+		j.MaxRetries = m.getInt("max_retries", 3)
+	}
+	if j.Timeout == 0 {
+		j.Timeout = m.getInt("job_timeout_seconds", 0)
+	}
+
+	if holder := m.uniqueKeyHolder(j, now); holder != "" {
+		return EnqueueResult{ID: holder, Deduplicated: true}, nil
+	}
+	if _, ok := m.jobs[j.ID]; ok {
+		return EnqueueResult{}, fmt.Errorf("enqueue %s: %w", j.ID, ErrDuplicateID)
+	}
+
+	m.seq++
+	m.jobs[j.ID] = &memJob{job: cloneJob(j), seq: m.seq}
+	if len(waiting) > 0 {
+		m.deps[j.ID] = waiting
+	}
+	return EnqueueResult{ID: j.ID}, nil
+}
+
+// EnqueueBatch adds jobs in order and returns one result per job. See
+// (*Store).EnqueueBatch; here every job is checked under one lock, so a
+// best-effort batch cannot fail part way.
+func (m *MemStore) EnqueueBatch(ctx context.Context, jobs []model.Job, atomic bool) ([]BatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	results := make([]BatchResult, len(jobs))
+	seq := m.seq
+	var added []string
+	for i, j := range jobs {
+		res, err := m.enqueue(j, now)
+		if err != nil {
+			results[i].Err = err
+			if !atomic {
+				continue
+			}
+			// roll back: enqueue only ever adds jobs and their dependencies
+			for _, id := range added {
+				delete(m.jobs, id)
+				delete(m.deps, id)
+			}
+			m.seq = seq
+			for k := range results[:i] {
+				results[k].EnqueueResult = EnqueueResult{}
+			}
+			return results, fmt.Errorf("job %d: %w (nothing was enqueued)", i+1, err)
+		}
+		results[i].EnqueueResult = res
+		if !res.Deduplicated {
+			added = append(added, res.ID)
+		}
+	}
+	return results, nil
+}
+
+// uniqueKeyHolder returns the most recently created job holding j's unique
+// key, or "" when the key is free. See uniqueKeyHeld.
+func (m *MemStore) uniqueKeyHolder(j model.Job, now time.Time) string {
+	if j.UniqueKey == "" {
+		return ""
+	}
+	since := now.Add(-time.Duration(j.UniqueFor) * time.Second)
+	var holder *model.Job
+	for _, mj := range m.jobs {
+		o := &mj.job
+		if o.UniqueKey != j.UniqueKey {
+			continue
+		}
+		if j.UniqueFor > 0 && o.CreatedAt.Before(since) {
+			continue
+		}
+		if j.UniqueFor == 0 && o.State != "pending" && o.State != "blocked" && o.State != "processing" {
+			continue
+		}
+		if holder == nil || o.CreatedAt.After(holder.CreatedAt) {
+			holder = o
+		}
+	}
+	if holder == nil {
+		return ""
+	}
+	return holder.ID
+}
+
+// GetJob returns the job with the given id, looking in the DLQ when it is
+// no longer queued.
+func (m *MemStore) GetJob(ctx context.Context, id string) (*model.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mj, ok := m.jobs[id]; ok {
+		j := cloneJob(mj.job)
+		return &j, nil
+	}
+	if dj, ok := m.dlq[id]; ok {
+		j := cloneJob(dj)
+		return &j, nil
+	}
+	return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+}
+
+// ListJobsFiltered returns the queued jobs matching f, ordered by f.SortBy.
+func (m *MemStore) ListJobsFiltered(ctx context.Context, f JobFilter) ([]model.Job, error) {
+	if f.SortBy != "" && f.SortBy != "created" && f.SortBy != "priority" {
+		return nil, fmt.Errorf("unknown sort %q (use created or priority)", f.SortBy)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	var matched []*memJob
+	for _, mj := range m.jobs {
+		j := &mj.job
+		switch f.State {
+		case "":
+		case StateScheduled:
+			if j.State != "pending" || !j.AvailableAt.After(now) {
+				continue
+			}
+		default:
+			if j.State != f.State {
+				continue
+			}
+		}
+		if f.Queue != "" && j.Queue != f.Queue {
+			continue
+		}
+		if f.MinPriority != nil && j.Priority < *f.MinPriority {
+			continue
+		}
+		matched = append(matched, mj)
+	}
+
+	if f.SortBy == "priority" {
+		sortClaimOrder(matched)
+	} else {
+		sort.Slice(matched, func(a, b int) bool {
+			return createdBefore(matched[a], matched[b])
+		})
+	}
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+
+	var result []model.Job
+	for _, mj := range matched {
+		result = append(result, cloneJob(mj.job))
+	}
+	return result, nil
+}
+
+func createdBefore(a, b *memJob) bool {
+	if !a.job.CreatedAt.Equal(b.job.CreatedAt) {
+		return a.job.CreatedAt.Before(b.job.CreatedAt)
+	}
+	return a.seq < b.seq
+}
+
+// sortClaimOrder sorts jobs the way Claim picks them: highest priority
+// first, then oldest.
+func sortClaimOrder(jobs []*memJob) {
+	sort.Slice(jobs, func(a, b int) bool {
+		if jobs[a].job.Priority != jobs[b].job.Priority {
+			return jobs[a].job.Priority > jobs[b].job.Priority
+		}
+		return createdBefore(jobs[a], jobs[b])
+	})
+}
+
+// BlockedOn maps each blocked job to the parents it is still waiting for,
+// formatted as "id (state)".
+func (m *MemStore) BlockedOn(ctx context.Context) (map[string][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := map[string][]string{}
+	for child, parents := range m.deps {
+		if c, ok := m.jobs[child]; !ok || c.job.State != "blocked" {
+			continue
+		}
+		for _, parent := range slices.Sorted(slices.Values(parents)) {
+			state := "missing"
+			if p, ok := m.jobs[parent]; ok {
+				state = p.job.State
+			} else if _, ok := m.dlq[parent]; ok {
+				state = "dead"
+			}
+			result[child] = append(result[child], fmt.Sprintf("%s (%s)", parent, state))
+		}
+	}
+	return result, nil
+}
+
+// QueueStatus counts jobs per state. "dead" counts the DLQ.
+func (m *MemStore) QueueStatus(ctx context.Context) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := map[string]int{}
+	for _, st := range []string{"pending", "blocked", "processing", "completed", "cancelled"} {
+		stats[st] = 0
+	}
+	for _, mj := range m.jobs {
+		stats[mj.job.State]++
+	}
+	stats["dead"] = len(m.dlq)
+	return stats, nil
+}
+
+// CancelJob cancels a job that has not finished. See (*Store).CancelJob.
+func (m *MemStore) CancelJob(ctx context.Context, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mj, ok := m.jobs[id]
+	if !ok {
+		if _, ok := m.dlq[id]; !ok {
+			return false, fmt.Errorf("job %s: %w", id, ErrNotFound)
+		}
+		return false, fmt.Errorf("cannot cancel dead job %s: %w", id, ErrInvalidState)
+	}
+
+	j := &mj.job
+	switch j.State {
+	case "pending", "blocked":
+		m.cancel(j, now)
+		return false, nil
+	case "processing":
+		j.CancelRequested, j.UpdatedAt = true, now.UTC()
+		return true, nil
+	}
+	return false, fmt.Errorf("cannot cancel %s job %s: %w", j.State, id, ErrInvalidState)
+}
+
+// owned returns the stored job j refers to if it is still processing under
+// j.WorkerID.
+func (m *MemStore) owned(j *model.Job) (*model.Job, bool) {
+	mj, ok := m.jobs[j.ID]
+	if !ok || mj.job.State != "processing" || mj.job.WorkerID != j.WorkerID {
+		return nil, false
+	}
+	return &mj.job, true
+}
+
+// MarkCancelled records that the worker owning j stopped it after a cancel
+// request.
+func (m *MemStore) MarkCancelled(ctx context.Context, j *model.Job, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.owned(j)
+	if !ok {
+		return ErrLeaseLost
+	}
+	m.cancel(stored, now)
+	return nil
+}
+
+// cancel marks a stored job cancelled and applies dependency_failure to its
+// blocked dependents, as for a dead parent. m.mu must be held.
+func (m *MemStore) cancel(j *model.Job, now time.Time) {
+	j.State, j.UpdatedAt, j.LeaseExpiresAt = "cancelled", now.UTC(), time.Time{}
+	if m.getConfig("dependency_failure") == "cascade" {
+		m.cascadeToDLQ(j.ID, "was cancelled", now.UTC())
+	}
+}
+
+// Claim marks the next runnable job as processing and owned by workerID
+// until now+lease. An empty queue claims from any queue.
+func (m *MemStore) Claim(ctx context.Context, now time.Time, workerID string, lease time.Duration, queue string) (*model.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var runnable []*memJob
+	for _, mj := range m.jobs {
+		if mj.job.State != "pending" || mj.job.AvailableAt.After(now) {
+			continue
+		}
+		if queue != "" && mj.job.Queue != queue {
+			continue
+		}
+		runnable = append(runnable, mj)
+	}
+	if len(runnable) == 0 {
+		return nil, nil
+	}
+	sortClaimOrder(runnable)
+
+	j := &runnable[0].job
+	j.State, j.UpdatedAt = "processing", now.UTC()
+	j.WorkerID, j.LeaseExpiresAt = workerID, now.Add(lease).UTC()
+	claimed := cloneJob(*j)
+	return &claimed, nil
+}
+
+// ExtendLease pushes the lease on a processing job out to until. See
+// (*Store).ExtendLease.
+func (m *MemStore) ExtendLease(ctx context.Context, j *model.Job, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.owned(j)
+	if !ok {
+		return ErrLeaseLost
+	}
+	stored.LeaseExpiresAt = until.UTC()
+	j.LeaseExpiresAt = until
+	if stored.CancelRequested {
+		j.CancelRequested = true
+		return ErrCancelRequested
+	}
+	return nil
+}
+
+// Complete marks a job completed and releases any children whose last
+// unmet dependency it was.
+func (m *MemStore) Complete(ctx context.Context, j *model.Job, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.owned(j)
+	if !ok {
+		return ErrLeaseLost
+	}
+	stored.State, stored.UpdatedAt, stored.LeaseExpiresAt = "completed", now.UTC(), time.Time{}
+
+	for child, parents := range m.deps {
+		i := slices.Index(parents, j.ID)
+		if i < 0 {
+			continue
+		}
+		parents = slices.Delete(parents, i, i+1)
+		if len(parents) > 0 {
+			m.deps[child] = parents
+			continue
+		}
+		delete(m.deps, child)
+		if c, ok := m.jobs[child]; ok && c.job.State == "blocked" {
+			c.job.State, c.job.UpdatedAt = "pending", now.UTC()
+		}
+	}
+	return nil
+}
+
+// FailRetry records a failed attempt, scheduling a retry with backoff or
+// moving the job to the DLQ. See (*Store).FailRetry.
+func (m *MemStore) FailRetry(ctx context.Context, j *model.Job, now time.Time, base, capSeconds int, execErr error) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failRetry(j, now, base, capSeconds, execErr, false)
+}
+
+// failRetry is FailRetry with m.mu held. With expiredOnly set the lease must
+// also have run out by now.
+func (m *MemStore) failRetry(j *model.Job, now time.Time, base, capSeconds int, execErr error, expiredOnly bool) (bool, error) {
+	stored, ok := m.owned(j)
+	if !ok || expiredOnly && (stored.LeaseExpiresAt.IsZero() || !stored.LeaseExpiresAt.Before(now)) {
+		return false, ErrLeaseLost
+	}
+	now = now.UTC()
+
+	newAttempts := j.Attempts + 1
+	if newAttempts >= j.MaxRetries || errors.Is(execErr, ErrPermanent) {
+		m.moveToDLQ(stored, newAttempts, execErr.Error(), now)
+		if m.getConfig("dependency_failure") == "cascade" {
+			m.cascadeToDLQ(j.ID, "failed", now)
+		}
+		return true, nil
+	}
+
+	stored.Attempts, stored.State = newAttempts, "pending"
+	stored.AvailableAt = now.Add(backoff(base, capSeconds, newAttempts))
+	stored.UpdatedAt, stored.LeaseExpiresAt, stored.LastError = now, time.Time{}, execErr.Error()
+	return false, nil
+}
+
+// moveToDLQ replaces a queued job with its DLQ entry, keeping only what the
+// DLQ table stores.
+func (m *MemStore) moveToDLQ(j *model.Job, attempts int, lastError string, now time.Time) {
+	dead := cloneJob(*j)
+	dead.State, dead.Attempts, dead.LastError = "dead", attempts, lastError
+	dead.FailedAt, dead.UpdatedAt, dead.AvailableAt = now, now, time.Time{}
+	dead.WorkerID, dead.LeaseExpiresAt, dead.CancelRequested = "", time.Time{}, false
+	dead.UniqueKey, dead.UniqueFor = "", 0
+	delete(m.jobs, j.ID)
+	m.dlq[dead.ID] = dead
+}
+
+// cascadeToDLQ moves every blocked descendant of a dead or cancelled job to
+// the DLQ. See cascadeToDLQ in jobs.go.
+func (m *MemStore) cascadeToDLQ(deadID, why string, now time.Time) {
+	queue := []string{deadID}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		reason := fmt.Sprintf("dependency %s failed", parent)
+		if parent == deadID {
+			reason = fmt.Sprintf("dependency %s %s", parent, why)
+		}
+
+		var children []string
+		for child, parents := range m.deps {
+			if c, ok := m.jobs[child]; ok && c.job.State == "blocked" && slices.Contains(parents, parent) {
+				children = append(children, child)
+			}
+		}
+		sort.Strings(children)
+		for _, child := range children {
+			c := &m.jobs[child].job
+			m.moveToDLQ(c, c.Attempts, reason, now)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// ReapExpired returns processing jobs whose lease ran out before now to the
+// queue. See (*Store).ReapExpired.
+func (m *MemStore) ReapExpired(ctx context.Context, now time.Time, base, capSeconds int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := func(j *model.Job) bool {
+		return j.State == "processing" && !j.LeaseExpiresAt.IsZero() && j.LeaseExpiresAt.Before(now)
+	}
+
+	reaped := 0
+	var lost []model.Job
+	for _, mj := range m.jobs {
+		j := &mj.job
+		if !expired(j) {
+			continue
+		}
+		if j.CancelRequested {
+			m.cancel(j, now)
+			reaped++
+			continue
+		}
+		lost = append(lost, cloneJob(*j))
+	}
+
+	for _, j := range lost {
+		reason := fmt.Errorf("lease expired: worker %q stopped heartbeating", j.WorkerID)
+		if _, err := m.failRetry(&j, now, base, capSeconds, reason, true); err != nil {
+			return reaped, err
+		}
+		m.abandonAttempts(j.ID, now, reason.Error())
+		reaped++
+	}
+	return reaped, nil
+}
+
+// StartAttempt opens a new attempt for a claimed job and returns it.
+func (m *MemStore) StartAttempt(ctx context.Context, j *model.Job, now time.Time) (*model.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 1
+	if prev := m.attempts[j.ID]; len(prev) > 0 {
+		n = prev[len(prev)-1].Attempt + 1
+	}
+	m.attempts[j.ID] = append(m.attempts[j.ID], model.Attempt{
+		JobID:     j.ID,
+		Attempt:   n,
+		WorkerID:  j.WorkerID,
+		StartedAt: now.UTC(),
+		ExitCode:  -1,
+	})
+
+	return &model.Attempt{
+		JobID:     j.ID,
+		Attempt:   n,
+		WorkerID:  j.WorkerID,
+		StartedAt: now,
+		ExitCode:  -1,
+	}, nil
+}
+
+// attempt returns the stored attempt a refers to, or nil.
+func (m *MemStore) attempt(a *model.Attempt) *model.Attempt {
+	for i := range m.attempts[a.JobID] {
+		if m.attempts[a.JobID][i].Attempt == a.Attempt {
+			return &m.attempts[a.JobID][i]
+		}
+	}
+	return nil
+}
+
+// UpdateAttemptOutput stores the output captured so far for a running attempt.
+func (m *MemStore) UpdateAttemptOutput(ctx context.Context, a *model.Attempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored := m.attempt(a); stored != nil && !stored.Finished() {
+		stored.Stdout, stored.Stderr = a.Stdout, a.Stderr
+	}
+	return nil
+}
+
+// FinishAttempt records the outcome of an attempt.
+func (m *MemStore) FinishAttempt(ctx context.Context, a *model.Attempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored := m.attempt(a); stored != nil {
+		stored.FinishedAt, stored.ExitCode, stored.Signal = a.FinishedAt.UTC(), a.ExitCode, a.Signal
+		stored.Stdout, stored.Stderr, stored.Error = a.Stdout, a.Stderr, a.Error
+	}
+	return nil
+}
+
+// abandonAttempts closes attempts of a job that will never report back.
+func (m *MemStore) abandonAttempts(jobID string, now time.Time, reason string) {
+	for i := range m.attempts[jobID] {
+		a := &m.attempts[jobID][i]
+		if !a.Finished() {
+			a.FinishedAt, a.Error = now.UTC(), reason
+		}
+	}
+}
+
+// GetAttempt returns attempt n of a job, or the latest one when n is 0.
+// It returns nil if there is no such attempt.
+func (m *MemStore) GetAttempt(ctx context.Context, jobID string, n int) (*model.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts := m.attempts[jobID]
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+	if n == 0 {
+		a := attempts[len(attempts)-1]
+		return &a, nil
+	}
+	if stored := m.attempt(&model.Attempt{JobID: jobID, Attempt: n}); stored != nil {
+		a := *stored
+		return &a, nil
+	}
+	return nil, nil
+}
+
+// ListAttempts returns every recorded attempt of a job, oldest first.
+func (m *MemStore) ListAttempts(ctx context.Context, jobID string) ([]model.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.attempts[jobID]), nil
+}
+
+// ListDLQ returns every DLQ job, most recently failed first.
+func (m *MemStore) ListDLQ(ctx context.Context) ([]model.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []model.Job
+	for _, j := range m.dlq {
+		jobs = append(jobs, cloneJob(j))
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		if !jobs[a].FailedAt.Equal(jobs[b].FailedAt) {
+			return jobs[a].FailedAt.After(jobs[b].FailedAt)
+		}
+		return jobs[a].ID > jobs[b].ID
+	})
+	return jobs, nil
+}
+
+// GetDLQJob returns a job from the DLQ, or ErrNotFound when it is not there.
+func (m *MemStore) GetDLQJob(ctx context.Context, id string) (*model.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dj, ok := m.dlq[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s is not in the DLQ: %w", id, ErrNotFound)
+	}
+	j := cloneJob(dj)
+	return &j, nil
+}
+
+// RetryDLQ moves a job from the DLQ back to the queue with its attempts
+// reset. See (*Store).RetryDLQ.
+func (m *MemStore) RetryDLQ(ctx context.Context, jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRetry(jobID); err != nil {
+		return err
+	}
+	m.retryDLQ(jobID, time.Now().UTC())
+	return nil
+}
+
+// RetryDLQWhere moves every DLQ job matching f back to the queue and
+// returns their ids, oldest failure first. If one cannot be retried, none
+// are.
+func (m *MemStore) RetryDLQWhere(ctx context.Context, f DLQFilter) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.matchDLQ(f)
+	for _, id := range ids {
+		if err := m.checkRetry(id); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now().UTC()
+	for _, id := range ids {
+		m.retryDLQ(id, now)
+	}
+	return ids, nil
+}
+
+// checkRetry reports why jobID cannot be moved back to the queue.
+func (m *MemStore) checkRetry(jobID string) error {
+	if _, ok := m.dlq[jobID]; !ok {
+		return fmt.Errorf("job %s is not in the DLQ: %w", jobID, ErrNotFound)
+	}
+	if _, ok := m.jobs[jobID]; ok {
+		return fmt.Errorf("retry %s: %w", jobID, ErrDuplicateID)
+	}
+	return nil
+}
+
+// retryDLQ moves a job that passed checkRetry back to the queue.
+func (m *MemStore) retryDLQ(jobID string, now time.Time) {
+	j := cloneJob(m.dlq[jobID])
+	j.State, j.Attempts = "pending", 0
+
+	// wait again only for parents still queued or in the DLQ
+	var waiting []string
+	for _, parent := range m.deps[jobID] {
+		p, queued := m.jobs[parent]
+		_, dead := m.dlq[parent]
+		if queued && p.job.State != "completed" || dead {
+			waiting = append(waiting, parent)
+		}
+	}
+	if len(waiting) > 0 {
+		m.deps[jobID] = waiting
+		j.State = "blocked"
+	} else {
+		delete(m.deps, jobID)
+	}
+	j.UpdatedAt, j.AvailableAt, j.FailedAt = now, now, time.Time{}
+
+	delete(m.dlq, jobID)
+	m.seq++
+	m.jobs[jobID] = &memJob{job: j, seq: m.seq}
+}
+
+// PurgeDLQ deletes the given jobs from the DLQ, or every DLQ job when no ids
+// are given, and reports how many were removed.
+func (m *MemStore) PurgeDLQ(ctx context.Context, ids ...string) (int, error) {
+	return m.PurgeDLQWhere(ctx, DLQFilter{IDs: ids})
+}
+
+// PurgeDLQWhere deletes the DLQ jobs matching f and reports how many were
+// removed. See (*Store).PurgeDLQWhere.
+func (m *MemStore) PurgeDLQWhere(ctx context.Context, f DLQFilter) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.matchDLQ(f)
+	now := time.Now().UTC()
+	for _, id := range ids {
+		delete(m.dlq, id)
+		delete(m.attempts, id)
+		delete(m.deps, id)
+		m.cascadeToDLQ(id, "was purged", now)
+	}
+	return len(ids), nil
+}
+
+// matchDLQ returns the ids of the DLQ jobs matching f, oldest failure first.
+func (m *MemStore) matchDLQ(f DLQFilter) []string {
+	var matched []model.Job
+	for id, j := range m.dlq {
+		if len(f.IDs) > 0 && !slices.Contains(f.IDs, id) {
+			continue
+		}
+		if f.Command != "" && !dlqCommandMatches(f.Command, j) {
+			continue
+		}
+		if !f.Since.IsZero() && j.FailedAt.Before(f.Since) {
+			continue
+		}
+		if !f.Before.IsZero() && !j.FailedAt.Before(f.Before) {
+			continue
+		}
+		matched = append(matched, j)
+	}
+	sort.Slice(matched, func(a, b int) bool {
+		if !matched[a].FailedAt.Equal(matched[b].FailedAt) {
+			return matched[a].FailedAt.Before(matched[b].FailedAt)
+		}
+		return matched[a].ID < matched[b].ID
+	})
+
+	ids := make([]string, len(matched))
+	for i, j := range matched {
+		ids[i] = j.ID
+	}
+	return ids
+}
+
+// dlqCommandMatches applies DLQFilter.Command the way SQLite's GLOB does:
+// to the command, the args as stored (json) and the handler type.
+func dlqCommandMatches(pattern string, j model.Job) bool {
+	var args string
+	if len(j.Args) > 0 {
+		b, _ := json.Marshal(j.Args)
+		args = string(b)
+	}
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(j.Command) || re.MatchString(args) || re.MatchString(j.Type)
+}
+
+// globToRegexp translates a GLOB pattern: * and ? match any text and any
+// one character, including slashes, and [...] classes are kept.
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(pattern[i : i+end+2])
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`$`)
+	return b.String()
+}
+
+// Close releases nothing; a MemStore lives as long as it is referenced.
+func (m *MemStore) Close() error {
+	return nil
+}
+
+// SetConfig validates value against the key's setting and stores it.
+func (m *MemStore) SetConfig(ctx context.Context, key, value string) error {
+	value, err := ValidateConfig(key, value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config[key] = value
+	m.version++
+	return nil
+}
+
+// UnsetConfig removes the value set for key. See (*Store).UnsetConfig.
+func (m *MemStore) UnsetConfig(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, set := m.config[key]
+	if _, known := LookupSetting(key); !known && !set {
+		return fmt.Errorf("unknown config key %q: %w", key, ErrInvalidConfig)
+	}
+	if set {
+		delete(m.config, key)
+		m.version++
+	}
+	return nil
+}
+
+// GetConfig returns the value set for key, or the setting's default.
+func (m *MemStore) GetConfig(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getConfig(key), nil
+}
+
+// getConfig is GetConfig with m.mu held.
+func (m *MemStore) getConfig(key string) string {
+	if v, ok := m.config[key]; ok {
+		return v
+	}
+	s, _ := LookupSetting(key)
+	return s.Default
+}
+
+// ConfigOverrides returns the values that have been set.
+func (m *MemStore) ConfigOverrides(ctx context.Context) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.config), nil
+}
+
+// AllConfig returns the value of every setting, default or set.
+func (m *MemStore) AllConfig(ctx context.Context) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return effectiveConfig(m.config), nil
+}
+
+// ConfigVersion returns a counter that moves on every config change.
+func (m *MemStore) ConfigVersion(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version, nil
+}
+
+func (m *MemStore) MustGetInt(key string, defaultVal int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getInt(key, defaultVal)
+}
+
+// getInt is MustGetInt with m.mu held.
+func (m *MemStore) getInt(key string, defaultVal int) int {
+	return configInt(key, m.config[key], defaultVal)
+}
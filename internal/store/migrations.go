@@ -0,0 +1,338 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// querier is what a migration runs against: the connection holding the
+// migration lock, inside the migration's transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// migration is one versioned schema change. Down is nil when the change
+// cannot be reverted.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, q querier) error
+	Down    func(ctx context.Context, q querier) error
+}
+
+// migrations is the schema history, in order, with no gaps. Append new
+// migrations; never edit or renumber one that has been released.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      migrateBaseline,
+	},
+	{
+		Version: 2,
+		Name:    "retention indexes",
+		Up: func(ctx context.Context, q querier) error {
+			_, err := q.ExecContext(ctx, `
+CREATE INDEX IF NOT EXISTS idx_dlq_failed_at ON dlq(failed_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_finished ON jobs(state, updated_at);
+`)
+			return err
+		},
+		Down: func(ctx context.Context, q querier) error {
+			_, err := q.ExecContext(ctx, `
+DROP INDEX IF EXISTS idx_dlq_failed_at;
+DROP INDEX IF EXISTS idx_jobs_finished;
+`)
+			return err
+		},
+	},
+	{
+		Version: 3,
+		Name:    "config overrides",
+		// defaults now come from the settings registry, so the rows the
+		// baseline seeded only hide whether a value was ever changed
+		Up: func(ctx context.Context, q querier) error {
+			for _, kv := range seededConfig {
+				if _, err := q.ExecContext(ctx, `DELETE FROM config WHERE key=? AND value=?`, kv[0], kv[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, q querier) error {
+			for _, kv := range seededConfig {
+				if _, err := q.ExecContext(ctx, `INSERT OR IGNORE INTO config(key, value) VALUES (?, ?)`, kv[0], kv[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "config version",
+		// every write to config bumps the counter, so running workers can
+		// notice a change with one cheap read
+		Up: func(ctx context.Context, q querier) error {
+			_, err := q.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS config_version (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  version INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO config_version (id, version) VALUES (1, 0);
+CREATE TRIGGER IF NOT EXISTS config_version_insert AFTER INSERT ON config
+BEGIN UPDATE config_version SET version = version + 1; END;
+CREATE TRIGGER IF NOT EXISTS config_version_update AFTER UPDATE ON config
+BEGIN UPDATE config_version SET version = version + 1; END;
+CREATE TRIGGER IF NOT EXISTS config_version_delete AFTER DELETE ON config
+BEGIN UPDATE config_version SET version = version + 1; END;
+`)
+			return err
+		},
+		Down: func(ctx context.Context, q querier) error {
+			_, err := q.ExecContext(ctx, `
+DROP TRIGGER IF EXISTS config_version_insert;
+DROP TRIGGER IF EXISTS config_version_update;
+DROP TRIGGER IF EXISTS config_version_delete;
+DROP TABLE IF EXISTS config_version;
+`)
+			return err
+		},
+	},
+}
+
+// seededConfig is the config the baseline migration inserts.
+var seededConfig = [][2]string{
+	{"max_retries", "3"},
+	{"backoff_base", "2"},
+	{"backoff_cap_seconds", "60"},
+	{"lease_seconds", "30"},
+	{"output_max_bytes", "65536"},
+	{"job_timeout_seconds", "0"},
+	{"timeout_grace_seconds", "10"},
+	{"dependency_failure", "block"},
+	{"dlq_max_age_days", "0"},
+	{"dlq_max_count", "0"},
+	{"completed_retention", "0"},
+}
+
+// migrationLockWait bounds how long a process waits for another one to
+// finish migrating the same database.
+const migrationLockWait = 2 * time.Minute
+
+// LatestSchemaVersion is the schema version this queuectl migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus describes one migration, known to this queuectl or found
+// applied in the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Reversible is false for migrations `migrate down` cannot undo, and
+	// for ones only a newer queuectl knows about.
+	Reversible bool
+}
+
+// SchemaVersion returns the database's schema version, 0 for a new database
+// or one created before migrations were versioned.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, s.DB)
+}
+
+func schemaVersion(ctx context.Context, q querier) (int, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type='table' AND name='schema_migrations')
+	`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var v int
+	err = q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// Migrations lists every known migration and every applied one, by version.
+func (s *Store) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	applied := map[int]MigrationStatus{}
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 {
+		rows, err := s.DB.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m MigrationStatus
+			var at string
+			if err := rows.Scan(&m.Version, &m.Name, &at); err != nil {
+				return nil, err
+			}
+			m.Applied, m.AppliedAt = true, parseTime(at)
+			applied[m.Version] = m
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var out []MigrationStatus
+	for _, m := range migrations {
+		st := applied[m.Version]
+		delete(applied, m.Version)
+		st.Version, st.Name, st.Reversible = m.Version, m.Name, m.Down != nil
+		out = append(out, st)
+	}
+	// applied by a newer queuectl
+	for v := LatestSchemaVersion() + 1; len(applied) > 0; v++ {
+		if m, ok := applied[v]; ok {
+			out = append(out, m)
+			delete(applied, v)
+		}
+	}
+	return out, nil
+}
+
+// Migrate brings the database up to LatestSchemaVersion. It is cheap when
+// the database is already current, and safe when several processes start
+// at once: one migrates while the others wait for it.
+func (s *Store) Migrate(ctx context.Context) error {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return tooNew(version)
+	}
+	if version == LatestSchemaVersion() {
+		return nil
+	}
+	return s.MigrateUp(ctx, LatestSchemaVersion())
+}
+
+// MigrateUp applies migrations until the database is at version target.
+func (s *Store) MigrateUp(ctx context.Context, target int) error {
+	if target < 1 || target > LatestSchemaVersion() {
+		return fmt.Errorf("no schema version %d (latest is %d)", target, LatestSchemaVersion())
+	}
+	return s.migrate(ctx, func(ctx context.Context, q querier, version int) (bool, error) {
+		if version > LatestSchemaVersion() {
+			return false, tooNew(version)
+		}
+		if version > target {
+			return false, fmt.Errorf("database is at version %d, use migrate down to go back to %d", version, target)
+		}
+		if version == target {
+			return false, nil
+		}
+		m := migrations[version] // versions start at 1 with no gaps
+		if err := m.Up(ctx, q); err != nil {
+			return false, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		_, err := q.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, formatTime(time.Now().UTC()))
+		return true, err
+	})
+}
+
+// MigrateDown reverts migrations until the database is at version target.
+// It stops with an error at a migration that cannot be reverted, leaving
+// the ones already reverted undone.
+func (s *Store) MigrateDown(ctx context.Context, target int) error {
+	if target < 0 {
+		return fmt.Errorf("no schema version %d", target)
+	}
+	return s.migrate(ctx, func(ctx context.Context, q querier, version int) (bool, error) {
+		if version > LatestSchemaVersion() {
+			return false, tooNew(version)
+		}
+		if version < target {
+			return false, fmt.Errorf("database is at version %d, use migrate up to go to %d", version, target)
+		}
+		if version == target {
+			return false, nil
+		}
+		m := migrations[version-1]
+		if m.Down == nil {
+			return false, fmt.Errorf("migration %d (%s) cannot be reverted", m.Version, m.Name)
+		}
+		if err := m.Down(ctx, q); err != nil {
+			return false, fmt.Errorf("revert migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		_, err := q.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version=?`, m.Version)
+		return true, err
+	})
+}
+
+// migrate calls step once per transaction with the current version until
+// it reports nothing left to do. Each transaction holds SQLite's write lock
+// from the start (BEGIN IMMEDIATE), so the version step sees cannot change
+// under it and concurrent migrators take turns.
+func (s *Store) migrate(ctx context.Context, step func(ctx context.Context, q querier, version int) (bool, error)) error {
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		if err := beginImmediate(ctx, conn); err != nil {
+			return fmt.Errorf("lock database for migration: %w", err)
+		}
+		more, err := func() (bool, error) {
+			_, err := conn.ExecContext(ctx, `
+				CREATE TABLE IF NOT EXISTS schema_migrations (
+				  version INTEGER PRIMARY KEY,
+				  name TEXT NOT NULL,
+				  applied_at TEXT NOT NULL
+				)`)
+			if err != nil {
+				return false, err
+			}
+			version, err := schemaVersion(ctx, conn)
+			if err != nil {
+				return false, err
+			}
+			return step(ctx, conn, version)
+		}()
+		if err != nil {
+			conn.ExecContext(context.Background(), `ROLLBACK`)
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+			conn.ExecContext(context.Background(), `ROLLBACK`)
+			return fmt.Errorf("commit migration: %w", err)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// beginImmediate starts a write transaction on conn, waiting up to
+// migrationLockWait for another migrator to finish.
+func beginImmediate(ctx context.Context, conn *sql.Conn) error {
+	deadline := time.Now().Add(migrationLockWait)
+	for {
+		_, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`)
+		if err == nil || !isBusy(err) || time.Now().After(deadline) {
+			return err
+		}
+	}
+}
+
+func tooNew(version int) error {
+	return fmt.Errorf("database is at schema version %d but this queuectl only knows up to %d, upgrade queuectl: %w",
+		version, LatestSchemaVersion(), ErrSchemaTooNew)
+}
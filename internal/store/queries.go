@@ -2,21 +2,71 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"queuectl/internal/model"
+	"strings"
 	"time"
 )
 
+// StateScheduled is a virtual state for JobFilter: pending jobs whose
+// available_at is still in the future.
+const StateScheduled = "scheduled"
+
+// JobFilter narrows and orders ListJobsFiltered results.
+type JobFilter struct {
+	State       string
+	Queue       string
+	MinPriority *int
+	// SortBy is "created" (default, oldest first) or "priority" (claim order).
+	SortBy string
+	// Limit caps how many jobs are returned; 0 returns all of them.
+	Limit int
+}
+
 func (s *Store) ListJobs(ctx context.Context, state string) ([]model.Job, error) {
+	return s.ListJobsFiltered(ctx, JobFilter{State: state})
+}
+
+func (s *Store) ListJobsFiltered(ctx context.Context, f JobFilter) ([]model.Job, error) {
 	q := `
-		SELECT id, command, state, attempts, max_retries,
-		       created_at, updated_at, available_at
+		SELECT ` + jobColumns + `
 		FROM jobs
 	`
+	var where []string
 	args := []any{}
 
-	if state != "" {
-		q += " WHERE state = ?"
-		args = append(args, state)
+	switch f.State {
+	case "":
+	case StateScheduled:
+		where = append(where, "state = 'pending' AND available_at > ?")
+		args = append(args, formatTime(time.Now().UTC()))
+	default:
+		where = append(where, "state = ?")
+		args = append(args, f.State)
+	}
+	if f.Queue != "" {
+		where = append(where, "queue = ?")
+		args = append(args, f.Queue)
+	}
+	if f.MinPriority != nil {
+		where = append(where, "priority >= ?")
+		args = append(args, *f.MinPriority)
+	}
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	switch f.SortBy {
+	case "", "created":
+		q += " ORDER BY created_at ASC"
+	case "priority":
+		q += " ORDER BY priority DESC, created_at ASC"
+	default:
+		return nil, fmt.Errorf("unknown sort %q (use created or priority)", f.SortBy)
+	}
+	if f.Limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, f.Limit)
 	}
 
 	rows, err := s.DB.QueryContext(ctx, q, args...)
@@ -27,36 +77,68 @@ func (s *Store) ListJobs(ctx context.Context, state string) ([]model.Job, error)
 
 	var result []model.Job
 	for rows.Next() {
-		var j model.Job
-		var createdAtStr, updatedAtStr, availableAtStr string
-
-		if err := rows.Scan(
-			&j.ID, &j.Command, &j.State, &j.Attempts, &j.MaxRetries,
-			&createdAtStr, &updatedAtStr, &availableAtStr,
-		); err != nil {
+		j, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		j.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
-		j.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updatedAtStr)
-		j.AvailableAt, _ = time.Parse(time.RFC3339Nano, availableAtStr)
-
-		result = append(result, j)
+		result = append(result, *j)
 	}
 	return result, nil
 }
 
+// QueueStatus counts jobs per state in one pass. "dead" counts the DLQ.
+// States with no jobs are reported as 0.
 func (s *Store) QueueStatus(ctx context.Context) (map[string]int, error) {
 	stats := map[string]int{}
-	states := []string{"pending", "processing", "completed", "dead"}
+	for _, st := range []string{"pending", "blocked", "processing", "completed", "cancelled"} {
+		stats[st] = 0
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT state, COUNT(*) FROM jobs GROUP BY state
+		UNION ALL
+		SELECT 'dead', COUNT(*) FROM dlq
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	for _, st := range states {
+	for rows.Next() {
+		var state string
 		var count int
-		err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs WHERE state=?`, st).Scan(&count)
-		if err != nil {
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		stats[state] += count
+	}
+	return stats, rows.Err()
+}
+
+// BlockedOn maps each blocked job to the parents it is still waiting for,
+// formatted as "id (state)".
+func (s *Store) BlockedOn(ctx context.Context) (map[string][]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT d.job_id, d.depends_on,
+		       COALESCE(p.state, CASE WHEN q.id IS NOT NULL THEN 'dead' ELSE 'missing' END)
+		FROM job_dependencies d
+		JOIN jobs j ON j.id = d.job_id AND j.state = 'blocked'
+		LEFT JOIN jobs p ON p.id = d.depends_on
+		LEFT JOIN dlq q ON q.id = d.depends_on
+		ORDER BY d.job_id, d.depends_on
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string][]string{}
+	for rows.Next() {
+		var id, parent, state string
+		if err := rows.Scan(&id, &parent, &state); err != nil {
 			return nil, err
 		}
-		stats[st] = count
+		result[id] = append(result[id], fmt.Sprintf("%s (%s)", parent, state))
 	}
-	return stats, nil
+	return result, rows.Err()
 }
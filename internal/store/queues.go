@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// DefaultQueue is the queue jobs land in when none is given.
+const DefaultQueue = "default"
+
+var queueNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateQueueName reports whether name can be used as a queue name.
+func ValidateQueueName(name string) error {
+	if !queueNameRe.MatchString(name) {
+		return fmt.Errorf("invalid queue name %q (use letters, digits, '_', '.', '-')", name)
+	}
+	return nil
+}
+
+type QueueStats struct {
+	Name          string
+	MaxProcessing int // 0 = unlimited
+	Pending       int
+	Processing    int
+}
+
+// SetQueueLimit caps how many jobs of a queue may be processing at once
+// across all workers. A limit of 0 removes the cap.
+func (s *Store) SetQueueLimit(ctx context.Context, name string, maxProcessing int) error {
+	if err := ValidateQueueName(name); err != nil {
+		return err
+	}
+	if maxProcessing < 0 {
+		return fmt.Errorf("invalid limit %d (must be >= 0)", maxProcessing)
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO queues (name, max_processing) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET max_processing=excluded.max_processing
+	`, name, maxProcessing)
+	return err
+}
+
+// ListQueues returns every queue that has jobs or a configured limit.
+func (s *Store) ListQueues(ctx context.Context) ([]QueueStats, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT n.name,
+		       COALESCE(q.max_processing, 0),
+		       (SELECT COUNT(*) FROM jobs WHERE queue=n.name AND state='pending'),
+		       (SELECT COUNT(*) FROM jobs WHERE queue=n.name AND state='processing')
+		FROM (SELECT DISTINCT queue AS name FROM jobs
+		      UNION SELECT name FROM queues) n
+		LEFT JOIN queues q ON q.name = n.name
+		ORDER BY n.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []QueueStats
+	for rows.Next() {
+		var q QueueStats
+		if err := rows.Scan(&q.Name, &q.MaxProcessing, &q.Pending, &q.Processing); err != nil {
+			return nil, err
+		}
+		result = append(result, q)
+	}
+	return result, rows.Err()
+}
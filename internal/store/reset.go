@@ -5,7 +5,12 @@ import (
 )
 
 func (s *Store) ResetQueue(ctx context.Context) error {
-	_, err := s.DB.ExecContext(ctx, `DELETE FROM jobs;`)
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM jobs; DELETE FROM job_dependencies;`)
+	return err
+}
+
+func (s *Store) ResetAttempts(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM job_attempts;`)
 	return err
 }
 
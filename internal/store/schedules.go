@@ -0,0 +1,298 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"queuectl/internal/cron"
+	"queuectl/internal/model"
+	"strings"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// maxCatchupRuns bounds how many missed ticks a "all" schedule replays at once.
+const maxCatchupRuns = 100
+
+const scheduleColumns = `name, cron, template, paused, catchup,
+		       next_run_at, last_run_at, created_at`
+
+func scanSchedule(r rowScanner) (*model.Schedule, error) {
+	var sc model.Schedule
+	var paused int
+	var nextStr, lastStr, createdStr string
+
+	err := r.Scan(&sc.Name, &sc.Cron, &sc.Template, &paused, &sc.Catchup,
+		&nextStr, &lastStr, &createdStr)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.Paused = paused != 0
+	sc.NextRunAt = parseTime(nextStr)
+	if lastStr != "" {
+		sc.LastRunAt = parseTime(lastStr)
+	}
+	sc.CreatedAt = parseTime(createdStr)
+	return &sc, nil
+}
+
+// parseTemplate checks a schedule's job template and returns it as a spec.
+func parseTemplate(template string) (model.JobSpec, error) {
+	var spec model.JobSpec
+	if err := json.Unmarshal([]byte(template), &spec); err != nil {
+		return spec, fmt.Errorf("invalid job template json: %w", err)
+	}
+	if spec.ID != "" {
+		return spec, fmt.Errorf("job template must not set id, one is generated per run")
+	}
+	if spec.RunAt != "" || spec.Delay != "" {
+		return spec, fmt.Errorf("job template must not set run_at or delay")
+	}
+	if len(spec.DependsOn) > 0 {
+		return spec, fmt.Errorf("job template must not set depends_on")
+	}
+	if _, err := spec.ToJob(time.Now().UTC()); err != nil {
+		return spec, err
+	}
+	if spec.Queue != "" {
+		if err := ValidateQueueName(spec.Queue); err != nil {
+			return spec, err
+		}
+	}
+	return spec, nil
+}
+
+// AddSchedule validates and stores a new schedule. Its first run is the
+// first cron tick after now.
+func (s *Store) AddSchedule(ctx context.Context, sc model.Schedule, now time.Time) error {
+	if err := ValidateQueueName(sc.Name); err != nil {
+		return fmt.Errorf("invalid schedule name %q", sc.Name)
+	}
+	expr, err := cron.Parse(sc.Cron)
+	if err != nil {
+		return err
+	}
+	if _, err := parseTemplate(sc.Template); err != nil {
+		return err
+	}
+	switch sc.Catchup {
+	case "":
+		sc.Catchup = "one"
+	case "one", "all", "skip":
+	default:
+		return fmt.Errorf("invalid catchup %q (use one, all or skip)", sc.Catchup)
+	}
+
+	next := expr.Next(now)
+	if next.IsZero() {
+		return fmt.Errorf("cron %q never fires", sc.Cron)
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO schedules (name, cron, template, paused, catchup, next_run_at, last_run_at, created_at)
+		VALUES (?, ?, ?, 0, ?, ?, '', ?)
+	`, sc.Name, sc.Cron, sc.Template, sc.Catchup,
+		formatTime(next), formatTime(now))
+	if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+		return fmt.Errorf("schedule %q already exists", sc.Name)
+	}
+	return err
+}
+
+func (s *Store) ListSchedules(ctx context.Context) ([]model.Schedule, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Schedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *sc)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) RemoveSchedule(ctx context.Context, name string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM schedules WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (s *Store) PauseSchedule(ctx context.Context, name string) error {
+	res, err := s.DB.ExecContext(ctx, `UPDATE schedules SET paused=1 WHERE name=?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// ResumeSchedule unpauses a schedule. Ticks that passed while it was paused
+// are not replayed, the next run is the first tick after now.
+func (s *Store) ResumeSchedule(ctx context.Context, name string, now time.Time) error {
+	var cronExpr string
+	err := s.DB.QueryRowContext(ctx, `SELECT cron FROM schedules WHERE name=?`, name).Scan(&cronExpr)
+	if err == sql.ErrNoRows {
+		return ErrScheduleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	expr, err := cron.Parse(cronExpr)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE schedules SET paused=0, next_run_at=? WHERE name=?
+	`, formatTime(expr.Next(now)), name)
+	return err
+}
+
+// FireDueSchedules enqueues a job for every schedule tick that is due at now
+// and returns the ids of the jobs it created, along with the errors of the
+// schedules that could not fire. Each schedule is advanced with a
+// compare-and-swap on next_run_at in the same transaction as the enqueue, so
+// several schedulers sharing a database fire every tick exactly once.
+func (s *Store) FireDueSchedules(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM schedules
+		WHERE paused=0 AND next_run_at <= ?
+		ORDER BY name
+	`, formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+
+	var due []*model.Schedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, sc)
+	}
+	rows.Close()
+
+	// one broken schedule must not hold up the others
+	var fired []string
+	var errs []error
+	for _, sc := range due {
+		ids, err := s.fireSchedule(ctx, sc, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", sc.Name, err))
+			continue
+		}
+		fired = append(fired, ids...)
+	}
+	return fired, errors.Join(errs...)
+}
+
+func (s *Store) fireSchedule(ctx context.Context, sc *model.Schedule, now time.Time) ([]string, error) {
+	expr, err := cron.Parse(sc.Cron)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := parseTemplate(sc.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the missed ticks, keeping only the latest ones
+	var ticks []time.Time
+	next := sc.NextRunAt
+	for !next.IsZero() && !next.After(now) {
+		ticks = append(ticks, next)
+		if len(ticks) > maxCatchupRuns {
+			ticks = ticks[1:]
+		}
+		next = expr.Next(next)
+	}
+	if len(ticks) == 0 {
+		return nil, nil
+	}
+
+	latest := ticks[len(ticks)-1]
+	switch sc.Catchup {
+	case "all":
+	case "skip":
+		// only the tick we are on time for runs
+		if now.Sub(latest) >= time.Minute {
+			ticks = nil
+		} else {
+			ticks = ticks[len(ticks)-1:]
+		}
+	default:
+		ticks = ticks[len(ticks)-1:]
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	nextStr := formatTime(next)
+	paused := 0
+	if next.IsZero() {
+		// the expression ran out of ticks, park the schedule
+		nextStr, paused = formatTime(sc.NextRunAt), 1
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE schedules SET next_run_at=?, last_run_at=?, paused=?
+		WHERE name=? AND next_run_at=? AND paused=0
+	`, nextStr, formatTime(latest), paused,
+		sc.Name, formatTime(sc.NextRunAt))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return nil, nil // another scheduler fired these ticks
+	}
+
+	var ids []string
+	for _, tick := range ticks {
+		j, err := spec.ToJob(now)
+		if err != nil {
+			return nil, err
+		}
+		j.ID = fmt.Sprintf("%s-%s", sc.Name, tick.Format("20060102T1504Z"))
+		res, err := s.enqueue(ctx, tx, j)
+		if errors.Is(err, ErrDuplicateID) {
+			// a schedule removed and added again can come back to a tick
+			// that already has its job; it counts as fired
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Deduplicated {
+			continue // the previous run still holds the template's unique key
+		}
+		ids = append(ids, j.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("tx commit: %w", err)
+	}
+	return ids, nil
+}
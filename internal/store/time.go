@@ -0,0 +1,26 @@
+package store
+
+import "time"
+
+// TimeLayout is how every timestamp is stored: UTC with all nine fractional
+// digits, so the text has a fixed width and comparing it in SQL agrees with
+// comparing the times. RFC3339Nano trims trailing zeros and does not.
+const TimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqliteLayout is what SQLite's datetime('now') writes; older versions of
+// RetryDLQ stored it.
+const sqliteLayout = "2006-01-02 15:04:05"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(TimeLayout)
+}
+
+// parseTime reads a stored timestamp, returning the zero time for an empty
+// or unreadable one.
+func parseTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse(sqliteLayout, s)
+	return t
+}
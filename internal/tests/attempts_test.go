@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+)
+
+func TestWorkerRecordsAttemptOutput(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := enqueueTestJob(st, "noisy-job", "echo out; echo err >&2; exit 3", 1)
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	go worker.Run(ctx)
+
+	time.Sleep(2 * time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	attempts, err := st.ListAttempts(context.Background(), "noisy-job")
+	if err != nil {
+		t.Fatalf("Failed to list attempts: %v", err)
+	}
+	if len(attempts) != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", len(attempts))
+	}
+
+	a := attempts[0]
+	if a.Attempt != 1 {
+		t.Errorf("Expected attempt number 1, got %d", a.Attempt)
+	}
+	if !a.Finished() {
+		t.Error("Expected attempt to be finished")
+	}
+	if a.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", a.ExitCode)
+	}
+	if a.Stdout != "out\n" {
+		t.Errorf("Expected stdout 'out\\n', got %q", a.Stdout)
+	}
+	if a.Stderr != "err\n" {
+		t.Errorf("Expected stderr 'err\\n', got %q", a.Stderr)
+	}
+	if a.WorkerID != worker.ID {
+		t.Errorf("Expected worker_id %s, got %s", worker.ID, a.WorkerID)
+	}
+}
+
+func TestWorkerTruncatesAttemptOutput(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := enqueueTestJob(st, "chatty-job", "head -c 1000 /dev/zero | tr '\\0' x", 3)
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	worker.OutputMax = 100
+	go worker.Run(ctx)
+
+	time.Sleep(2 * time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	a, err := st.GetAttempt(context.Background(), "chatty-job", 0)
+	if err != nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if a == nil {
+		t.Fatal("Expected an attempt to be recorded")
+	}
+	if a.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", a.ExitCode)
+	}
+	if !strings.HasPrefix(a.Stdout, strings.Repeat("x", 100)+"\n") {
+		t.Errorf("Expected first 100 bytes to be kept, got %q", a.Stdout)
+	}
+	if !strings.Contains(a.Stdout, "truncated, 900 bytes dropped") {
+		t.Errorf("Expected truncation marker, got %q", a.Stdout)
+	}
+	// followers read the kept part back without the marker
+	if kept, truncated := model.SplitTruncated(a.Stdout); !truncated || kept != strings.Repeat("x", 100) {
+		t.Errorf("Expected SplitTruncated to return the 100 kept bytes, got %q %v", kept, truncated)
+	}
+	if kept, truncated := model.SplitTruncated("plain\n"); truncated || kept != "plain\n" {
+		t.Errorf("Expected untruncated output unchanged, got %q %v", kept, truncated)
+	}
+}
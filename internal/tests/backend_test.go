@@ -0,0 +1,917 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+// The conformance suite below is run against every store.Backend. A new
+// backend gets its own Test function calling runBackendSuite.
+
+func TestSQLiteBackend(t *testing.T) {
+	runBackendSuite(t, func(t *testing.T) store.Backend { return newStore(t) })
+}
+
+func TestMemoryBackend(t *testing.T) {
+	runBackendSuite(t, func(t *testing.T) store.Backend { return store.NewMemStore() })
+}
+
+func TestMemoryBackendRunsWorker(t *testing.T) {
+	st := store.NewMemStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res, err := st.EnqueueJob(ctx, model.Job{Command: "echo from memory"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	w := engine.NewWorker(st)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := st.GetJob(context.Background(), res.ID)
+		if err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+		if j.State == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the worker to complete the job, still %s", j.State)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	attempts, _ := st.ListAttempts(context.Background(), res.ID)
+	if len(attempts) != 1 || attempts[0].ExitCode != 0 || attempts[0].Stdout != "from memory\n" {
+		t.Errorf("Expected one successful attempt with output, got %+v", attempts)
+	}
+}
+
+func runBackendSuite(t *testing.T, newBackend func(t *testing.T) store.Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b store.Backend)
+	}{
+		{"EnqueueAndGet", backendEnqueueAndGet},
+		{"EnqueueBatch", backendEnqueueBatch},
+		{"UniqueKeys", backendUniqueKeys},
+		{"ClaimOrder", backendClaimOrder},
+		{"Dependencies", backendDependencies},
+		{"FailRetryAndDLQ", backendFailRetryAndDLQ},
+		{"CascadeDependencyFailure", backendCascade},
+		{"Cancel", backendCancel},
+		{"CancelledParent", backendCancelledParent},
+		{"LeaseAndReap", backendLeaseAndReap},
+		{"Attempts", backendAttempts},
+		{"DLQRetryAndPurge", backendDLQRetryAndPurge},
+		{"PurgedIDsStartClean", backendPurgedIDsStartClean},
+		{"DLQFilters", backendDLQFilters},
+		{"Config", backendConfig},
+		{"ListAndStatus", backendListAndStatus},
+		{"ConcurrentClaimsAreExclusive", backendConcurrentClaims},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+// soon is a moment after anything enqueued so far, when those jobs are due.
+func soon() time.Time {
+	return time.Now().UTC().Add(time.Second)
+}
+
+func mustEnqueue(t *testing.T, b store.Backend, j model.Job) string {
+	t.Helper()
+	res, err := b.EnqueueJob(context.Background(), j)
+	if err != nil {
+		t.Fatalf("Failed to enqueue %q: %v", j.ID, err)
+	}
+	return res.ID
+}
+
+func mustClaim(t *testing.T, b store.Backend, now time.Time, queue string) *model.Job {
+	t.Helper()
+	j, err := b.Claim(context.Background(), now, "w1", time.Minute, queue)
+	if err != nil {
+		t.Fatalf("Failed to claim: %v", err)
+	}
+	if j == nil {
+		t.Fatalf("Expected a job to claim")
+	}
+	return j
+}
+
+func mustGet(t *testing.T, b store.Backend, id string) *model.Job {
+	t.Helper()
+	j, err := b.GetJob(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", id, err)
+	}
+	return j
+}
+
+func backendEnqueueAndGet(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+
+	id := mustEnqueue(t, b, model.Job{
+		Args:    []string{"echo", "hi"},
+		Env:     map[string]string{"A": "1"},
+		Workdir: "/tmp",
+		Payload: json.RawMessage(`{"k":1}`),
+	})
+	if len(id) != 26 {
+		t.Errorf("Expected a generated ULID, got %q", id)
+	}
+	j := mustGet(t, b, id)
+	if j.State != "pending" || j.Queue != store.DefaultQueue || j.MaxRetries != 3 || j.Attempts != 0 {
+		t.Errorf("Expected defaults filled in, got %+v", j)
+	}
+	if len(j.Args) != 2 || j.Args[1] != "hi" || j.Env["A"] != "1" || j.Workdir != "/tmp" || string(j.Payload) != `{"k":1}` {
+		t.Errorf("Expected the payload to round trip, got %+v", j)
+	}
+	if j.CreatedAt.IsZero() || j.AvailableAt.IsZero() {
+		t.Errorf("Expected timestamps filled in, got %+v", j)
+	}
+
+	// the returned job is a copy
+	j.Args[0] = "changed"
+	if mustGet(t, b, id).Args[0] != "echo" {
+		t.Errorf("Expected GetJob to return a copy")
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "fixed", Command: "true"})
+	if _, err := b.EnqueueJob(ctx, model.Job{ID: "fixed", Command: "true"}); !errors.Is(err, store.ErrDuplicateID) {
+		t.Errorf("Expected ErrDuplicateID, got %v", err)
+	}
+	if _, err := b.EnqueueJob(ctx, model.Job{Command: "true", Queue: "bad queue"}); err == nil {
+		t.Errorf("Expected an invalid queue name to be rejected")
+	}
+	if _, err := b.GetJob(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func backendUniqueKeys(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	first := mustEnqueue(t, b, model.Job{ID: "u1", Command: "true", UniqueKey: "k"})
+	res, err := b.EnqueueJob(ctx, model.Job{ID: "u2", Command: "true", UniqueKey: "k"})
+	if err != nil || !res.Deduplicated || res.ID != first {
+		t.Fatalf("Expected u2 deduplicated onto u1, got %+v (%v)", res, err)
+	}
+
+	j := mustClaim(t, b, now.Add(time.Second), "")
+	if err := b.Complete(ctx, j, now.Add(time.Second)); err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	res, err = b.EnqueueJob(ctx, model.Job{ID: "u3", Command: "true", UniqueKey: "k"})
+	if err != nil || res.Deduplicated || res.ID != "u3" {
+		t.Errorf("Expected the key released once u1 completed, got %+v (%v)", res, err)
+	}
+
+	// with a window the key is held whatever the state
+	mustEnqueue(t, b, model.Job{ID: "w1", Command: "true", UniqueKey: "win", UniqueFor: 3600})
+	if _, err := b.CancelJob(ctx, "w1", now); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	res, err = b.EnqueueJob(ctx, model.Job{ID: "w2", Command: "true", UniqueKey: "win", UniqueFor: 3600})
+	if err != nil || !res.Deduplicated || res.ID != "w1" {
+		t.Errorf("Expected the window to hold the key, got %+v (%v)", res, err)
+	}
+}
+
+func backendClaimOrder(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "old-low", Command: "true", CreatedAt: now.Add(-3 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "new-high", Command: "true", Priority: 5, CreatedAt: now.Add(-1 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "old-high", Command: "true", Priority: 5, CreatedAt: now.Add(-2 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "later", Command: "true", Priority: 9, AvailableAt: now.Add(time.Hour)})
+	mustEnqueue(t, b, model.Job{ID: "emails", Command: "true", Queue: "emails"})
+
+	if j := mustClaim(t, b, now, "emails"); j.ID != "emails" {
+		t.Errorf("Expected the emails job from the emails queue, got %s", j.ID)
+	}
+	for _, want := range []string{"old-high", "new-high", "old-low"} {
+		j := mustClaim(t, b, now, "")
+		if j.ID != want {
+			t.Errorf("Expected %s next, got %s", want, j.ID)
+		}
+		if j.State != "processing" || j.WorkerID != "w1" || !j.LeaseExpiresAt.Equal(now.Add(time.Minute)) {
+			t.Errorf("Expected %s processing under w1's lease, got %+v", j.ID, j)
+		}
+	}
+	if j, err := b.Claim(ctx, now, "w1", time.Minute, ""); err != nil || j != nil {
+		t.Errorf("Expected nothing runnable before the scheduled job is due, got %v (%v)", j, err)
+	}
+	if j := mustClaim(t, b, now.Add(2*time.Hour), ""); j.ID != "later" {
+		t.Errorf("Expected the scheduled job once due, got %s", j.ID)
+	}
+}
+
+func backendDependencies(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "parent", Command: "true"})
+	mustEnqueue(t, b, model.Job{ID: "child", Command: "true", DependsOn: []string{"parent"}})
+	if s := mustGet(t, b, "child").State; s != "blocked" {
+		t.Errorf("Expected child blocked, got %s", s)
+	}
+	blockedOn, err := b.BlockedOn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read blocked jobs: %v", err)
+	}
+	if got := blockedOn["child"]; len(got) != 1 || got[0] != "parent (pending)" || len(blockedOn) != 1 {
+		t.Errorf("Expected child waiting on the pending parent, got %v", blockedOn)
+	}
+	if _, err := b.EnqueueJob(ctx, model.Job{ID: "orphan", Command: "true", DependsOn: []string{"nope"}}); err == nil {
+		t.Errorf("Expected an unknown dependency to be rejected")
+	}
+	if _, err := b.EnqueueJob(ctx, model.Job{ID: "self", Command: "true", DependsOn: []string{"self"}}); err == nil {
+		t.Errorf("Expected a self dependency to be rejected")
+	}
+
+	j := mustClaim(t, b, now, "")
+	if j.ID != "parent" {
+		t.Fatalf("Expected to claim parent, got %s", j.ID)
+	}
+	if err := b.Complete(ctx, j, now); err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	if s := mustGet(t, b, "child").State; s != "pending" {
+		t.Errorf("Expected child released, got %s", s)
+	}
+	if blockedOn, _ := b.BlockedOn(ctx); len(blockedOn) != 0 {
+		t.Errorf("Expected nothing blocked, got %v", blockedOn)
+	}
+
+	// a completed parent is already met
+	mustEnqueue(t, b, model.Job{ID: "late-child", Command: "true", DependsOn: []string{"parent"}})
+	if s := mustGet(t, b, "late-child").State; s != "pending" {
+		t.Errorf("Expected late-child pending, got %s", s)
+	}
+}
+
+func backendFailRetryAndDLQ(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "flaky", Command: "false", MaxRetries: 2})
+	j := mustClaim(t, b, now, "")
+	dead, err := b.FailRetry(ctx, j, now, 2, 60, errors.New("exit 1"))
+	if err != nil || dead {
+		t.Fatalf("Expected a retry, got dead=%v (%v)", dead, err)
+	}
+	j = mustGet(t, b, "flaky")
+	if j.State != "pending" || j.Attempts != 1 || j.LastError != "exit 1" || !j.AvailableAt.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected a retry after 2^1s, got %+v", j)
+	}
+
+	// a job that failed for someone else's lease is not touched
+	if _, err := b.FailRetry(ctx, j, now, 2, 60, errors.New("x")); !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost for an unclaimed job, got %v", err)
+	}
+
+	j = mustClaim(t, b, now.Add(3*time.Second), "")
+	dead, err = b.FailRetry(ctx, j, now.Add(3*time.Second), 2, 60, errors.New("exit 2"))
+	if err != nil || !dead {
+		t.Fatalf("Expected the job dead after its last retry, got dead=%v (%v)", dead, err)
+	}
+	j = mustGet(t, b, "flaky")
+	if j.State != "dead" || j.Attempts != 2 || j.LastError != "exit 2" || !j.FailedAt.Equal(now.Add(3*time.Second)) || !j.AvailableAt.IsZero() || j.WorkerID != "" {
+		t.Errorf("Expected the DLQ entry, got %+v", j)
+	}
+	if _, err := b.EnqueueJob(ctx, model.Job{ID: "flaky", Command: "false"}); !errors.Is(err, store.ErrDuplicateID) {
+		t.Errorf("Expected a DLQ id to stay reserved, got %v", err)
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "broken", Command: "false", MaxRetries: 5})
+	j = mustClaim(t, b, now, "")
+	dead, err = b.FailRetry(ctx, j, now, 2, 60, fmt.Errorf("bad input: %w", store.ErrPermanent))
+	if err != nil || !dead {
+		t.Errorf("Expected a permanent failure to skip retries, got dead=%v (%v)", dead, err)
+	}
+}
+
+func backendCascade(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+	if err := b.SetConfig(ctx, "dependency_failure", "cascade"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "root", Command: "false", MaxRetries: 1})
+	mustEnqueue(t, b, model.Job{ID: "mid", Command: "true", DependsOn: []string{"root"}})
+	mustEnqueue(t, b, model.Job{ID: "leaf", Command: "true", DependsOn: []string{"mid"}})
+
+	j := mustClaim(t, b, now, "")
+	if _, err := b.FailRetry(ctx, j, now, 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	for id, want := range map[string]string{"mid": "dependency root failed", "leaf": "dependency mid failed"} {
+		j := mustGet(t, b, id)
+		if j.State != "dead" || j.LastError != want {
+			t.Errorf("Expected %s dead with %q, got %s %q", id, want, j.State, j.LastError)
+		}
+	}
+
+	// a retried child waits for its parent again
+	if err := b.RetryDLQ(ctx, "mid"); err != nil {
+		t.Fatalf("Failed to retry mid: %v", err)
+	}
+	if s := mustGet(t, b, "mid").State; s != "blocked" {
+		t.Errorf("Expected retried mid blocked on root, got %s", s)
+	}
+
+	// purging root leaves mid nothing to wait for: it goes back to the DLQ,
+	// and a retry no longer waits on root
+	if n, err := b.PurgeDLQ(ctx, "root"); err != nil || n != 1 {
+		t.Fatalf("Expected to purge root, got %d (%v)", n, err)
+	}
+	if j := mustGet(t, b, "mid"); j.State != "dead" || j.LastError != "dependency root was purged" {
+		t.Errorf("Expected mid dead after root was purged, got %s %q", j.State, j.LastError)
+	}
+	for id, want := range map[string]string{"mid": "pending", "leaf": "blocked"} {
+		if err := b.RetryDLQ(ctx, id); err != nil {
+			t.Fatalf("Failed to retry %s: %v", id, err)
+		}
+		if s := mustGet(t, b, id).State; s != want {
+			t.Errorf("Expected retried %s %s, got %s", id, want, s)
+		}
+	}
+}
+
+func backendCancel(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "queued", Command: "true"})
+	if running, err := b.CancelJob(ctx, "queued", now); err != nil || running {
+		t.Fatalf("Expected an immediate cancel, got running=%v (%v)", running, err)
+	}
+	if s := mustGet(t, b, "queued").State; s != "cancelled" {
+		t.Errorf("Expected cancelled, got %s", s)
+	}
+	if _, err := b.CancelJob(ctx, "queued", now); !errors.Is(err, store.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState cancelling twice, got %v", err)
+	}
+	if _, err := b.CancelJob(ctx, "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "running", Command: "sleep 10"})
+	j := mustClaim(t, b, now, "")
+	if running, err := b.CancelJob(ctx, "running", now); err != nil || !running {
+		t.Fatalf("Expected a cancel request, got running=%v (%v)", running, err)
+	}
+	if err := b.ExtendLease(ctx, j, now.Add(time.Minute)); !errors.Is(err, store.ErrCancelRequested) || !j.CancelRequested {
+		t.Errorf("Expected the heartbeat to see the request, got %v", err)
+	}
+	if err := b.MarkCancelled(ctx, j, now); err != nil {
+		t.Fatalf("Failed to mark cancelled: %v", err)
+	}
+	if s := mustGet(t, b, "running").State; s != "cancelled" {
+		t.Errorf("Expected cancelled, got %s", s)
+	}
+	if err := b.MarkCancelled(ctx, j, now); !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost once cancelled, got %v", err)
+	}
+}
+
+func backendCancelledParent(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	// under block the child keeps waiting, showing why
+	mustEnqueue(t, b, model.Job{ID: "parent", Command: "true"})
+	mustEnqueue(t, b, model.Job{ID: "child", Command: "true", DependsOn: []string{"parent"}})
+	if _, err := b.CancelJob(ctx, "parent", now); err != nil {
+		t.Fatalf("Failed to cancel parent: %v", err)
+	}
+	if s := mustGet(t, b, "child").State; s != "blocked" {
+		t.Errorf("Expected child still blocked, got %s", s)
+	}
+	if blockedOn, _ := b.BlockedOn(ctx); len(blockedOn["child"]) != 1 || blockedOn["child"][0] != "parent (cancelled)" {
+		t.Errorf("Expected child waiting on the cancelled parent, got %v", blockedOn)
+	}
+
+	if err := b.SetConfig(ctx, "dependency_failure", "cascade"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	// under cascade a job cancelled while running takes its descendants
+	// with it
+	mustEnqueue(t, b, model.Job{ID: "root", Command: "sleep 10"})
+	mustEnqueue(t, b, model.Job{ID: "mid", Command: "true", DependsOn: []string{"root"}})
+	mustEnqueue(t, b, model.Job{ID: "leaf", Command: "true", DependsOn: []string{"mid"}})
+	j := mustClaim(t, b, now, "")
+	if _, err := b.CancelJob(ctx, "root", now); err != nil {
+		t.Fatalf("Failed to cancel root: %v", err)
+	}
+	if err := b.MarkCancelled(ctx, j, now); err != nil {
+		t.Fatalf("Failed to mark cancelled: %v", err)
+	}
+	for id, want := range map[string]string{"mid": "dependency root was cancelled", "leaf": "dependency mid failed"} {
+		j := mustGet(t, b, id)
+		if j.State != "dead" || j.LastError != want {
+			t.Errorf("Expected %s dead with %q, got %s %q", id, want, j.State, j.LastError)
+		}
+	}
+
+	// and so does one whose worker died after the cancel request
+	mustEnqueue(t, b, model.Job{ID: "orphaned", Command: "sleep 10"})
+	mustEnqueue(t, b, model.Job{ID: "waiting", Command: "true", DependsOn: []string{"orphaned"}})
+	mustClaim(t, b, now, "")
+	if _, err := b.CancelJob(ctx, "orphaned", now); err != nil {
+		t.Fatalf("Failed to cancel orphaned: %v", err)
+	}
+	if n, err := b.ReapExpired(ctx, now.Add(2*time.Minute), 2, 60); err != nil || n != 1 {
+		t.Fatalf("Expected one reaped job, got %d (%v)", n, err)
+	}
+	if j := mustGet(t, b, "waiting"); j.State != "dead" || j.LastError != "dependency orphaned was cancelled" {
+		t.Errorf("Expected waiting dead after its parent was reaped, got %s %q", j.State, j.LastError)
+	}
+}
+
+func backendLeaseAndReap(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "lost", Command: "sleep 100"})
+	j, err := b.Claim(ctx, now, "dead-worker", 5*time.Second, "")
+	if err != nil || j == nil {
+		t.Fatalf("Failed to claim: %v", err)
+	}
+	if _, err := b.StartAttempt(ctx, j, now); err != nil {
+		t.Fatalf("Failed to start attempt: %v", err)
+	}
+	if err := b.ExtendLease(ctx, j, now.Add(10*time.Second)); err != nil {
+		t.Fatalf("Failed to extend lease: %v", err)
+	}
+
+	if n, err := b.ReapExpired(ctx, now.Add(8*time.Second), 2, 60); err != nil || n != 0 {
+		t.Errorf("Expected the extended lease to hold, reaped %d (%v)", n, err)
+	}
+	if n, err := b.ReapExpired(ctx, now.Add(11*time.Second), 2, 60); err != nil || n != 1 {
+		t.Fatalf("Expected one reaped job, got %d (%v)", n, err)
+	}
+	reaped := mustGet(t, b, "lost")
+	if reaped.State != "pending" || reaped.Attempts != 1 || reaped.LastError == "" {
+		t.Errorf("Expected the lost run counted as a failed attempt, got %+v", reaped)
+	}
+	attempts, _ := b.ListAttempts(ctx, "lost")
+	if len(attempts) != 1 || !attempts[0].Finished() || attempts[0].Error == "" {
+		t.Errorf("Expected the abandoned attempt closed, got %+v", attempts)
+	}
+
+	for name, err := range map[string]error{
+		"ExtendLease": b.ExtendLease(ctx, j, now.Add(time.Minute)),
+		"Complete":    b.Complete(ctx, j, now),
+	} {
+		if !errors.Is(err, store.ErrLeaseLost) {
+			t.Errorf("Expected %s from the old owner to fail with ErrLeaseLost, got %v", name, err)
+		}
+	}
+
+	// a worker that died after a cancel request leaves a cancelled job
+	claimed := mustClaim(t, b, now.Add(time.Hour), "")
+	if _, err := b.CancelJob(ctx, claimed.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+	if n, err := b.ReapExpired(ctx, now.Add(2*time.Hour), 2, 60); err != nil || n != 1 {
+		t.Fatalf("Expected one reaped job, got %d (%v)", n, err)
+	}
+	if s := mustGet(t, b, "lost").State; s != "cancelled" {
+		t.Errorf("Expected cancelled, got %s", s)
+	}
+}
+
+func backendAttempts(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "logged", Command: "echo hi"})
+	j := mustClaim(t, b, now, "")
+	a, err := b.StartAttempt(ctx, j, now)
+	if err != nil {
+		t.Fatalf("Failed to start attempt: %v", err)
+	}
+	if a.Attempt != 1 || a.ExitCode != -1 || a.WorkerID != "w1" {
+		t.Errorf("Expected attempt 1 running under w1, got %+v", a)
+	}
+
+	a.Stdout = "partial"
+	if err := b.UpdateAttemptOutput(ctx, a); err != nil {
+		t.Fatalf("Failed to update output: %v", err)
+	}
+	a.Stdout, a.ExitCode, a.FinishedAt = "hi\n", 0, now.Add(time.Second)
+	if err := b.FinishAttempt(ctx, a); err != nil {
+		t.Fatalf("Failed to finish attempt: %v", err)
+	}
+	// output arriving after the attempt finished is dropped
+	late := *a
+	late.Stdout = "late"
+	if err := b.UpdateAttemptOutput(ctx, &late); err != nil {
+		t.Fatalf("Failed to update output: %v", err)
+	}
+
+	next, err := b.StartAttempt(ctx, j, now.Add(2*time.Second))
+	if err != nil || next.Attempt != 2 {
+		t.Fatalf("Expected attempt 2, got %+v (%v)", next, err)
+	}
+
+	attempts, err := b.ListAttempts(ctx, "logged")
+	if err != nil {
+		t.Fatalf("Failed to list attempts: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+	first := attempts[0]
+	if first.Attempt != 1 || first.Stdout != "hi\n" || first.ExitCode != 0 || !first.FinishedAt.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected the finished first attempt, got %+v", first)
+	}
+	if attempts[1].Finished() {
+		t.Errorf("Expected the second attempt still running")
+	}
+	if none, _ := b.ListAttempts(ctx, "missing"); len(none) != 0 {
+		t.Errorf("Expected no attempts for an unknown job, got %+v", none)
+	}
+
+	if latest, err := b.GetAttempt(ctx, "logged", 0); err != nil || latest == nil || latest.Attempt != 2 {
+		t.Errorf("Expected the latest attempt to be 2, got %+v (%v)", latest, err)
+	}
+	if got, err := b.GetAttempt(ctx, "logged", 1); err != nil || got == nil || got.Stdout != "hi\n" {
+		t.Errorf("Expected attempt 1 with its output, got %+v (%v)", got, err)
+	}
+	if got, err := b.GetAttempt(ctx, "logged", 9); err != nil || got != nil {
+		t.Errorf("Expected no attempt 9, got %+v (%v)", got, err)
+	}
+}
+
+func backendDLQRetryAndPurge(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	for i, id := range []string{"d1", "d2", "d3"} {
+		mustEnqueue(t, b, model.Job{ID: id, Command: "false", MaxRetries: 1, Priority: 3, Queue: "q", Timeout: 7})
+		j := mustClaim(t, b, now, "")
+		failedAt := now.Add(time.Duration(i) * time.Second)
+		if _, err := b.FailRetry(ctx, j, failedAt, 2, 60, errors.New("nope")); err != nil {
+			t.Fatalf("Failed to fail %s: %v", id, err)
+		}
+	}
+
+	dlq, err := b.ListDLQ(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	if len(dlq) != 3 || dlq[0].ID != "d3" || dlq[2].ID != "d1" {
+		t.Fatalf("Expected the DLQ most recent first, got %+v", dlq)
+	}
+	if _, err := b.GetDLQJob(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := b.RetryDLQ(ctx, "d1"); err != nil {
+		t.Fatalf("Failed to retry: %v", err)
+	}
+	j := mustGet(t, b, "d1")
+	if j.State != "pending" || j.Attempts != 0 || j.LastError != "nope" || j.Priority != 3 || j.Queue != "q" || j.Timeout != 7 || !j.FailedAt.IsZero() {
+		t.Errorf("Expected d1 back as enqueued with attempts reset, got %+v", j)
+	}
+	if err := b.RetryDLQ(ctx, "d1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound retrying twice, got %v", err)
+	}
+
+	if n, err := b.PurgeDLQ(ctx, "d2", "missing"); err != nil || n != 1 {
+		t.Errorf("Expected to purge d2 only, got %d (%v)", n, err)
+	}
+	if n, err := b.PurgeDLQ(ctx); err != nil || n != 1 {
+		t.Errorf("Expected to purge the rest, got %d (%v)", n, err)
+	}
+	if dlq, _ := b.ListDLQ(ctx); len(dlq) != 0 {
+		t.Errorf("Expected an empty DLQ, got %+v", dlq)
+	}
+}
+
+func backendPurgedIDsStartClean(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+	if err := b.SetConfig(ctx, "dependency_failure", "cascade"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	// root runs once and dies, taking child to the DLQ with it
+	mustEnqueue(t, b, model.Job{ID: "root", Command: "false", MaxRetries: 1})
+	mustEnqueue(t, b, model.Job{ID: "child", Command: "true", DependsOn: []string{"root"}})
+	j := mustClaim(t, b, now, "")
+	if _, err := b.StartAttempt(ctx, j, now); err != nil {
+		t.Fatalf("Failed to start attempt: %v", err)
+	}
+	if _, err := b.FailRetry(ctx, j, now, 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail root: %v", err)
+	}
+	if n, err := b.PurgeDLQ(ctx); err != nil || n != 2 {
+		t.Fatalf("Expected to purge root and child, got %d (%v)", n, err)
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "root", Command: "true"})
+	if attempts, err := b.ListAttempts(ctx, "root"); err != nil || len(attempts) != 0 {
+		t.Errorf("Expected the new root to have no attempts, got %+v (%v)", attempts, err)
+	}
+
+	// the new child has no parent; a DLQ retry must not find the old one
+	mustEnqueue(t, b, model.Job{ID: "child", Command: "false", MaxRetries: 1, Priority: 9})
+	j = mustClaim(t, b, now, "")
+	if j.ID != "child" {
+		t.Fatalf("Expected to claim child, got %s", j.ID)
+	}
+	if _, err := b.FailRetry(ctx, j, now, 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail child: %v", err)
+	}
+	if err := b.RetryDLQ(ctx, "child"); err != nil {
+		t.Fatalf("Failed to retry child: %v", err)
+	}
+	if s := mustGet(t, b, "child").State; s != "pending" {
+		t.Errorf("Expected the retried child runnable, got %s", s)
+	}
+}
+
+func backendDLQFilters(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	jobs := []model.Job{
+		{ID: "f1", Command: "./backup.sh nightly", MaxRetries: 1},
+		{ID: "f2", Args: []string{"/usr/bin/backup", "--full"}, MaxRetries: 1},
+		{ID: "f3", Command: "./report.sh", MaxRetries: 1},
+	}
+	for i, job := range jobs {
+		mustEnqueue(t, b, job)
+		j := mustClaim(t, b, now, "")
+		if _, err := b.FailRetry(ctx, j, now.Add(time.Duration(i)*time.Minute), 2, 60, errors.New("no")); err != nil {
+			t.Fatalf("Failed to fail %s: %v", job.ID, err)
+		}
+	}
+
+	// GLOB * crosses slashes and also matches the args
+	ids, err := b.RetryDLQWhere(ctx, store.DLQFilter{Command: "*backup*"})
+	if err != nil || len(ids) != 2 || ids[0] != "f1" || ids[1] != "f2" {
+		t.Fatalf("Expected f1 and f2 retried oldest first, got %v (%v)", ids, err)
+	}
+	if s := mustGet(t, b, "f2").State; s != "pending" {
+		t.Errorf("Expected f2 pending, got %s", s)
+	}
+
+	if n, err := b.PurgeDLQWhere(ctx, store.DLQFilter{Before: now.Add(90 * time.Second)}); err != nil || n != 0 {
+		t.Errorf("Expected nothing that failed before 90s left to purge, got %d (%v)", n, err)
+	}
+	if n, err := b.PurgeDLQWhere(ctx, store.DLQFilter{Since: now.Add(90 * time.Second), Command: "./report*"}); err != nil || n != 1 {
+		t.Errorf("Expected to purge f3, got %d (%v)", n, err)
+	}
+	if dlq, _ := b.ListDLQ(ctx); len(dlq) != 0 {
+		t.Errorf("Expected an empty DLQ, got %+v", dlq)
+	}
+}
+
+func backendEnqueueBatch(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	mustEnqueue(t, b, model.Job{ID: "taken", Command: "true"})
+
+	batch := []model.Job{
+		{ID: "b1", Command: "true"},
+		{ID: "b2", Command: "true", DependsOn: []string{"b1"}},
+		{ID: "taken", Command: "true"},
+	}
+	results, err := b.EnqueueBatch(ctx, batch, true)
+	if err == nil || !errors.Is(results[2].Err, store.ErrDuplicateID) {
+		t.Fatalf("Expected the atomic batch to fail on the duplicate, got %v / %+v", err, results)
+	}
+	if _, err := b.GetJob(ctx, "b1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected nothing enqueued by the failed atomic batch, got %v", err)
+	}
+
+	results, err = b.EnqueueBatch(ctx, batch, false)
+	if err != nil {
+		t.Fatalf("Failed best-effort batch: %v", err)
+	}
+	if results[0].ID != "b1" || results[1].ID != "b2" || results[2].Err == nil {
+		t.Errorf("Expected b1 and b2 in and the duplicate skipped, got %+v", results)
+	}
+	if s := mustGet(t, b, "b2").State; s != "blocked" {
+		t.Errorf("Expected b2 to wait on b1 from the same batch, got %s", s)
+	}
+}
+
+func backendConfig(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+
+	all, err := b.AllConfig(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	for key, want := range map[string]string{"max_retries": "3", "backoff_base": "2", "lease_seconds": "30", "dependency_failure": "block"} {
+		if all[key] != want {
+			t.Errorf("Expected default %s=%s, got %q", key, want, all[key])
+		}
+	}
+
+	v0, err := b.ConfigVersion(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read config version: %v", err)
+	}
+	if err := b.SetConfig(ctx, "job_timeout_seconds", "12"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	v1, _ := b.ConfigVersion(ctx)
+	if v1 == v0 {
+		t.Errorf("Expected the config version to move on set, still %d", v1)
+	}
+	if v, _ := b.GetConfig(ctx, "job_timeout_seconds"); v != "12" {
+		t.Errorf("Expected 12, got %q", v)
+	}
+	if v, err := b.GetConfig(ctx, "unknown"); err != nil || v != "" {
+		t.Errorf("Expected an unknown key to read as empty, got %q (%v)", v, err)
+	}
+	if n := b.MustGetInt("job_timeout_seconds", 0); n != 12 {
+		t.Errorf("Expected 12, got %d", n)
+	}
+	if n := b.MustGetInt("dependency_failure", 5); n != 5 {
+		t.Errorf("Expected the default for a non-number, got %d", n)
+	}
+
+	// the job timeout default applies to jobs without one
+	id := mustEnqueue(t, b, model.Job{Command: "true"})
+	if j := mustGet(t, b, id); j.Timeout != 12 {
+		t.Errorf("Expected the configured timeout, got %d", j.Timeout)
+	}
+
+	for key, value := range map[string]string{"no_such_key": "1", "max_retries": "0", "backoff_base": "x", "dependency_failure": "maybe"} {
+		if err := b.SetConfig(ctx, key, value); !errors.Is(err, store.ErrInvalidConfig) {
+			t.Errorf("Expected %s=%q rejected with ErrInvalidConfig, got %v", key, value, err)
+		}
+	}
+	overrides, err := b.ConfigOverrides(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read overrides: %v", err)
+	}
+	if len(overrides) != 1 || overrides["job_timeout_seconds"] != "12" {
+		t.Errorf("Expected only the value set to be an override, got %v", overrides)
+	}
+
+	if err := b.UnsetConfig(ctx, "job_timeout_seconds"); err != nil {
+		t.Fatalf("Failed to unset config: %v", err)
+	}
+	if v, _ := b.GetConfig(ctx, "job_timeout_seconds"); v != "0" {
+		t.Errorf("Expected the default back after unset, got %q", v)
+	}
+	if v2, _ := b.ConfigVersion(ctx); v2 == v1 {
+		t.Errorf("Expected the config version to move on unset, still %d", v2)
+	}
+	if err := b.UnsetConfig(ctx, "no_such_key"); !errors.Is(err, store.ErrInvalidConfig) {
+		t.Errorf("Expected unsetting an unknown key to fail, got %v", err)
+	}
+}
+
+func backendListAndStatus(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	now := soon()
+
+	mustEnqueue(t, b, model.Job{ID: "e", Command: "false", MaxRetries: 1})
+	if _, err := b.FailRetry(ctx, mustClaim(t, b, now, ""), now, 2, 60, errors.New("no")); err != nil {
+		t.Fatalf("Failed to fail e: %v", err)
+	}
+
+	mustEnqueue(t, b, model.Job{ID: "a", Command: "true", Priority: 1, CreatedAt: now.Add(-3 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "b", Command: "true", Priority: 5, Queue: "other", CreatedAt: now.Add(-2 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "c", Command: "true", AvailableAt: now.Add(time.Hour), CreatedAt: now.Add(-1 * time.Second)})
+	mustEnqueue(t, b, model.Job{ID: "d", Command: "true", DependsOn: []string{"a"}})
+	for _, want := range []string{"b", "a"} {
+		j := mustClaim(t, b, now, "")
+		if j.ID != want {
+			t.Fatalf("Expected to claim %s, got %s", want, j.ID)
+		}
+		if err := b.Complete(ctx, j, now); err != nil {
+			t.Fatalf("Failed to complete: %v", err)
+		}
+	}
+
+	ids := func(jobs []model.Job) string {
+		s := ""
+		for _, j := range jobs {
+			s += j.ID
+		}
+		return s
+	}
+	minPriority := 1
+	for _, tc := range []struct {
+		filter store.JobFilter
+		want   string
+	}{
+		{store.JobFilter{}, "abcd"},
+		{store.JobFilter{SortBy: "priority"}, "bacd"},
+		{store.JobFilter{State: "completed"}, "ab"},
+		{store.JobFilter{State: "pending"}, "cd"},
+		{store.JobFilter{State: store.StateScheduled}, "c"},
+		{store.JobFilter{Queue: "other"}, "b"},
+		{store.JobFilter{MinPriority: &minPriority}, "ab"},
+		{store.JobFilter{Limit: 2}, "ab"},
+	} {
+		jobs, err := b.ListJobsFiltered(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("Failed to list %+v: %v", tc.filter, err)
+		}
+		if got := ids(jobs); got != tc.want {
+			t.Errorf("ListJobsFiltered(%+v) = %q, want %q", tc.filter, got, tc.want)
+		}
+	}
+	if _, err := b.ListJobsFiltered(ctx, store.JobFilter{SortBy: "name"}); err == nil {
+		t.Errorf("Expected an unknown sort to be rejected")
+	}
+
+	stats, err := b.QueueStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	want := map[string]int{"pending": 2, "blocked": 0, "processing": 0, "completed": 2, "cancelled": 0, "dead": 1}
+	for state, n := range want {
+		if stats[state] != n {
+			t.Errorf("Expected %d %s, got %d", n, state, stats[state])
+		}
+	}
+}
+
+func backendConcurrentClaims(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	const jobs, workers = 60, 8
+
+	for i := 0; i < jobs; i++ {
+		mustEnqueue(t, b, model.Job{ID: fmt.Sprintf("job-%02d", i), Command: "true"})
+	}
+	now := soon()
+
+	var mu sync.Mutex
+	claimedBy := map[string]string{}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			for {
+				j, err := b.Claim(ctx, now, worker, time.Minute, "")
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", worker, err)
+					return
+				}
+				if j == nil {
+					return
+				}
+				mu.Lock()
+				if prev, ok := claimedBy[j.ID]; ok {
+					errs <- fmt.Errorf("%s claimed by both %s and %s", j.ID, prev, worker)
+				}
+				claimedBy[j.ID] = worker
+				mu.Unlock()
+			}
+		}(fmt.Sprintf("worker-%d", w))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(claimedBy) != jobs {
+		t.Errorf("Expected all %d jobs claimed once, got %d", jobs, len(claimedBy))
+	}
+	for id, worker := range claimedBy {
+		if j := mustGet(t, b, id); j.State != "processing" || j.WorkerID != worker {
+			t.Errorf("Expected %s processing under %s, got %s under %s", id, worker, j.State, j.WorkerID)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func countJobs(t *testing.T, st *store.Store) int {
+	t.Helper()
+	var n int
+	if err := st.DB.QueryRow(`SELECT COUNT(*) FROM jobs`).Scan(&n); err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	return n
+}
+
+func TestEnqueueBatchSkipsFailingJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	results, err := st.EnqueueBatch(ctx, []model.Job{
+		{ID: "a", Command: "true"},
+		{ID: "b", Command: "true", DependsOn: []string{"a"}},
+		{ID: "a", Command: "true"},
+		{ID: "c", Command: "true", DependsOn: []string{"missing"}},
+		{Command: "true", UniqueKey: "k"},
+		{Command: "true", UniqueKey: "k"},
+	}, false)
+	if err != nil {
+		t.Fatalf("EnqueueBatch failed: %v", err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("Expected a and b enqueued, got %v / %v", results[0].Err, results[1].Err)
+	}
+	if !errors.Is(results[2].Err, store.ErrDuplicateID) {
+		t.Errorf("Expected ErrDuplicateID for the second a, got %v", results[2].Err)
+	}
+	if results[3].Err == nil {
+		t.Errorf("Expected unknown dependency to fail")
+	}
+	if !results[5].Deduplicated || results[5].ID != results[4].ID {
+		t.Errorf("Expected the second unique job deduplicated, got %+v", results[5])
+	}
+
+	b, err := getJob(st, "b")
+	if err != nil || b.State != "blocked" {
+		t.Errorf("Expected b blocked on a from the same batch, got %+v (%v)", b, err)
+	}
+	var deps int
+	st.DB.QueryRow(`SELECT COUNT(*) FROM job_dependencies WHERE job_id='c'`).Scan(&deps)
+	if _, err := getJob(st, "c"); err == nil || deps != 0 {
+		t.Errorf("Expected nothing left of the failed job c")
+	}
+	if n := countJobs(t, st); n != 3 {
+		t.Errorf("Expected 3 jobs, got %d", n)
+	}
+}
+
+func TestEnqueueBatchAtomicRollsBack(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	if err := enqueueTestJob(st, "taken", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	results, err := st.EnqueueBatch(ctx, []model.Job{
+		{ID: "x", Command: "true"},
+		{ID: "taken", Command: "true"},
+		{ID: "y", Command: "true"},
+	}, true)
+	if !errors.Is(err, store.ErrDuplicateID) {
+		t.Fatalf("Expected the batch to fail with ErrDuplicateID, got %v", err)
+	}
+	if results[1].Err == nil || results[0].ID != "" {
+		t.Errorf("Expected the failing job marked and no ids reported, got %+v", results)
+	}
+	if n := countJobs(t, st); n != 1 {
+		t.Errorf("Expected nothing enqueued, got %d jobs", n)
+	}
+}
+
+func TestEnqueueBatchAcrossTransactions(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	jobs := make([]model.Job, store.DefaultBatchSize*2+10)
+	for i := range jobs {
+		jobs[i] = model.Job{ID: fmt.Sprintf("job-%d", i), Command: "true"}
+	}
+	jobs[store.DefaultBatchSize+3].ID = "job-0" // fails in the second batch
+
+	results, err := st.EnqueueBatch(ctx, jobs, false)
+	if err != nil {
+		t.Fatalf("EnqueueBatch failed: %v", err)
+	}
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Expected one failed job, got %d", failed)
+	}
+	if n := countJobs(t, st); n != len(jobs)-1 {
+		t.Errorf("Expected %d jobs, got %d", len(jobs)-1, n)
+	}
+}
+
+func TestClientEnqueueBatchValidatesFirst(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+	jobs := []client.Job{
+		{ID: "ok", Command: "true"},
+		{ID: "bad"},
+		{ID: "ok2", Command: "true"},
+	}
+
+	results, err := c.EnqueueBatch(ctx, jobs, true)
+	if err == nil || results[1].Err == nil {
+		t.Fatalf("Expected the invalid job to abort the batch, got %v", err)
+	}
+	if _, err := c.Get(ctx, "ok"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected nothing enqueued, got %v", err)
+	}
+
+	results, err = c.EnqueueBatch(ctx, jobs, false)
+	if err != nil {
+		t.Fatalf("EnqueueBatch failed: %v", err)
+	}
+	if results[0].ID != "ok" || results[1].Err == nil || results[2].ID != "ok2" {
+		t.Errorf("Expected only the invalid job skipped, got %+v", results)
+	}
+}
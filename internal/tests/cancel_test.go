@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+func TestCancelPendingJobIsNeverClaimed(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := enqueueTestJob(st, "not-wanted", "echo hi", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	running, err := st.CancelJob(ctx, "not-wanted", time.Now().UTC())
+	if err != nil || running {
+		t.Fatalf("Expected pending job cancelled at once, got running=%v err=%v", running, err)
+	}
+
+	job, err := st.Claim(ctx, time.Now().UTC(), "w1", time.Minute, "")
+	if err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+	if job != nil {
+		t.Errorf("Expected nothing to claim, got %s", job.ID)
+	}
+	if _, err := st.CancelJob(ctx, "not-wanted", time.Now().UTC()); !errors.Is(err, store.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState cancelling twice, got %v", err)
+	}
+}
+
+// runAndCancel starts a long job on worker, cancels it once it is running
+// and waits for the worker to stop it.
+func runAndCancel(t *testing.T, st *store.Store, worker *engine.Worker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "long-job", Command: "sleep 30", MaxRetries: 3}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	worker.Lease = 900 * time.Millisecond
+	worker.KillGrace = 200 * time.Millisecond
+	go worker.Run(ctx)
+
+	waitForState(t, st, "long-job", "processing")
+	running, err := st.CancelJob(context.Background(), "long-job", time.Now().UTC())
+	if err != nil || !running {
+		t.Fatalf("Expected cancel requested on running job, got running=%v err=%v", running, err)
+	}
+	waitForState(t, st, "long-job", "cancelled")
+	cancel()
+
+	a, err := st.GetAttempt(context.Background(), "long-job", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if a.Error != "cancelled" || a.Signal != "terminated" || !a.Finished() {
+		t.Errorf("Expected attempt recorded as cancelled by SIGTERM, got %+v", a)
+	}
+
+	dlq, err := st.ListDLQ(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	if len(dlq) != 0 {
+		t.Errorf("Expected cancelled job kept out of the DLQ, got %+v", dlq)
+	}
+}
+
+func TestCancelRunningJobStopsProcess(t *testing.T) {
+	st := newStore(t)
+	runAndCancel(t, st, engine.NewWorker(st))
+}
+
+func TestCancelRunningRemoteJob(t *testing.T) {
+	st, url := newRemote(t)
+	runAndCancel(t, st, engine.NewWorker(engine.NewRemoteTransport(url, "tok")))
+}
+
+func TestReapCancelsAbandonedCancelRequest(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := enqueueTestJob(st, "orphan", "sleep 30", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	now := time.Now().UTC()
+	job, err := st.Claim(ctx, now, "dead-worker", time.Second, "")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if _, err := st.CancelJob(ctx, "orphan", now); err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+	if err := st.ExtendLease(ctx, job, now.Add(time.Second)); !errors.Is(err, store.ErrCancelRequested) {
+		t.Errorf("Expected heartbeat to report the cancel request, got %v", err)
+	}
+
+	n, err := st.ReapExpired(ctx, now.Add(2*time.Second), 2, 60)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 job reaped, got %d (%v)", n, err)
+	}
+	job, err = getJob(st, "orphan")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.State != "cancelled" || job.Attempts != 0 {
+		t.Errorf("Expected job cancelled without a failed attempt, got %s after %d attempts", job.State, job.Attempts)
+	}
+}
+
+func waitForState(t *testing.T, st *store.Store, id, state string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := getJob(st, id)
+		if err == nil && job.State == state {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Job %s never reached state %s", id, state)
+}
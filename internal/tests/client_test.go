@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"queuectl/pkg/client"
+)
+
+func newClient(t *testing.T) *client.Client {
+	path := filepath.Join(t.TempDir(), "client.db")
+	c, err := client.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientEnqueueGetAndTypedErrors(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	runAt := time.Now().UTC().Add(time.Hour)
+	job, err := c.Enqueue(ctx, client.Job{ID: "c1", Command: "echo hi", Priority: 4, RunAt: runAt})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if job.State != "pending" || job.Priority != 4 || job.MaxRetries != 3 || job.Queue != "default" {
+		t.Errorf("Unexpected stored job %+v", job)
+	}
+	if !job.RunAt.Equal(runAt) {
+		t.Errorf("Expected run_at %v, got %v", runAt, job.RunAt)
+	}
+
+	_, err = c.Enqueue(ctx, client.Job{ID: "c1", Command: "echo again"})
+	if !errors.Is(err, client.ErrDuplicateID) {
+		t.Errorf("Expected ErrDuplicateID, got %v", err)
+	}
+	_, err = c.Enqueue(ctx, client.Job{ID: "c2"})
+	if err == nil {
+		t.Error("Expected validation error for a job without command")
+	}
+
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := c.RetryDead(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from RetryDead, got %v", err)
+	}
+	if _, err := c.Cancel(ctx, "missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Cancel, got %v", err)
+	}
+}
+
+func TestClientCancelAndList(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Enqueue(ctx, client.Job{ID: "parent", Command: "true"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	child, err := c.Enqueue(ctx, client.Job{ID: "child", Command: "true", DependsOn: []string{"parent"}})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if child.State != "blocked" || len(child.WaitingOn) != 1 || child.WaitingOn[0] != "parent (pending)" {
+		t.Errorf("Expected child blocked on parent, got %s %v", child.State, child.WaitingOn)
+	}
+
+	if _, err := c.Cancel(ctx, "parent"); err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+	if _, err := c.Cancel(ctx, "parent"); !errors.Is(err, client.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState cancelling twice, got %v", err)
+	}
+
+	cancelled, err := c.List(ctx, client.ListOptions{State: "cancelled"})
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != "parent" {
+		t.Errorf("Expected only parent cancelled, got %+v", cancelled)
+	}
+
+	blocked, err := c.List(ctx, client.ListOptions{State: "blocked"})
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(blocked) != 1 || blocked[0].WaitingOn[0] != "parent (cancelled)" {
+		t.Errorf("Expected child waiting on cancelled parent, got %+v", blocked)
+	}
+
+	stats, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	if stats["cancelled"] != 1 || stats["blocked"] != 1 || stats["pending"] != 0 {
+		t.Errorf("Expected one cancelled and one blocked job, got %v", stats)
+	}
+}
+
+func TestClientWorkerRunsHandlerJobs(t *testing.T) {
+	c := newClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.Register("test-client-sum", func(ctx context.Context, payload json.RawMessage) error {
+		var nums []int
+		if err := json.Unmarshal(payload, &nums); err != nil {
+			return err
+		}
+		if len(nums) == 0 {
+			return errors.Join(errors.New("nothing to add"), client.ErrPermanent)
+		}
+		return nil
+	})
+
+	if _, err := c.Enqueue(ctx, client.Job{ID: "sum", Type: "test-client-sum", Payload: json.RawMessage(`[1,2]`)}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := c.Enqueue(ctx, client.Job{ID: "empty", Type: "test-client-sum", Payload: json.RawMessage(`[]`)}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	if _, err := c.NewWorker(client.WithQueues("bad:weight")); err == nil {
+		t.Error("Expected invalid queue spec to be rejected")
+	}
+	w, err := c.NewWorker(client.WithConcurrency(2), client.WithLease(5*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create worker: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	time.Sleep(1 * time.Second)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Worker returned %v", err)
+	}
+
+	sum, err := c.Get(context.Background(), "sum")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if sum.State != "completed" {
+		t.Errorf("Expected sum completed, got %s", sum.State)
+	}
+	empty, err := c.Get(context.Background(), "empty")
+	if err != nil {
+		t.Fatalf("Failed to get dead job: %v", err)
+	}
+	if empty.State != "dead" {
+		t.Errorf("Expected empty dead, got %s", empty.State)
+	}
+}
+
+func TestClientOpenMemory(t *testing.T) {
+	c := client.OpenMemory()
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	parent, err := c.Enqueue(ctx, client.Job{Args: []string{"true"}})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	child, err := c.Enqueue(ctx, client.Job{Args: []string{"echo", "after"}, DependsOn: []string{parent.ID}})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if child.State != "blocked" || len(child.WaitingOn) != 1 {
+		t.Errorf("Expected child blocked on parent, got %+v", child)
+	}
+
+	if _, err := c.NewWorker(client.WithScheduler()); err == nil {
+		t.Error("Expected the scheduler to be refused on an in-memory queue")
+	}
+	w, err := c.NewWorker()
+	if err != nil {
+		t.Fatalf("Failed to create worker: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, _ := c.Get(ctx, child.ID); j != nil && j.State == "completed" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if j, err := c.Get(context.Background(), child.ID); err != nil || j.State != "completed" {
+		t.Errorf("Expected child completed in memory, got %+v (%v)", j, err)
+	}
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func TestMaxRetriesConfigAppliesToNewJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.SetConfig(ctx, "max_retries", "5"); err != nil {
+		t.Fatalf("Failed to set max_retries: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "default-retries", Command: "true"}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "own-retries", Command: "true", MaxRetries: 2}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	for id, want := range map[string]int{"default-retries": 5, "own-retries": 2} {
+		j, err := getJob(st, id)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", id, err)
+		}
+		if j.MaxRetries != want {
+			t.Errorf("Expected %s to get %d runs, got %d", id, want, j.MaxRetries)
+		}
+	}
+
+	// job json sets it too
+	j, err := client.ParseJob([]byte(`{"command":"true","max_retries":7}`))
+	if err != nil {
+		t.Fatalf("Failed to parse job: %v", err)
+	}
+	stored, err := client.NewFromStore(st).Enqueue(ctx, j)
+	if err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	if stored.MaxRetries != 7 {
+		t.Errorf("Expected max_retries from the job json, got %d", stored.MaxRetries)
+	}
+	if _, err := client.ParseJob([]byte(`{"command":"true","max_retries":-1}`)); err == nil {
+		t.Errorf("Expected a negative max_retries to be rejected")
+	}
+}
+
+func TestInvalidStoredConfigFallsBackToDefault(t *testing.T) {
+	st := newStore(t)
+
+	// written before values were validated
+	if _, err := st.DB.Exec(`INSERT INTO config (key, value) VALUES ('backoff_base', 'fast'), ('lease_seconds', '-4')`); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if n := st.MustGetInt("backoff_base", 9); n != 2 {
+		t.Errorf("Expected the registry default 2, got %d", n)
+	}
+	if n := st.MustGetInt("lease_seconds", 9); n != 30 {
+		t.Errorf("Expected the registry default 30, got %d", n)
+	}
+}
+
+func TestSeededConfigIsNotAnOverride(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	// go back to when every default was a row, with one value changed
+	if err := st.MigrateDown(ctx, 2); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if _, err := st.DB.Exec(`UPDATE config SET value='7' WHERE key='max_retries'`); err != nil {
+		t.Fatalf("Failed to change config: %v", err)
+	}
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	overrides, err := st.ConfigOverrides(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read overrides: %v", err)
+	}
+	if len(overrides) != 1 || overrides["max_retries"] != "7" {
+		t.Errorf("Expected only the changed value kept, got %v", overrides)
+	}
+	all, _ := st.AllConfig(ctx)
+	if all["max_retries"] != "7" || all["backoff_base"] != "2" {
+		t.Errorf("Expected defaults merged with the override, got %v", all)
+	}
+}
+
+func TestSetConfigNormalisesValues(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.SetConfig(ctx, "backoff_cap_seconds", " 090 "); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if v, _ := st.GetConfig(ctx, "backoff_cap_seconds"); v != "90" {
+		t.Errorf("Expected 90, got %q", v)
+	}
+	if err := st.SetConfig(ctx, "completed_retention", "soon"); !errors.Is(err, store.ErrInvalidConfig) {
+		t.Errorf("Expected an invalid age rejected, got %v", err)
+	}
+}
+
+func TestBackoffIsCappedForLargeAttempts(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "many-runs", Command: "false", MaxRetries: 1000}); err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	now := time.Now().UTC().Add(time.Second)
+	j, err := st.Claim(ctx, now, "w", time.Minute, "")
+	if err != nil || j == nil {
+		t.Fatalf("Failed to claim: %v", err)
+	}
+
+	// 60^99 does not fit in a Duration
+	j.Attempts = 98
+	if _, err := st.FailRetry(ctx, j, now, 60, 90, errors.New("exit 1")); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	retried, err := getJob(st, "many-runs")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if want := now.Add(90 * time.Second); !retried.AvailableAt.Equal(want) {
+		t.Errorf("Expected the retry capped at 90s (%v), got %v", want, retried.AvailableAt)
+	}
+}
+
+func TestEverySettingCanBeLookedUp(t *testing.T) {
+	for _, s := range store.Settings() {
+		if _, ok := store.LookupSetting(s.Key); !ok {
+			t.Errorf("Setting %s cannot be looked up; keep the registry sorted by key", s.Key)
+		}
+		if s.Default != "" {
+			if _, err := s.Validate(s.Default); err != nil {
+				t.Errorf("Default of %s is invalid: %v", s.Key, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+// claimAndComplete claims the next job and completes it, returning its id
+func claimAndComplete(t *testing.T, st *store.Store) string {
+	t.Helper()
+	ctx := context.Background()
+	job, err := st.ClaimOne(ctx, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if job == nil {
+		return ""
+	}
+	if err := st.Complete(ctx, job, time.Now().UTC()); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	return job.ID
+}
+
+func TestDependenciesRunInOrder(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	jobs := []model.Job{
+		{ID: "migrate", Command: "true"},
+		{ID: "backfill-1", Command: "true", DependsOn: []string{"migrate"}},
+		{ID: "backfill-2", Command: "true", DependsOn: []string{"migrate"}},
+		{ID: "notify", Command: "true", DependsOn: []string{"backfill-1", "backfill-2"}},
+	}
+	for _, j := range jobs {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+	}
+
+	blocked, err := st.ListJobs(ctx, "blocked")
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(blocked) != 3 {
+		t.Fatalf("Expected 3 blocked jobs, got %d", len(blocked))
+	}
+
+	reasons, err := st.BlockedOn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get blocked reasons: %v", err)
+	}
+	if got := reasons["notify"]; len(got) != 2 || got[0] != "backfill-1 (blocked)" {
+		t.Errorf("Unexpected reasons for notify: %v", got)
+	}
+
+	if id := claimAndComplete(t, st); id != "migrate" {
+		t.Fatalf("Expected migrate to run first, got %q", id)
+	}
+
+	first := claimAndComplete(t, st)
+	if first != "backfill-1" && first != "backfill-2" {
+		t.Fatalf("Expected a backfill to run next, got %q", first)
+	}
+
+	// notify still waits for the other backfill
+	pending, err := st.ListJobs(ctx, "pending")
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID == "notify" {
+		t.Fatalf("Expected only the remaining backfill pending, got %+v", pending)
+	}
+
+	claimAndComplete(t, st)
+	if id := claimAndComplete(t, st); id != "notify" {
+		t.Errorf("Expected notify to run last, got %q", id)
+	}
+}
+
+func TestDependencyCascadeToDLQ(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.SetConfig(ctx, "dependency_failure", "cascade"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	for _, j := range []model.Job{
+		{ID: "parent", Command: "false", MaxRetries: 1},
+		{ID: "child", Command: "true", DependsOn: []string{"parent"}},
+		{ID: "grandchild", Command: "true", DependsOn: []string{"child"}},
+	} {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+	}
+
+	job, err := st.ClaimOne(ctx, time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	moved, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, errors.New("boom"))
+	if err != nil || !moved {
+		t.Fatalf("Expected parent to move to DLQ: moved=%v err=%v", moved, err)
+	}
+
+	dlqJobs, err := st.ListDLQ(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list DLQ jobs: %v", err)
+	}
+	if len(dlqJobs) != 3 {
+		t.Fatalf("Expected parent and both descendants in DLQ, got %d", len(dlqJobs))
+	}
+
+	var lastError string
+	err = st.DB.QueryRowContext(ctx, `SELECT last_error FROM dlq WHERE id=?`, "grandchild").Scan(&lastError)
+	if err != nil {
+		t.Fatalf("Failed to get last_error: %v", err)
+	}
+	if lastError != "dependency child failed" {
+		t.Errorf("Expected last_error 'dependency child failed', got '%s'", lastError)
+	}
+}
+
+func TestDependencyStaysBlockedUntilDLQRetrySucceeds(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, j := range []model.Job{
+		{ID: "parent", Command: "false", MaxRetries: 1},
+		{ID: "child", Command: "true", DependsOn: []string{"parent"}},
+	} {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+	}
+
+	job, err := st.ClaimOne(ctx, time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if _, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+
+	reasons, err := st.BlockedOn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get blocked reasons: %v", err)
+	}
+	if got := reasons["child"]; len(got) != 1 || got[0] != "parent (dead)" {
+		t.Errorf("Expected child waiting on dead parent, got %v", got)
+	}
+
+	if err := st.RetryDLQ(ctx, "parent"); err != nil {
+		t.Fatalf("Failed to retry parent: %v", err)
+	}
+	if id := claimAndComplete(t, st); id != "parent" {
+		t.Fatalf("Expected parent to run, got %q", id)
+	}
+	if id := claimAndComplete(t, st); id != "child" {
+		t.Errorf("Expected child to run after parent succeeded, got %q", id)
+	}
+}
+
+func TestDependencyValidation(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	err := st.Enqueue(ctx, model.Job{ID: "orphan", Command: "true", DependsOn: []string{"nope"}})
+	if err == nil {
+		t.Error("Expected error for unknown dependency")
+	}
+	if _, err := getJob(st, "orphan"); err == nil {
+		t.Error("Expected rejected job not to be inserted")
+	}
+
+	err = st.Enqueue(ctx, model.Job{ID: "self", Command: "true", DependsOn: []string{"self"}})
+	if err == nil {
+		t.Error("Expected error for self dependency")
+	}
+}
+
+func TestOldJobsTableIsRebuiltForNewStates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	_, err = db.Exec(`
+CREATE TABLE jobs (
+  id TEXT PRIMARY KEY,
+  command TEXT NOT NULL,
+  state TEXT NOT NULL CHECK (state IN ('pending','processing','completed','failed','dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_retries INTEGER NOT NULL DEFAULT 3,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  available_at TEXT NOT NULL
+);
+INSERT INTO jobs VALUES ('old-job','echo old','completed',0,3,'2024-01-01T00:00:00Z','2024-01-01T00:00:00Z','2024-01-01T00:00:00Z');
+`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+
+	st, err := store.NewStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer func() {
+		st.DB.Close()
+		os.Remove(path)
+	}()
+
+	old, err := getJob(st, "old-job")
+	if err != nil {
+		t.Fatalf("Expected old job to survive the rebuild: %v", err)
+	}
+	if old.Command != "echo old" || old.State != "completed" {
+		t.Errorf("Old job changed during rebuild: %+v", old)
+	}
+
+	err = st.Enqueue(context.Background(), model.Job{ID: "new-child", Command: "true", DependsOn: []string{"old-job"}})
+	if err != nil {
+		t.Fatalf("Failed to enqueue: %v", err)
+	}
+	err = st.Enqueue(context.Background(), model.Job{ID: "blocked-child", Command: "true", DependsOn: []string{"new-child"}})
+	if err != nil {
+		t.Fatalf("Expected blocked state to be accepted after rebuild: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/store"
+)
+
+// fillDLQ moves the given jobs to the DLQ, the first failing longest ago:
+// job i failed i+1 days before now.
+func fillDLQ(t *testing.T, st *store.Store, ids ...string) {
+	t.Helper()
+	now := time.Now().UTC()
+	for i, id := range ids {
+		if err := enqueueTestJob(st, id, "false", 3); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		killJob(t, st, id, "boom")
+		failed := now.Add(-time.Duration(len(ids)-i) * 24 * time.Hour).Format(store.TimeLayout)
+		if _, err := st.DB.Exec(`UPDATE dlq SET failed_at=? WHERE id=?`, failed, id); err != nil {
+			t.Fatalf("Failed to age job: %v", err)
+		}
+	}
+}
+
+func dlqIDs(t *testing.T, st *store.Store) []string {
+	t.Helper()
+	jobs, err := st.ListDLQ(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	var ids []string
+	for _, j := range jobs {
+		ids = append(ids, j.ID)
+	}
+	return ids
+}
+
+func TestGetDLQJobShowsFailureDetails(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	before := time.Now().UTC()
+	if err := enqueueTestJob(st, "broken", "false", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	killJob(t, st, "broken", "exit status 1")
+
+	j, err := st.GetDLQJob(ctx, "broken")
+	if err != nil {
+		t.Fatalf("Failed to get DLQ job: %v", err)
+	}
+	if j.State != "dead" || j.LastError != "exit status 1: permanent failure" {
+		t.Errorf("Expected dead job with its last error, got %s %q", j.State, j.LastError)
+	}
+	if j.FailedAt.Before(before) || !j.AvailableAt.IsZero() {
+		t.Errorf("Expected failed_at set and no available_at, got %v / %v", j.FailedAt, j.AvailableAt)
+	}
+
+	if _, err := st.GetDLQJob(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPurgeDLQWhereByAgeAndID(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	fillDLQ(t, st, "d3", "d2", "d1")
+
+	n, err := st.PurgeDLQWhere(ctx, store.DLQFilter{Before: time.Now().UTC().Add(-36 * time.Hour)})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 jobs older than 36h purged, got %d (%v)", n, err)
+	}
+	if ids := dlqIDs(t, st); len(ids) != 1 || ids[0] != "d1" {
+		t.Errorf("Expected only d1 left, got %v", ids)
+	}
+
+	n, err = st.PurgeDLQWhere(ctx, store.DLQFilter{IDs: []string{"d1", "missing"}})
+	if err != nil || n != 1 {
+		t.Errorf("Expected d1 purged by id, got %d (%v)", n, err)
+	}
+}
+
+func TestTrimDLQKeepsNewestJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	fillDLQ(t, st, "d5", "d4", "d3", "d2", "d1")
+
+	n, err := st.TrimDLQ(ctx, time.Now().UTC(), 4*24*time.Hour+time.Hour, 3)
+	if err != nil {
+		t.Fatalf("Failed to trim DLQ: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 jobs removed, got %d", n)
+	}
+	ids := dlqIDs(t, st)
+	if len(ids) != 3 || ids[0] != "d1" || ids[2] != "d3" {
+		t.Errorf("Expected the 3 newest jobs kept, got %v", ids)
+	}
+}
+
+func TestJanitorEnforcesDLQRetentionFromConfig(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	fillDLQ(t, st, "d3", "d2", "d1")
+
+	janitor := engine.NewJanitor(st)
+	if err := janitor.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if ids := dlqIDs(t, st); len(ids) != 3 {
+		t.Fatalf("Expected nothing removed without a policy, got %v", ids)
+	}
+
+	if err := st.SetConfig(ctx, "dlq_max_age_days", "2"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := st.SetConfig(ctx, "dlq_max_count", "1"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := janitor.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if ids := dlqIDs(t, st); len(ids) != 1 || ids[0] != "d1" {
+		t.Errorf("Expected only the newest job kept, got %v", ids)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -332,3 +333,157 @@ func TestDLQRetryPreservesJobCommand(t *testing.T) {
 	}
 }
 
+
+// killJob claims id and fails it permanently, moving it to the DLQ.
+func killJob(t *testing.T, st *store.Store, id, reason string) {
+	t.Helper()
+	ctx := context.Background()
+	job, err := st.Claim(ctx, time.Now().UTC(), "w1", time.Minute, "")
+	if err != nil || job == nil || job.ID != id {
+		t.Fatalf("Expected to claim %s, got %v (%v)", id, job, err)
+	}
+	moved, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, fmt.Errorf("%s: %w", reason, store.ErrPermanent))
+	if err != nil || !moved {
+		t.Fatalf("Expected %s moved to DLQ, got moved=%v err=%v", id, moved, err)
+	}
+}
+
+func TestDLQRetryKeepsLastErrorAndIsClaimable(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := enqueueTestJob(st, "flaky", "exit 1", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	killJob(t, st, "flaky", "disk full")
+
+	if err := st.RetryDLQ(ctx, "flaky"); err != nil {
+		t.Fatalf("Failed to retry job: %v", err)
+	}
+	job, err := st.GetJob(ctx, "flaky")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.LastError != "disk full: permanent failure" {
+		t.Errorf("Expected DLQ last_error kept on the job, got %q", job.LastError)
+	}
+
+	var availableAt string
+	if err := st.DB.QueryRow(`SELECT available_at FROM jobs WHERE id='flaky'`).Scan(&availableAt); err != nil {
+		t.Fatalf("Failed to read available_at: %v", err)
+	}
+	if _, err := time.Parse(store.TimeLayout, availableAt); err != nil || len(availableAt) != len(time.Time{}.Format(store.TimeLayout)) {
+		t.Errorf("Expected available_at in the store's layout, got %q", availableAt)
+	}
+
+	claimed, err := st.Claim(ctx, time.Now().UTC(), "w1", time.Minute, "")
+	if err != nil || claimed == nil || claimed.ID != "flaky" {
+		t.Errorf("Expected retried job claimable right away, got %v (%v)", claimed, err)
+	}
+
+	if err := st.RetryDLQ(ctx, "flaky"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound retrying a job not in the DLQ, got %v", err)
+	}
+}
+
+func TestDLQRetryWhereFiltersByCommandAndAge(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, j := range []struct{ id, command string }{
+		{"backup-old", "backup db"},
+		{"backup-new", "backup files"},
+		{"mail", "send mail"},
+	} {
+		if err := enqueueTestJob(st, j.id, j.command, 3); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		killJob(t, st, j.id, "boom")
+	}
+	old := time.Now().UTC().Add(-2 * time.Hour).Format(store.TimeLayout)
+	if _, err := st.DB.Exec(`UPDATE dlq SET failed_at=? WHERE id='backup-old'`, old); err != nil {
+		t.Fatalf("Failed to age job: %v", err)
+	}
+
+	ids, err := st.RetryDLQWhere(ctx, store.DLQFilter{Command: "backup*", Since: time.Now().UTC().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to retry jobs: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "backup-new" {
+		t.Errorf("Expected only backup-new retried, got %v", ids)
+	}
+
+	ids, err = st.RetryDLQWhere(ctx, store.DLQFilter{})
+	if err != nil {
+		t.Fatalf("Failed to retry jobs: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "backup-old" || ids[1] != "mail" {
+		t.Errorf("Expected the rest retried oldest first, got %v", ids)
+	}
+}
+
+func TestDLQRetryWhereIsAllOrNothing(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		if err := enqueueTestJob(st, id, "false", 3); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		killJob(t, st, id, "boom")
+	}
+	// a new job took b's id while b sat in the DLQ
+	if err := enqueueTestJob(st, "b", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	if _, err := st.RetryDLQWhere(ctx, store.DLQFilter{}); !errors.Is(err, store.ErrDuplicateID) {
+		t.Fatalf("Expected ErrDuplicateID, got %v", err)
+	}
+	dlq, err := st.ListDLQ(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	if len(dlq) != 2 {
+		t.Errorf("Expected both jobs left in the DLQ, got %d", len(dlq))
+	}
+}
+
+func TestOldTimestampsAreNormalizedOnOpen(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := enqueueTestJob(st, "legacy", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	// what older versions wrote: trimmed RFC3339Nano and datetime('now')
+	if _, err := st.DB.Exec(`UPDATE jobs SET created_at='2025-01-02T15:04:05.5Z', available_at=datetime('now') WHERE id='legacy'`); err != nil {
+		t.Fatalf("Failed to write old timestamps: %v", err)
+	}
+	// nor did they record a schema version
+	if _, err := st.DB.Exec(`DROP TABLE schema_migrations`); err != nil {
+		t.Fatalf("Failed to drop schema_migrations: %v", err)
+	}
+
+	reopened, err := store.NewStore(dbPath(t, st))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.DB.Close()
+
+	var created, available string
+	if err := reopened.DB.QueryRow(`SELECT created_at, available_at FROM jobs WHERE id='legacy'`).Scan(&created, &available); err != nil {
+		t.Fatalf("Failed to read job: %v", err)
+	}
+	if created != "2025-01-02T15:04:05.500000000Z" {
+		t.Errorf("Expected created_at rewritten, got %q", created)
+	}
+	if _, err := time.Parse(store.TimeLayout, available); err != nil {
+		t.Errorf("Expected available_at rewritten, got %q", available)
+	}
+
+	job, err := reopened.Claim(ctx, time.Now().UTC(), "w1", time.Minute, "")
+	if err != nil || job == nil {
+		t.Errorf("Expected legacy job claimable, got %v (%v)", job, err)
+	}
+}
@@ -118,9 +118,9 @@ func TestEnqueueJobWithFutureAvailableAt(t *testing.T) {
 		INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, available_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`, "future-job", "echo future", "pending", 0, 3,
-		now.Format(time.RFC3339Nano),
-		now.Format(time.RFC3339Nano),
-		future.Format(time.RFC3339Nano),
+		now.Format(store.TimeLayout),
+		now.Format(store.TimeLayout),
+		future.Format(store.TimeLayout),
 	)
 	if err != nil {
 		t.Fatalf("Failed to insert job: %v", err)
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+// finishJob puts a job straight into state, last updated at updated.
+func finishJob(t *testing.T, st *store.Store, id, state string, updated time.Time) {
+	t.Helper()
+	if err := enqueueTestJob(st, id, "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	_, err := st.DB.Exec(`UPDATE jobs SET state=?, updated_at=? WHERE id=?`, state, updated.Format(store.TimeLayout), id)
+	if err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+	if _, err := st.DB.Exec(`INSERT INTO job_attempts (job_id, attempt, started_at) VALUES (?, 1, ?)`, id, updated.Format(store.TimeLayout)); err != nil {
+		t.Fatalf("Failed to record attempt: %v", err)
+	}
+}
+
+func TestDeleteFinishedJobsKeepsDailyCounts(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	old := now.Add(-10 * 24 * time.Hour)
+
+	finishJob(t, st, "old-done", "completed", old)
+	finishJob(t, st, "old-done-2", "completed", old)
+	finishJob(t, st, "old-cancelled", "cancelled", old)
+	finishJob(t, st, "new-done", "completed", now)
+	if err := enqueueTestJob(st, "still-pending", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	before, err := st.DailyStats(ctx, old)
+	if err != nil {
+		t.Fatalf("Failed to read stats: %v", err)
+	}
+
+	n, err := st.DeleteFinishedJobs(ctx, now.Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to delete jobs: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 old finished jobs deleted, got %d", n)
+	}
+	for _, id := range []string{"new-done", "still-pending"} {
+		if _, err := getJob(st, id); err != nil {
+			t.Errorf("Expected %s kept: %v", id, err)
+		}
+	}
+	var attempts int
+	if err := st.DB.QueryRow(`SELECT COUNT(*) FROM job_attempts WHERE job_id LIKE 'old-%'`).Scan(&attempts); err != nil || attempts != 0 {
+		t.Errorf("Expected attempts of deleted jobs removed, got %d (%v)", attempts, err)
+	}
+
+	after, err := st.DailyStats(ctx, old)
+	if err != nil {
+		t.Fatalf("Failed to read stats: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("Expected the same stats before and after gc, got %v and %v", before, after)
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Errorf("Expected %v after gc, got %v", before[i], after[i])
+		}
+	}
+	want := store.DayStats{Day: old.Format(time.DateOnly), Queue: "default", State: "completed", Count: 2}
+	if after[1] != want {
+		t.Errorf("Expected %v, got %v", want, after[1])
+	}
+}
+
+func TestJanitorAppliesCompletedRetention(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	finishJob(t, st, "ancient", "completed", time.Now().UTC().Add(-3*time.Hour))
+
+	janitor := engine.NewJanitor(st)
+	if err := janitor.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if _, err := getJob(st, "ancient"); err != nil {
+		t.Fatalf("Expected job kept without a retention policy: %v", err)
+	}
+
+	if err := st.SetConfig(ctx, "completed_retention", "2h"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := janitor.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if _, err := getJob(st, "ancient"); err == nil {
+		t.Error("Expected job deleted by retention")
+	}
+}
+
+func TestNewDatabaseUsesIncrementalVacuum(t *testing.T) {
+	st := newStore(t)
+	var mode int
+	if err := st.DB.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		t.Fatalf("Failed to read auto_vacuum: %v", err)
+	}
+	if mode != 2 {
+		t.Errorf("Expected incremental auto_vacuum (2), got %d", mode)
+	}
+
+	// fill a few hundred pages, then free them
+	payload := strings.Repeat("x", 4000)
+	for i := 0; i < 200; i++ {
+		if err := enqueueTestJob(st, fmt.Sprintf("big-%d", i), payload, 3); err != nil {
+			t.Fatalf("Failed to insert job: %v", err)
+		}
+	}
+	if _, err := st.DB.Exec(`DELETE FROM jobs`); err != nil {
+		t.Fatalf("Failed to delete jobs: %v", err)
+	}
+	var free int
+	if err := st.DB.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil || free < 100 {
+		t.Fatalf("Expected many free pages after the delete, got %d (%v)", free, err)
+	}
+
+	if err := st.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if err := st.DB.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil || free != 0 {
+		t.Errorf("Expected Compact to release every free page, %d left (%v)", free, err)
+	}
+}
+
+func TestDeleteFinishedJobsKeepsCancelledParentsOfBlockedJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	old := time.Now().UTC().Add(-10 * 24 * time.Hour)
+	cutoff := old.Add(24 * time.Hour)
+
+	if err := st.Enqueue(ctx, model.Job{ID: "parent", Command: "true"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "child", Command: "true", DependsOn: []string{"parent"}}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := st.CancelJob(ctx, "parent", old); err != nil {
+		t.Fatalf("Failed to cancel parent: %v", err)
+	}
+
+	// child is still waiting on parent, so gc leaves it
+	if n, err := st.DeleteFinishedJobs(ctx, cutoff); err != nil || n != 0 {
+		t.Fatalf("Expected nothing deleted, got %d (%v)", n, err)
+	}
+	blockedOn, err := st.BlockedOn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read blocked jobs: %v", err)
+	}
+	if got := blockedOn["child"]; len(got) != 1 || got[0] != "parent (cancelled)" {
+		t.Errorf("Expected child waiting on the cancelled parent, got %v", blockedOn)
+	}
+
+	// once child is dealt with, both go along with the dependency row
+	if _, err := st.CancelJob(ctx, "child", old); err != nil {
+		t.Fatalf("Failed to cancel child: %v", err)
+	}
+	if n, err := st.DeleteFinishedJobs(ctx, cutoff); err != nil || n != 2 {
+		t.Fatalf("Expected parent and child deleted, got %d (%v)", n, err)
+	}
+	var deps int
+	if err := st.DB.QueryRow(`SELECT COUNT(*) FROM job_dependencies`).Scan(&deps); err != nil || deps != 0 {
+		t.Errorf("Expected no dependency rows left, got %d (%v)", deps, err)
+	}
+}
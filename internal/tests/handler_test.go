@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+)
+
+func TestWorkerDispatchesToRegisteredHandler(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := make(chan string, 1)
+	engine.Register("test-greet", func(ctx context.Context, payload json.RawMessage) error {
+		var p struct{ Name string }
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		got <- p.Name
+		return nil
+	})
+	defer engine.Unregister("test-greet")
+
+	err := st.Enqueue(context.Background(), model.Job{
+		ID:      "handler-job",
+		Type:    "test-greet",
+		Payload: json.RawMessage(`{"name":"ada"}`),
+	})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	go worker.Run(ctx)
+
+	select {
+	case name := <-got:
+		if name != "ada" {
+			t.Errorf("Expected payload name 'ada', got %q", name)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Handler was not called")
+	}
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	job, err := getJob(st, "handler-job")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.State != "completed" {
+		t.Errorf("Expected state 'completed', got '%s'", job.State)
+	}
+}
+
+func TestUnknownJobTypeGoesStraightToDLQ(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := st.Enqueue(context.Background(), model.Job{ID: "mystery", Type: "no-such-type", MaxRetries: 5})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	go worker.Run(ctx)
+	time.Sleep(1 * time.Second)
+	cancel()
+
+	dlq, err := st.ListDLQ(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	if len(dlq) != 1 || dlq[0].ID != "mystery" {
+		t.Fatalf("Expected job in DLQ after one attempt, got %+v", dlq)
+	}
+	if dlq[0].Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", dlq[0].Attempts)
+	}
+	if dlq[0].Type != "no-such-type" {
+		t.Errorf("Expected DLQ to keep the job type, got %q", dlq[0].Type)
+	}
+
+	var lastError string
+	err = st.DB.QueryRow(`SELECT last_error FROM dlq WHERE id=?`, "mystery").Scan(&lastError)
+	if err != nil {
+		t.Fatalf("Failed to get last_error: %v", err)
+	}
+	if !strings.Contains(lastError, `no handler registered for job type "no-such-type"`) {
+		t.Errorf("Unexpected last_error %q", lastError)
+	}
+}
+
+func TestHandlerErrorsRetryAndPanicsAreRecovered(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	engine.Register("test-panic", func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+	defer engine.Unregister("test-panic")
+	engine.Register("test-permanent", func(ctx context.Context, payload json.RawMessage) error {
+		return errors.Join(errors.New("bad address"), engine.ErrPermanent)
+	})
+	defer engine.Unregister("test-permanent")
+
+	if err := st.Enqueue(context.Background(), model.Job{ID: "panics", Type: "test-panic"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(context.Background(), model.Job{ID: "permanent", Type: "test-permanent"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	go worker.Run(ctx)
+	time.Sleep(1 * time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	job, err := getJob(st, "panics")
+	if err != nil {
+		t.Fatalf("Expected panicking job to stay queued for retry: %v", err)
+	}
+	if job.State != "pending" || job.Attempts != 1 {
+		t.Errorf("Expected pending retry after 1 attempt, got %s/%d", job.State, job.Attempts)
+	}
+	a, err := st.GetAttempt(context.Background(), "panics", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if !strings.Contains(a.Error, "panicked: boom") {
+		t.Errorf("Expected panic recorded on attempt, got %q", a.Error)
+	}
+
+	if _, err := getJob(st, "permanent"); err == nil {
+		t.Error("Expected permanent failure to leave the jobs table")
+	}
+}
+
+func TestJobSpecHandlerValidation(t *testing.T) {
+	now := time.Now().UTC()
+	bad := []string{
+		`{"id":"x","type":"email","command":"echo"}`,
+		`{"id":"x","command":"echo","payload":{"a":1}}`,
+	}
+	for _, raw := range bad {
+		var spec model.JobSpec
+		if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+			t.Fatalf("Failed to parse spec: %v", err)
+		}
+		if _, err := spec.ToJob(now); err == nil {
+			t.Errorf("Expected validation error for %s", raw)
+		}
+	}
+
+	var spec model.JobSpec
+	if err := json.Unmarshal([]byte(`{"id":"x","type":"email","payload":{ "to": "a@b.c" }}`), &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	j, err := spec.ToJob(now)
+	if err != nil {
+		t.Fatalf("Expected valid spec: %v", err)
+	}
+	if j.Display() != `email {"to":"a@b.c"}` {
+		t.Errorf("Unexpected display %s", j.Display())
+	}
+}
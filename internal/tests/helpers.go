@@ -33,6 +33,16 @@ func newStore(t testingT) *store.Store {
 	return st
 }
 
+// dbPath returns the file backing a test store, to open it a second time
+func dbPath(t testingT, st *store.Store) string {
+	var seq int
+	var name, file string
+	if err := st.DB.QueryRow(`PRAGMA database_list`).Scan(&seq, &name, &file); err != nil {
+		t.Fatalf("Failed to get database path: %v", err)
+	}
+	return file
+}
+
 // enqueueTestJob directly inserts a job into the database for testing
 // This should be used instead of calling st.Enqueue directly in tests
 func enqueueTestJob(st *store.Store, id, command string, maxRetries int) error {
@@ -43,9 +53,9 @@ func enqueueTestJob(st *store.Store, id, command string, maxRetries int) error {
 		INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, available_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`, id, command, "pending", 0, maxRetries,
-		now.Format(time.RFC3339Nano),
-		now.Format(time.RFC3339Nano),
-		now.Format(time.RFC3339Nano),
+		now.Format(store.TimeLayout),
+		now.Format(store.TimeLayout),
+		now.Format(store.TimeLayout),
 	)
 	
 	return err
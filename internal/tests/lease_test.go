@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"queuectl/internal/store"
+)
+
+func TestExpiredLeaseIsReaped(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	err := enqueueTestJob(st, "orphan-job", "sleep 100", 3)
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	now := time.Now().UTC()
+
+	// Claim with a short lease and never heartbeat, as a killed worker would
+	job, err := st.Claim(ctx, now, "dead-worker", 5*time.Second, "")
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if job == nil {
+		t.Fatal("Expected to claim a job")
+	}
+	if job.WorkerID != "dead-worker" {
+		t.Errorf("Expected worker_id 'dead-worker', got '%s'", job.WorkerID)
+	}
+
+	// Nothing to reap while the lease is still valid
+	n, err := st.ReapExpired(ctx, now.Add(1*time.Second), 2, 60)
+	if err != nil {
+		t.Fatalf("Failed to reap: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected 0 reaped jobs before lease expiry, got %d", n)
+	}
+
+	n, err = st.ReapExpired(ctx, now.Add(10*time.Second), 2, 60)
+	if err != nil {
+		t.Fatalf("Failed to reap: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected 1 reaped job, got %d", n)
+	}
+
+	updatedJob, err := getJob(st, "orphan-job")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if updatedJob.State != "pending" {
+		t.Errorf("Expected state 'pending', got '%s'", updatedJob.State)
+	}
+	if updatedJob.Attempts != 1 {
+		t.Errorf("Expected lost run to count as attempt 1, got %d", updatedJob.Attempts)
+	}
+
+	// The original worker can no longer report on the job
+	err = st.Complete(ctx, job, time.Now().UTC())
+	if !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost completing a reaped job, got %v", err)
+	}
+}
+
+func TestHeartbeatKeepsLease(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	err := enqueueTestJob(st, "busy-job", "sleep 100", 3)
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	now := time.Now().UTC()
+
+	job, err := st.Claim(ctx, now, "live-worker", 5*time.Second, "")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+
+	if err := st.ExtendLease(ctx, job, now.Add(1*time.Minute)); err != nil {
+		t.Fatalf("Failed to extend lease: %v", err)
+	}
+
+	n, err := st.ReapExpired(ctx, now.Add(10*time.Second), 2, 60)
+	if err != nil {
+		t.Fatalf("Failed to reap: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected heartbeated job not to be reaped, got %d", n)
+	}
+
+	if err := st.Complete(ctx, job, time.Now().UTC()); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	updatedJob, err := getJob(st, "busy-job")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if updatedJob.State != "completed" {
+		t.Errorf("Expected state 'completed', got '%s'", updatedJob.State)
+	}
+}
+
+func TestReapedJobMovesToDLQOnLastAttempt(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	err := enqueueTestJob(st, "last-chance", "sleep 100", 1)
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	now := time.Now().UTC()
+
+	if _, err := st.Claim(ctx, now, "dead-worker", 5*time.Second, ""); err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+
+	n, err := st.ReapExpired(ctx, now.Add(10*time.Second), 2, 60)
+	if err != nil {
+		t.Fatalf("Failed to reap: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected 1 reaped job, got %d", n)
+	}
+
+	dlqJobs, err := st.ListDLQ(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list DLQ jobs: %v", err)
+	}
+	if len(dlqJobs) != 1 {
+		t.Fatalf("Expected 1 job in DLQ, got %d", len(dlqJobs))
+	}
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/metrics"
+	"queuectl/internal/model"
+	"queuectl/internal/server"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+	m := metrics.New()
+	m.JobClaimed("emails", 200*time.Millisecond)
+	m.JobClaimed("emails", 2*time.Second)
+	m.JobRan("emails", 3*time.Second)
+	m.JobCompleted("emails")
+	m.JobFailed("reports", true)
+	m.SetWorkerBusy(`host:1:"1"`, true)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE queuectl_jobs_claimed_total counter\n",
+		`queuectl_jobs_claimed_total{queue="emails"} 2` + "\n",
+		`queuectl_jobs_completed_total{queue="emails"} 1` + "\n",
+		`queuectl_jobs_failed_total{queue="reports"} 1` + "\n",
+		`queuectl_jobs_dead_lettered_total{queue="reports"} 1` + "\n",
+		"# TYPE queuectl_job_queue_wait_seconds histogram\n",
+		`queuectl_job_queue_wait_seconds_bucket{queue="emails",le="0.1"} 0` + "\n",
+		`queuectl_job_queue_wait_seconds_bucket{queue="emails",le="0.5"} 1` + "\n",
+		`queuectl_job_queue_wait_seconds_bucket{queue="emails",le="5"} 2` + "\n",
+		`queuectl_job_queue_wait_seconds_bucket{queue="emails",le="+Inf"} 2` + "\n",
+		`queuectl_job_queue_wait_seconds_sum{queue="emails"} 2.2` + "\n",
+		`queuectl_job_queue_wait_seconds_count{queue="emails"} 2` + "\n",
+		`queuectl_job_duration_seconds_count{queue="emails"} 1` + "\n",
+		`queuectl_worker_busy{worker="host:1:\"1\""} 1` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics output missing %q\n%s", want, out)
+		}
+	}
+
+	var nilMetrics *metrics.Metrics
+	nilMetrics.JobCompleted("x") // must not panic
+}
+
+func TestServeExposesQueueGauges(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	if err := st.SetConfig(ctx, server.TokenKey, "tok"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+	if err := enqueueTestJob(st, "m2", "false", 1); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	job, err := st.ClaimOne(ctx, time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if _, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if err := enqueueTestJob(st, "m1", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	stats, err := st.QueueStatus(ctx)
+	if err != nil {
+		t.Fatalf("QueueStatus failed: %v", err)
+	}
+	if stats["pending"] != 1 || stats["dead"] != 1 || stats["processing"] != 0 {
+		t.Errorf("Unexpected status counts %v", stats)
+	}
+
+	ts := httptest.NewServer(server.New(st))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected /metrics to need the token, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/metrics", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	out := string(body)
+	for _, want := range []string{
+		`queuectl_jobs{state="pending"} 1`,
+		`queuectl_jobs{state="processing"} 0`,
+		"queuectl_dlq_size 1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWorkerMetricsEndpoint(t *testing.T) {
+	c := newClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := c.Enqueue(ctx, client.Job{ID: "w1", Args: []string{"true"}, Queue: "emails"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w, err := c.NewWorker(client.WithMetricsAddr(addr))
+	if err != nil {
+		t.Fatalf("Failed to create worker: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	time.Sleep(1 * time.Second)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	cancel()
+	<-done
+
+	out := string(body)
+	for _, want := range []string{
+		`queuectl_jobs_claimed_total{queue="emails"} 1`,
+		`queuectl_jobs_completed_total{queue="emails"} 1`,
+		`queuectl_job_duration_seconds_count{queue="emails"} 1`,
+		`queuectl_jobs{state="completed"} 1`,
+		"queuectl_worker_busy{worker=",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestStoppedWorkersLeaveTheBusyGauge(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := metrics.New()
+	pool := engine.NewPool(st)
+	pool.Metrics = m
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if out := metricsText(t, m); !strings.Contains(out, "queuectl_worker_busy{worker=") {
+		t.Errorf("Expected a running worker in the busy gauge\n%s", out)
+	}
+
+	cancel()
+	<-done
+	if out := metricsText(t, m); strings.Contains(out, "queuectl_worker_busy") {
+		t.Errorf("Expected stopped workers removed from the busy gauge\n%s", out)
+	}
+}
+
+func TestServerClearsBusyForLostJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	if err := st.SetConfig(ctx, server.TokenKey, "tok"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+	srv := server.New(st)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	rt := engine.NewRemoteTransport(ts.URL, "tok")
+
+	for _, id := range []string{"reaped", "lost"} {
+		if err := st.Enqueue(ctx, model.Job{ID: id, Args: []string{"true"}}); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+	// one worker's job is reaped through the server
+	reaped, err := rt.Claim(ctx, time.Now(), "gone", 100*time.Millisecond, "")
+	if err != nil || reaped == nil {
+		t.Fatalf("Failed to claim: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	lost, err := rt.Claim(ctx, time.Now(), "late", time.Minute, "")
+	if err != nil || lost == nil {
+		t.Fatalf("Failed to claim: %v", err)
+	}
+	if n, err := rt.ReapExpired(ctx, time.Now(), 2, 60); err != nil || n != 1 {
+		t.Fatalf("Expected one reaped job, got %d (%v)", n, err)
+	}
+	if out := metricsText(t, srv.Metrics); !strings.Contains(out, `queuectl_worker_busy{worker="late"} 1`) {
+		t.Errorf("Expected late still busy\n%s", out)
+	}
+
+	// the other reports after another process reaped its job
+	if _, err := st.ReapExpired(ctx, time.Now().UTC().Add(2*time.Minute), 2, 60); err != nil {
+		t.Fatalf("Failed to reap: %v", err)
+	}
+	if err := rt.Complete(ctx, lost, time.Now()); !errors.Is(err, store.ErrLeaseLost) {
+		t.Fatalf("Expected ErrLeaseLost, got %v", err)
+	}
+
+	out := metricsText(t, srv.Metrics)
+	for _, want := range []string{
+		`queuectl_worker_busy{worker="gone"} 0`,
+		`queuectl_worker_busy{worker="late"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Metrics output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func metricsText(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	return buf.String()
+}
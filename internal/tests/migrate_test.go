@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"queuectl/internal/store"
+)
+
+func countMigrations(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+		t.Fatalf("Failed to count migrations: %v", err)
+	}
+	return n
+}
+
+func hasIndex(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name=?`, name).Scan(&n); err != nil {
+		t.Fatalf("Failed to look up index: %v", err)
+	}
+	return n == 1
+}
+
+func TestNewStoreMigratesToLatest(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	v, err := st.SchemaVersion(ctx)
+	if err != nil || v != store.LatestSchemaVersion() {
+		t.Fatalf("Expected version %d, got %d (%v)", store.LatestSchemaVersion(), v, err)
+	}
+	if n := countMigrations(t, st.DB); n != store.LatestSchemaVersion() {
+		t.Errorf("Expected one row per migration, got %d", n)
+	}
+
+	// reopening an up to date database changes nothing
+	reopened, err := store.NewStore(dbPath(t, st))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.DB.Close()
+	if n := countMigrations(t, reopened.DB); n != store.LatestSchemaVersion() {
+		t.Errorf("Expected migrations to run once, got %d rows", n)
+	}
+}
+
+func TestUnversionedDatabaseIsUpgraded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	// the jobs table as the first release created it
+	_, err = db.Exec(`
+CREATE TABLE jobs (
+  id TEXT PRIMARY KEY,
+  command TEXT NOT NULL,
+  state TEXT NOT NULL CHECK (state IN ('pending','processing','completed','failed','dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_retries INTEGER NOT NULL DEFAULT 3,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  available_at TEXT NOT NULL
+);
+INSERT INTO jobs VALUES ('old-job', 'echo hi', 'pending', 0, 3,
+  '2025-01-02T15:04:05Z', '2025-01-02T15:04:05Z', '2025-01-02T15:04:05Z');
+`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+
+	st, err := store.NewStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer st.DB.Close()
+
+	job, err := st.GetJob(context.Background(), "old-job")
+	if err != nil {
+		t.Fatalf("Failed to read old job: %v", err)
+	}
+	if job.Queue != store.DefaultQueue || job.Priority != 0 {
+		t.Errorf("Expected new columns filled with defaults, got %+v", job)
+	}
+	if v, _ := st.SchemaVersion(context.Background()); v != store.LatestSchemaVersion() {
+		t.Errorf("Expected version %d, got %d", store.LatestSchemaVersion(), v)
+	}
+}
+
+func TestNewerDatabaseIsRefused(t *testing.T) {
+	st := newStore(t)
+	future := store.LatestSchemaVersion() + 1
+	if _, err := st.DB.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', '')`, future); err != nil {
+		t.Fatalf("Failed to fake a newer migration: %v", err)
+	}
+
+	if _, err := store.NewStore(dbPath(t, st)); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+
+	opened, err := store.OpenStore(dbPath(t, st))
+	if err != nil {
+		t.Fatalf("OpenStore should not migrate or refuse: %v", err)
+	}
+	defer opened.DB.Close()
+	ms, err := opened.Migrations(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	last := ms[len(ms)-1]
+	if last.Version != future || last.Name != "from the future" || !last.Applied || last.Reversible {
+		t.Errorf("Expected the unknown migration listed, got %+v", last)
+	}
+	if err := opened.MigrateDown(context.Background(), 1); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Errorf("Expected MigrateDown to refuse too, got %v", err)
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if v, _ := st.SchemaVersion(ctx); v != 1 {
+		t.Errorf("Expected version 1, got %d", v)
+	}
+	if hasIndex(t, st.DB, "idx_dlq_failed_at") {
+		t.Errorf("Expected migration 2 reverted")
+	}
+	if err := st.MigrateDown(ctx, 0); err == nil {
+		t.Errorf("Expected the baseline to be irreversible")
+	}
+	if err := st.MigrateUp(ctx, 0); err == nil {
+		t.Errorf("Expected MigrateUp to reject version 0")
+	}
+
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if !hasIndex(t, st.DB, "idx_dlq_failed_at") {
+		t.Errorf("Expected migration 2 applied again")
+	}
+}
+
+func TestConcurrentStartupsMigrateOnce(t *testing.T) {
+	// a lost race shows up only now and then, so start many rounds
+	for round := 0; round < 20; round++ {
+		path := filepath.Join(t.TempDir(), fmt.Sprintf("shared-%d.db", round))
+
+		var wg sync.WaitGroup
+		stores := make([]*store.Store, 8)
+		errs := make([]error, len(stores))
+		for i := range stores {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				stores[i], errs[i] = store.NewStore(path)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("Round %d: startup %d failed: %v", round, i, err)
+			}
+			defer stores[i].DB.Close()
+		}
+		if n := countMigrations(t, stores[0].DB); n != store.LatestSchemaVersion() {
+			t.Errorf("Round %d: expected each migration recorded once, got %d rows", round, n)
+		}
+		var mode string
+		if err := stores[0].DB.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+			t.Errorf("Round %d: expected WAL, got %q (%v)", round, mode, err)
+		}
+	}
+}
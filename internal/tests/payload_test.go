@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+)
+
+func TestWorkerRunsArgsWithoutShell(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	err := st.Enqueue(context.Background(), model.Job{
+		ID: "argv-job",
+		// a shell would expand these; exec passes them through untouched
+		Args:    []string{"printf", "%s|%s|%s", "$HOME", "a b", "$SECRET"},
+		Env:     map[string]string{"SECRET": "hunter2"},
+		Workdir: dir,
+	})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	err = st.Enqueue(context.Background(), model.Job{
+		ID:      "env-job",
+		Command: `printf '%s %s' "$SECRET" "$(pwd)"`,
+		Env:     map[string]string{"SECRET": "hunter2"},
+		Workdir: dir,
+	})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	go worker.Run(ctx)
+
+	time.Sleep(2 * time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	a, err := st.GetAttempt(context.Background(), "argv-job", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if a.Stdout != "$HOME|a b|$SECRET" {
+		t.Errorf("Expected args passed verbatim, got %q", a.Stdout)
+	}
+
+	a, err = st.GetAttempt(context.Background(), "env-job", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	if a.Stdout != "hunter2 "+dir && a.Stdout != "hunter2 "+realDir {
+		t.Errorf("Expected env and workdir to apply, got %q", a.Stdout)
+	}
+}
+
+func TestPayloadSurvivesDLQRetry(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	orig := model.Job{
+		ID:         "payload-job",
+		Args:       []string{"/bin/echo", "hi"},
+		Env:        map[string]string{"A": "1"},
+		Workdir:    os.TempDir(),
+		Queue:      "emails",
+		Priority:   7,
+		Timeout:    30,
+		MaxRetries: 1,
+	}
+	if err := st.Enqueue(ctx, orig); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	job, err := st.ClaimOne(ctx, time.Now().UTC())
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if _, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, errors.New("boom")); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if err := st.RetryDLQ(ctx, "payload-job"); err != nil {
+		t.Fatalf("Failed to retry job: %v", err)
+	}
+
+	jobs, err := st.ListJobs(ctx, "")
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("Expected the retried job back, got %d (%v)", len(jobs), err)
+	}
+	got := jobs[0]
+	gotJSON, _ := json.Marshal([]any{got.Args, got.Env, got.Workdir, got.Queue, got.Priority, got.Timeout})
+	wantJSON, _ := json.Marshal([]any{orig.Args, orig.Env, orig.Workdir, orig.Queue, orig.Priority, orig.Timeout})
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("Payload changed across DLQ retry: got %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestJobSpecPayloadValidation(t *testing.T) {
+	now := time.Now().UTC()
+	bad := []string{
+		`{"id":"x"}`,
+		`{"id":"x","command":"echo","args":["echo"]}`,
+		`{"id":"x","args":[""]}`,
+		`{"id":"x","args":["env"],"env":{"A=B":"c"}}`,
+	}
+	for _, raw := range bad {
+		var spec model.JobSpec
+		if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+			t.Fatalf("Failed to parse spec: %v", err)
+		}
+		if _, err := spec.ToJob(now); err == nil {
+			t.Errorf("Expected validation error for %s", raw)
+		}
+	}
+
+	var spec model.JobSpec
+	if err := json.Unmarshal([]byte(`{"id":"x","args":["ls","-l"],"env":{"A":"1"},"workdir":"/tmp"}`), &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	j, err := spec.ToJob(now)
+	if err != nil {
+		t.Fatalf("Expected valid spec: %v", err)
+	}
+	if j.Display() != `["ls","-l"]` {
+		t.Errorf("Unexpected display %s", j.Display())
+	}
+}
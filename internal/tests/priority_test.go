@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+func TestClaimHonorsPriority(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	jobs := []model.Job{
+		{ID: "batch-1", Command: "true", Priority: 0},
+		{ID: "batch-2", Command: "true", Priority: 0},
+		{ID: "urgent", Command: "true", Priority: 10},
+		{ID: "low", Command: "true", Priority: -5},
+	}
+	for _, j := range jobs {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+		time.Sleep(time.Millisecond) // distinct created_at
+	}
+
+	expected := []string{"urgent", "batch-1", "batch-2", "low"}
+	for _, id := range expected {
+		job, err := st.ClaimOne(ctx, time.Now().UTC())
+		if err != nil {
+			t.Fatalf("Failed to claim job: %v", err)
+		}
+		if job == nil {
+			t.Fatalf("Expected to claim job %s", id)
+		}
+		if job.ID != id {
+			t.Errorf("Expected to claim %s, got %s", id, job.ID)
+		}
+	}
+}
+
+func TestListFiltersAndSortsByPriority(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, j := range []model.Job{
+		{ID: "a", Command: "true", Priority: 1},
+		{ID: "b", Command: "true", Priority: 5},
+		{ID: "c", Command: "true", Priority: 3},
+	} {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+	}
+
+	min := 3
+	listed, err := st.ListJobsFiltered(ctx, store.JobFilter{MinPriority: &min, SortBy: "priority"})
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("Expected 2 jobs with priority >= 3, got %d", len(listed))
+	}
+	if listed[0].ID != "b" || listed[1].ID != "c" {
+		t.Errorf("Expected order [b c], got [%s %s]", listed[0].ID, listed[1].ID)
+	}
+
+	if _, err := st.ListJobsFiltered(ctx, store.JobFilter{SortBy: "bogus"}); err == nil {
+		t.Error("Expected an error for an unknown sort order")
+	}
+}
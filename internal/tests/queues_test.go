@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+)
+
+func TestClaimFromNamedQueue(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, j := range []model.Job{
+		{ID: "report-1", Command: "true", Queue: "reports"},
+		{ID: "email-1", Command: "true", Queue: "emails"},
+		{ID: "plain", Command: "true"},
+	} {
+		if err := st.Enqueue(ctx, j); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", j.ID, err)
+		}
+	}
+	now := time.Now().UTC()
+
+	job, err := st.Claim(ctx, now, "w1", time.Minute, "emails")
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if job == nil || job.ID != "email-1" {
+		t.Fatalf("Expected to claim email-1, got %+v", job)
+	}
+	if job.Queue != "emails" {
+		t.Errorf("Expected queue 'emails', got '%s'", job.Queue)
+	}
+
+	// emails is drained, nothing else leaks in
+	job, err = st.Claim(ctx, now, "w1", time.Minute, "emails")
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if job != nil {
+		t.Errorf("Expected no job from empty queue, got %s", job.ID)
+	}
+
+	job, err = st.Claim(ctx, now, "w1", time.Minute, "default")
+	if err != nil {
+		t.Fatalf("Failed to claim job: %v", err)
+	}
+	if job == nil || job.ID != "plain" {
+		t.Errorf("Expected jobs without a queue to land in 'default', got %+v", job)
+	}
+}
+
+func TestQueueConcurrencyLimit(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if err := st.Enqueue(ctx, model.Job{ID: id, Command: "true", Queue: "reports"}); err != nil {
+			t.Fatalf("Failed to enqueue job %s: %v", id, err)
+		}
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "e1", Command: "true", Queue: "emails"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.SetQueueLimit(ctx, "reports", 2); err != nil {
+		t.Fatalf("Failed to set queue limit: %v", err)
+	}
+	now := time.Now().UTC()
+
+	claimed := map[string]int{}
+	for i := 0; i < 4; i++ {
+		job, err := st.Claim(ctx, now, "w1", time.Minute, "")
+		if err != nil {
+			t.Fatalf("Failed to claim job: %v", err)
+		}
+		if job == nil {
+			break
+		}
+		claimed[job.Queue]++
+	}
+
+	if claimed["reports"] != 2 {
+		t.Errorf("Expected 2 reports jobs processing (limit), got %d", claimed["reports"])
+	}
+	if claimed["emails"] != 1 {
+		t.Errorf("Expected the emails job to be claimed despite the reports limit, got %d", claimed["emails"])
+	}
+
+	queues, err := st.ListQueues(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list queues: %v", err)
+	}
+	for _, q := range queues {
+		if q.Name == "reports" {
+			if q.Pending != 1 || q.Processing != 2 || q.MaxProcessing != 2 {
+				t.Errorf("Unexpected reports stats: %+v", q)
+			}
+		}
+	}
+}
+
+func TestParseQueues(t *testing.T) {
+	queues, err := engine.ParseQueues("emails:3, reports")
+	if err != nil {
+		t.Fatalf("Failed to parse queues: %v", err)
+	}
+	if len(queues) != 2 {
+		t.Fatalf("Expected 2 queues, got %d", len(queues))
+	}
+	if queues[0] != (engine.QueueWeight{Name: "emails", Weight: 3}) {
+		t.Errorf("Unexpected first queue: %+v", queues[0])
+	}
+	if queues[1] != (engine.QueueWeight{Name: "reports", Weight: 1}) {
+		t.Errorf("Unexpected second queue: %+v", queues[1])
+	}
+
+	for _, bad := range []string{"emails:0", "emails:x", "a,a", "bad name"} {
+		if _, err := engine.ParseQueues(bad); err == nil {
+			t.Errorf("Expected error parsing %q", bad)
+		}
+	}
+}
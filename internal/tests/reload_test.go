@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func waitForAttempts(t *testing.T, st *store.Store, id string, attempts int) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := getJob(st, id)
+		if err == nil && job.Attempts == attempts {
+			return job
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Job %s never reached %d attempts", id, attempts)
+	return nil
+}
+
+func TestSettingsChanges(t *testing.T) {
+	old := engine.Settings{Base: 2, Cap: 60, Lease: 30 * time.Second, PollInterval: 200 * time.Millisecond, Concurrency: 1}
+	cur := old
+	if changes := cur.Changes(old); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+
+	cur.Base = 3
+	cur.PollInterval = 50 * time.Millisecond
+	changes := cur.Changes(old)
+	want := []string{"backoff_base 2 -> 3", "poll_interval_ms 200 -> 50"}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, changes)
+	}
+}
+
+func TestPoolAppliesConfigChangesBetweenJobs(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "slow", Args: []string{"sleep", "2"}}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	pool := engine.NewPool(st)
+	pool.ReloadInterval = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	waitForState(t, st, "slow", "processing")
+
+	// a second worker and a longer backoff, while slow is still running
+	if err := st.SetConfig(ctx, "worker_concurrency", "2"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := st.SetConfig(ctx, "backoff_base", "7"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "fails", Args: []string{"false"}, MaxRetries: 2}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	job := waitForAttempts(t, st, "fails", 1)
+	if delay := job.AvailableAt.Sub(job.UpdatedAt); delay < 6*time.Second || delay > 8*time.Second {
+		t.Errorf("Expected the new backoff of 7s, got %s", delay)
+	}
+	if slow, _ := getJob(st, "slow"); slow.State != "processing" {
+		t.Errorf("Expected fails to run beside slow on the new worker, slow is %s", slow.State)
+	}
+
+	// shrinking the pool retires a worker but lets its job finish
+	if err := st.SetConfig(ctx, "worker_concurrency", "1"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	waitForState(t, st, "slow", "completed")
+	cancel()
+	<-done
+
+	if slow, _ := getJob(st, "slow"); slow.Attempts != 0 {
+		t.Errorf("Expected slow to complete on its first run, got %d attempts", slow.Attempts)
+	}
+}
+
+func TestRetiredWorkerFinishesItsJob(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.SetConfig(ctx, "worker_concurrency", "2"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	for _, id := range []string{"one", "two"} {
+		if err := st.Enqueue(ctx, model.Job{ID: id, Args: []string{"sleep", "1"}}); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+
+	pool := engine.NewPool(st)
+	pool.ReloadInterval = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	waitForState(t, st, "one", "processing")
+	waitForState(t, st, "two", "processing")
+
+	if err := st.SetConfig(ctx, "worker_concurrency", "1"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	waitForState(t, st, "one", "completed")
+	waitForState(t, st, "two", "completed")
+	cancel()
+	<-done
+}
+
+func TestClientWorkerOptionsSurviveReload(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := client.Open(dbPath(t, st))
+	if err != nil {
+		t.Fatalf("Failed to open client: %v", err)
+	}
+	defer c.Close()
+
+	w, err := c.NewWorker(client.WithBackoff(3, 60), client.WithConcurrency(1))
+	if err != nil {
+		t.Fatalf("Failed to create worker: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	if err := st.SetConfig(ctx, "backoff_base", "9"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if err := st.Enqueue(ctx, model.Job{ID: "pinned", Args: []string{"false"}, MaxRetries: 2}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	job := waitForAttempts(t, st, "pinned", 1)
+	if delay := job.AvailableAt.Sub(job.UpdatedAt); delay < 2*time.Second || delay > 4*time.Second {
+		t.Errorf("Expected WithBackoff's 3s to win over config, got %s", delay)
+	}
+	cancel()
+	<-done
+}
+
+func TestRemoteConfigVersion(t *testing.T) {
+	st, url := newRemote(t)
+	ctx := context.Background()
+	rt := engine.NewRemoteTransport(url, "tok")
+
+	before, err := rt.ConfigVersion(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read config version: %v", err)
+	}
+	if err := st.SetConfig(ctx, "poll_interval_ms", "50"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	after, err := rt.ConfigVersion(ctx)
+	if err != nil || after == before {
+		t.Errorf("Expected the version to move from %d, got %d (%v)", before, after, err)
+	}
+}
+
+func TestRetiringWorkerCountsTowardConcurrency(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.SetConfig(ctx, "worker_concurrency", "2"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	for _, id := range []string{"one", "two"} {
+		if err := st.Enqueue(ctx, model.Job{ID: id, Args: []string{"sleep", "2"}}); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+
+	pool := engine.NewPool(st)
+	pool.ReloadInterval = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	waitForState(t, st, "one", "processing")
+	waitForState(t, st, "two", "processing")
+
+	// lowering and raising again must not start a worker beside the
+	// retired one while it still runs its job
+	if err := st.SetConfig(ctx, "worker_concurrency", "1"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := st.SetConfig(ctx, "worker_concurrency", "2"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "extra", Args: []string{"true"}}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if extra, _ := getJob(st, "extra"); extra.State != "pending" {
+		t.Errorf("Expected extra to wait for a free worker, got %s", extra.State)
+	}
+
+	waitForState(t, st, "extra", "completed")
+	cancel()
+	<-done
+}
+
+func TestCancelledWorkerRecordsInterruptedJob(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "long", Args: []string{"sleep", "30"}}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	pool := engine.NewPool(st)
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+	waitForState(t, st, "long", "processing")
+
+	cancel()
+	<-done
+	job, err := st.GetJob(context.Background(), "long")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.State != "pending" || job.Attempts != 1 || job.LastError != "worker shut down" {
+		t.Errorf("Expected long handed back as a failed attempt, got %s %d %q", job.State, job.Attempts, job.LastError)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+	"queuectl/internal/server"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func newRemote(t *testing.T) (*store.Store, string) {
+	st := newStore(t)
+	if err := st.SetConfig(context.Background(), server.TokenKey, "tok"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+	ts := httptest.NewServer(server.New(st))
+	t.Cleanup(ts.Close)
+	return st, ts.URL
+}
+
+func TestRemoteWorkerRunsJobsOverHTTP(t *testing.T) {
+	st, url := newRemote(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "remote-ok", Args: []string{"echo", "from afar"}}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "remote-fail", Args: []string{"false"}, MaxRetries: 1}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "remote-unknown", Type: "nobody-handles-this", MaxRetries: 5}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	if _, err := client.NewRemoteWorker(url, "tok", client.WithScheduler()); err == nil {
+		t.Error("Expected the scheduler to be refused for remote workers")
+	}
+	w, err := client.NewRemoteWorker(url, "tok", client.WithConcurrency(2))
+	if err != nil {
+		t.Fatalf("Failed to create remote worker: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	time.Sleep(1500 * time.Millisecond)
+	cancel()
+	<-done
+
+	job, err := getJob(st, "remote-ok")
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.State != "completed" {
+		t.Errorf("Expected remote-ok completed, got %s", job.State)
+	}
+	a, err := st.GetAttempt(context.Background(), "remote-ok", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if a.Stdout != "from afar\n" || a.ExitCode != 0 || !a.Finished() {
+		t.Errorf("Expected output and exit code reported over HTTP, got %+v", a)
+	}
+
+	dlq, err := st.ListDLQ(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list DLQ: %v", err)
+	}
+	if len(dlq) != 2 {
+		t.Fatalf("Expected remote-fail and remote-unknown in the DLQ, got %+v", dlq)
+	}
+	for _, j := range dlq {
+		if j.Attempts != 1 {
+			t.Errorf("Expected %s dead after 1 attempt, got %d", j.ID, j.Attempts)
+		}
+	}
+}
+
+func TestRemoteTransportReportsLostLeases(t *testing.T) {
+	st, url := newRemote(t)
+	ctx := context.Background()
+
+	if err := enqueueTestJob(st, "owned", "true", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	rt := engine.NewRemoteTransport(url, "tok")
+	job, err := rt.Claim(ctx, time.Now(), "worker-a", 5*time.Second, "")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to claim job remotely: %v", err)
+	}
+	if job.ID != "owned" || job.WorkerID != "worker-a" {
+		t.Errorf("Unexpected claimed job %+v", job)
+	}
+	if none, err := rt.Claim(ctx, time.Now(), "worker-a", 5*time.Second, ""); err != nil || none != nil {
+		t.Errorf("Expected empty claim, got %v %v", none, err)
+	}
+
+	thief := *job
+	thief.WorkerID = "worker-b"
+	if err := rt.ExtendLease(ctx, &thief, time.Now().Add(time.Minute)); !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost heartbeating someone else's job, got %v", err)
+	}
+	if err := rt.Complete(ctx, &thief, time.Now()); !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost completing someone else's job, got %v", err)
+	}
+	if _, err := rt.StartAttempt(ctx, &thief, time.Now()); !errors.Is(err, store.ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost starting an attempt on someone else's job, got %v", err)
+	}
+
+	if err := rt.ExtendLease(ctx, job, time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("Owner heartbeat failed: %v", err)
+	}
+	if time.Until(job.LeaseExpiresAt) < 50*time.Second {
+		t.Errorf("Expected lease pushed out by the server, got %v", job.LeaseExpiresAt)
+	}
+	if err := rt.Complete(ctx, job, time.Now()); err != nil {
+		t.Errorf("Owner complete failed: %v", err)
+	}
+
+	bad := engine.NewRemoteTransport(url, "wrong")
+	if _, err := bad.Claim(ctx, time.Now(), "worker-a", time.Second, ""); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected 401 with a bad token, got %v", err)
+	}
+	if got := bad.MustGetInt("backoff_base", 7); got != 7 {
+		t.Errorf("Expected default when config is unreachable, got %d", got)
+	}
+	if got := rt.MustGetInt("backoff_base", 7); got != 2 {
+		t.Errorf("Expected server config value 2, got %d", got)
+	}
+}
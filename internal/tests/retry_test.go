@@ -16,13 +16,13 @@ func TestRetryIncrementsAttempts(t *testing.T) {
 	_ = (*store.Store)(nil) // Ensure store package is used
 	_ = (*model.Job)(nil)   // Ensure model package is used
 	ctx := context.Background()
-	now := time.Now().UTC()
 
 	// Enqueue a job
 	err := enqueueTestJob(st, "retry-job", "false", 3) // false command will fail
 	if err != nil {
 		t.Fatalf("Failed to enqueue job: %v", err)
 	}
+	now := time.Now().UTC()
 
 	// Claim the job
 	job, err := st.ClaimOne(ctx, now)
@@ -216,13 +216,13 @@ func TestRetryBackoffCap(t *testing.T) {
 func TestRetryJobNotClaimableUntilAvailableAt(t *testing.T) {
 	st := newStore(t)
 	ctx := context.Background()
-	now := time.Now().UTC()
 
 	// Enqueue a job
 	err := enqueueTestJob(st, "delay-job", "false", 3)
 	if err != nil {
 		t.Fatalf("Failed to enqueue job: %v", err)
 	}
+	now := time.Now().UTC()
 
 	// Claim and fail it
 	job, err := st.ClaimOne(ctx, now)
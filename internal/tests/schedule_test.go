@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"queuectl/internal/cron"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC) // a Wednesday
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 1, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// both day fields restricted: either may match
+		{"0 0 15 * fri", time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := cron.Parse(c.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", c.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q: expected next %v, got %v", c.expr, c.want, got)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * mon-", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := cron.Parse(bad); err == nil {
+			t.Errorf("Expected parse error for %q", bad)
+		}
+	}
+}
+
+func TestScheduleFiresOncePerTickAcrossSchedulers(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	created := time.Date(2025, 1, 1, 10, 0, 30, 0, time.UTC)
+
+	err := st.AddSchedule(ctx, model.Schedule{
+		Name:     "every-minute",
+		Cron:     "* * * * *",
+		Template: `{"command":"echo tick","queue":"cron"}`,
+	}, created)
+	if err != nil {
+		t.Fatalf("Failed to add schedule: %v", err)
+	}
+
+	// a second store on the same file acts as another scheduler process
+	other, err := store.NewStore(dbPath(t, st))
+	if err != nil {
+		t.Fatalf("Failed to open second store: %v", err)
+	}
+	defer other.DB.Close()
+
+	now := created.Add(40 * time.Second) // 10:01:10, tick 10:01 is due
+	var wg sync.WaitGroup
+	for _, s := range []*store.Store{st, other, st, other} {
+		wg.Add(1)
+		go func(s *store.Store) {
+			defer wg.Done()
+			// losers of the race may see a busy error, which a real loop retries
+			_, _ = s.FireDueSchedules(ctx, now)
+		}(s)
+	}
+	wg.Wait()
+
+	jobs, err := st.ListJobs(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("Expected exactly 1 job for the tick, got %d", len(jobs))
+	}
+	if jobs[0].ID != "every-minute-20250101T1001Z" {
+		t.Errorf("Unexpected job id %s", jobs[0].ID)
+	}
+	if jobs[0].Queue != "cron" || jobs[0].Command != "echo tick" {
+		t.Errorf("Job does not match template: %+v", jobs[0])
+	}
+
+	// firing again for the same instant does nothing
+	ids, err := st.FireDueSchedules(ctx, now)
+	if err != nil {
+		t.Fatalf("Failed to fire schedules: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("Expected no new jobs, got %v", ids)
+	}
+}
+
+func TestScheduleCatchupPolicies(t *testing.T) {
+	created := time.Date(2025, 1, 1, 10, 0, 30, 0, time.UTC)
+	// hourly ticks at 11:00, 12:00 and 13:00 were missed
+	onTime := time.Date(2025, 1, 1, 13, 0, 20, 0, time.UTC)
+	late := time.Date(2025, 1, 1, 13, 20, 0, 0, time.UTC)
+
+	cases := []struct {
+		catchup string
+		at      time.Time
+		want    int
+	}{
+		{"all", late, 3},
+		{"one", late, 1},
+		{"skip", late, 0},
+		{"skip", onTime, 1},
+	}
+
+	for _, c := range cases {
+		st := newStore(t)
+		ctx := context.Background()
+		err := st.AddSchedule(ctx, model.Schedule{
+			Name:     "hourly",
+			Cron:     "0 * * * *",
+			Template: `{"command":"true"}`,
+			Catchup:  c.catchup,
+		}, created)
+		if err != nil {
+			t.Fatalf("Failed to add schedule: %v", err)
+		}
+
+		ids, err := st.FireDueSchedules(ctx, c.at)
+		if err != nil {
+			t.Fatalf("Failed to fire schedules: %v", err)
+		}
+		if len(ids) != c.want {
+			t.Errorf("catchup=%s at %v: expected %d jobs, got %d (%v)", c.catchup, c.at, c.want, len(ids), ids)
+		}
+
+		// every policy moves on to the next future tick
+		schedules, err := st.ListSchedules(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list schedules: %v", err)
+		}
+		want := time.Date(2025, 1, 1, 14, 0, 0, 0, time.UTC)
+		if !schedules[0].NextRunAt.Equal(want) {
+			t.Errorf("catchup=%s: expected next run %v, got %v", c.catchup, want, schedules[0].NextRunAt)
+		}
+	}
+}
+
+func TestScheduleErrorsDoNotStopOthers(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	created := time.Date(2025, 1, 1, 10, 0, 30, 0, time.UTC)
+	add := func(name string) {
+		t.Helper()
+		err := st.AddSchedule(ctx, model.Schedule{Name: name, Cron: "* * * * *", Template: `{"command":"true"}`}, created)
+		if err != nil {
+			t.Fatalf("Failed to add schedule: %v", err)
+		}
+	}
+
+	add("a-readded")
+	if _, err := st.FireDueSchedules(ctx, time.Date(2025, 1, 1, 10, 1, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to fire schedules: %v", err)
+	}
+
+	// removed and added again, a-readded comes back to the 10:01 tick it
+	// already fired; b-broken can no longer build its job
+	if err := st.RemoveSchedule(ctx, "a-readded"); err != nil {
+		t.Fatalf("Failed to remove schedule: %v", err)
+	}
+	add("a-readded")
+	add("b-broken")
+	add("c-fine")
+	if _, err := st.DB.ExecContext(ctx, `UPDATE schedules SET template='{' WHERE name='b-broken'`); err != nil {
+		t.Fatalf("Failed to break schedule: %v", err)
+	}
+
+	ids, err := st.FireDueSchedules(ctx, time.Date(2025, 1, 1, 10, 1, 40, 0, time.UTC))
+	if err == nil || !strings.Contains(err.Error(), "schedule b-broken") || strings.Contains(err.Error(), "a-readded") {
+		t.Errorf("Expected only the error of b-broken, got %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "c-fine-20250101T1001Z" {
+		t.Errorf("Expected c-fine to fire, got %v", ids)
+	}
+
+	schedules, err := st.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list schedules: %v", err)
+	}
+	next := time.Date(2025, 1, 1, 10, 2, 0, 0, time.UTC)
+	for _, sc := range schedules {
+		if sc.Name == "a-readded" && !sc.NextRunAt.Equal(next) {
+			t.Errorf("Expected a-readded to move past the tick it already had, next run %v", sc.NextRunAt)
+		}
+	}
+}
+
+func TestScheduleRejectsBadInput(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	bad := []model.Schedule{
+		{Name: "a", Cron: "not cron", Template: `{"command":"true"}`},
+		{Name: "b", Cron: "* * * * *", Template: `{"id":"fixed","command":"true"}`},
+		{Name: "c", Cron: "* * * * *", Template: `{"command":""}`},
+		{Name: "d", Cron: "* * * * *", Template: `{"command":"true"}`, Catchup: "sometimes"},
+		{Name: "e", Cron: "0 0 30 2 *", Template: `{"command":"true"}`},
+	}
+	for _, sc := range bad {
+		if err := st.AddSchedule(ctx, sc, now); err == nil {
+			t.Errorf("Expected error adding schedule %s", sc.Name)
+		}
+	}
+}
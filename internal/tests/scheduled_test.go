@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+func TestJobSpecSchedulesJob(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	var spec model.JobSpec
+	if err := json.Unmarshal([]byte(`{"id":"later","command":"true","delay":"90s"}`), &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	j, err := spec.ToJob(now)
+	if err != nil {
+		t.Fatalf("Failed to build job: %v", err)
+	}
+	if !j.AvailableAt.Equal(now.Add(90 * time.Second)) {
+		t.Errorf("Expected available_at %v, got %v", now.Add(90*time.Second), j.AvailableAt)
+	}
+
+	spec = model.JobSpec{}
+	if err := json.Unmarshal([]byte(`{"id":"at","command":"true","run_at":"2025-01-03T08:00:00+02:00"}`), &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	j, err = spec.ToJob(now)
+	if err != nil {
+		t.Fatalf("Failed to build job: %v", err)
+	}
+	want := time.Date(2025, 1, 3, 6, 0, 0, 0, time.UTC)
+	if !j.AvailableAt.Equal(want) || j.AvailableAt.Location() != time.UTC {
+		t.Errorf("Expected available_at %v, got %v", want, j.AvailableAt)
+	}
+
+	bad := []string{
+		`{"id":"x","command":"true","delay":"soon"}`,
+		`{"id":"x","command":"true","delay":"-5s"}`,
+		`{"id":"x","command":"true","run_at":"tomorrow"}`,
+		`{"id":"x","command":"true","run_at":"2025-01-03T08:00:00Z","delay":"5s"}`,
+	}
+	for _, raw := range bad {
+		spec = model.JobSpec{}
+		if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+			t.Fatalf("Failed to parse spec: %v", err)
+		}
+		if _, err := spec.ToJob(now); err == nil {
+			t.Errorf("Expected validation error for %s", raw)
+		}
+	}
+}
+
+func TestListScheduledJobs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if err := st.Enqueue(ctx, model.Job{ID: "now", Command: "true", AvailableAt: now}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "later", Command: "true", AvailableAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	scheduled, err := st.ListJobsFiltered(ctx, store.JobFilter{State: store.StateScheduled})
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(scheduled) != 1 || scheduled[0].ID != "later" {
+		t.Fatalf("Expected only 'later' to be scheduled, got %+v", scheduled)
+	}
+
+	pending, err := st.ListJobs(ctx, "pending")
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Errorf("Expected scheduled jobs to still count as pending, got %d", len(pending))
+	}
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"queuectl/internal/server"
+	"queuectl/internal/store"
+)
+
+type apiClient struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func (a apiClient) do(method, path, body string) (int, map[string]any) {
+	a.t.Helper()
+	req, err := http.NewRequest(method, a.url+path, strings.NewReader(body))
+	if err != nil {
+		a.t.Fatalf("Failed to build request: %v", err)
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]any
+	if len(raw) > 0 && raw[0] == '{' {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			a.t.Fatalf("Bad json from %s %s: %s", method, path, raw)
+		}
+	} else {
+		out = map[string]any{"raw": string(raw)}
+	}
+	return resp.StatusCode, out
+}
+
+func newAPI(t *testing.T) (*store.Store, apiClient) {
+	st := newStore(t)
+	if err := st.SetConfig(context.Background(), server.TokenKey, "s3cret"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+	ts := httptest.NewServer(server.New(st))
+	t.Cleanup(ts.Close)
+	return st, apiClient{t: t, url: ts.URL, token: "s3cret"}
+}
+
+func TestServerRequiresBearerToken(t *testing.T) {
+	_, api := newAPI(t)
+
+	noToken := api
+	noToken.token = ""
+	if code, _ := noToken.do("GET", "/status", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+	wrong := api
+	wrong.token = "nope"
+	if code, _ := wrong.do("GET", "/status", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with wrong token, got %d", code)
+	}
+	if code, _ := api.do("GET", "/status", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 with token, got %d", code)
+	}
+
+	code, body := api.do("GET", "/config/api_token", "")
+	if code != http.StatusOK || body["value"] == "s3cret" {
+		t.Errorf("Expected token to be redacted, got %d %v", code, body)
+	}
+}
+
+func TestServerUnsetTokenRefusesRequests(t *testing.T) {
+	st := newStore(t)
+	ts := httptest.NewServer(server.New(st))
+	defer ts.Close()
+
+	api := apiClient{t: t, url: ts.URL}
+	if code, _ := api.do("GET", "/status", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 while api_token is unset, got %d", code)
+	}
+
+	open := server.New(st)
+	open.AllowNoAuth = true
+	ts2 := httptest.NewServer(open)
+	defer ts2.Close()
+	api.url = ts2.URL
+	if code, _ := api.do("GET", "/status", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 with AllowNoAuth, got %d", code)
+	}
+}
+
+func TestServerJobEndpoints(t *testing.T) {
+	_, api := newAPI(t)
+
+	code, body := api.do("POST", "/jobs", `{"id":"web1","command":"echo hi","priority":3,"delay":"1h"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d %v", code, body)
+	}
+	if body["id"] != "web1" || body["state"] != "pending" || body["priority"] != float64(3) {
+		t.Errorf("Unexpected enqueued job %v", body)
+	}
+	runAt, _ := time.Parse(time.RFC3339Nano, body["run_at"].(string))
+	if time.Until(runAt) < 59*time.Minute {
+		t.Errorf("Expected delay to apply, run_at %v", runAt)
+	}
+
+	if code, body := api.do("POST", "/jobs", `{"id":"web1","command":"echo hi"}`); code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate id, got %d %v", code, body)
+	}
+	if code, _ := api.do("POST", "/jobs", `{"id":"web2"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for job without command, got %d", code)
+	}
+	if code, _ := api.do("POST", "/jobs", `{"id":"web3","command":"x","depends_on":["nope"]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown dependency, got %d", code)
+	}
+	if code, _ := api.do("POST", "/jobs", `not json`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for bad json, got %d", code)
+	}
+
+	if code, body := api.do("GET", "/jobs/web1", ""); code != http.StatusOK || body["command"] != "echo hi" {
+		t.Errorf("Expected job back, got %d %v", code, body)
+	}
+	if code, _ := api.do("GET", "/jobs/missing", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+
+	code, body = api.do("GET", "/jobs?state=scheduled&min_priority=2", "")
+	if code != http.StatusOK || !strings.Contains(body["raw"].(string), `"web1"`) {
+		t.Errorf("Expected filtered list to contain web1, got %d %v", code, body)
+	}
+	if code, _ := api.do("GET", "/jobs?sort=sideways", ""); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for bad sort, got %d", code)
+	}
+
+	if code, body := api.do("POST", "/jobs/web1/cancel", ""); code != http.StatusOK || body["state"] != "cancelled" {
+		t.Errorf("Expected cancelled job, got %d %v", code, body)
+	}
+	if code, _ := api.do("POST", "/jobs/web1/cancel", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling twice, got %d", code)
+	}
+
+	code, body = api.do("GET", "/status", "")
+	if code != http.StatusOK || body["cancelled"] != float64(1) {
+		t.Errorf("Expected one cancelled job in status, got %v", body)
+	}
+}
+
+func TestServerDLQAndConfigEndpoints(t *testing.T) {
+	st, api := newAPI(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"dead1", "dead2"} {
+		if err := enqueueTestJob(st, id, "false", 1); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		job, err := st.ClaimOne(ctx, time.Now().UTC())
+		if err != nil || job == nil {
+			t.Fatalf("Failed to claim job: %v", err)
+		}
+		if _, err := st.FailRetry(ctx, job, time.Now().UTC(), 2, 60, errors.New("boom")); err != nil {
+			t.Fatalf("Failed to fail job: %v", err)
+		}
+	}
+
+	code, body := api.do("GET", "/dlq", "")
+	if code != http.StatusOK || strings.Count(body["raw"].(string), `"state":"dead"`) != 2 {
+		t.Errorf("Expected two DLQ jobs, got %d %v", code, body)
+	}
+
+	if code, body := api.do("POST", "/dlq/dead1/retry", ""); code != http.StatusOK || body["state"] != "pending" {
+		t.Errorf("Expected retried job, got %d %v", code, body)
+	}
+	if code, _ := api.do("POST", "/dlq/dead1/retry", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404 retrying a job not in the DLQ, got %d", code)
+	}
+	if code, _ := api.do("DELETE", "/dlq/nope", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404 purging unknown job, got %d", code)
+	}
+	if code, body := api.do("DELETE", "/dlq", ""); code != http.StatusOK || body["purged"] != float64(1) {
+		t.Errorf("Expected one job purged, got %d %v", code, body)
+	}
+
+	if code, body := api.do("PUT", "/config/backoff_base", `{"value":"3"}`); code != http.StatusOK || body["value"] != "3" {
+		t.Errorf("Expected config set, got %d %v", code, body)
+	}
+	if code, body := api.do("GET", "/config/backoff_base", ""); code != http.StatusOK || body["value"] != "3" {
+		t.Errorf("Expected config value 3, got %d %v", code, body)
+	}
+	if code, _ := api.do("GET", "/config/unknown_key", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unset config, got %d", code)
+	}
+	if code, body := api.do("GET", "/config", ""); code != http.StatusOK || body["api_token"] == "s3cret" {
+		t.Errorf("Expected config list with redacted token, got %d %v", code, body)
+	}
+	if code, _ := api.do("PUT", "/config/backoff_base", `{"value":"-1"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid value, got %d", code)
+	}
+	if code, _ := api.do("PUT", "/config/unknown_key", `{"value":"1"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown key, got %d", code)
+	}
+	if code, body := api.do("DELETE", "/config/backoff_base", ""); code != http.StatusOK || body["value"] != "2" {
+		t.Errorf("Expected config reset to the default, got %d %v", code, body)
+	}
+}
+
+func TestServerOnMemoryBackend(t *testing.T) {
+	st := store.NewMemStore()
+	if err := st.SetConfig(context.Background(), server.TokenKey, "mem"); err != nil {
+		t.Fatalf("Failed to set token: %v", err)
+	}
+	ts := httptest.NewServer(server.New(st))
+	t.Cleanup(ts.Close)
+	api := apiClient{t: t, url: ts.URL, token: "mem"}
+
+	code, body := api.do("POST", "/jobs", `{"id": "in-memory", "command": "true"}`)
+	if code != http.StatusCreated && code != http.StatusOK {
+		t.Fatalf("Expected the job accepted, got %d %v", code, body)
+	}
+	if j, err := st.GetJob(context.Background(), "in-memory"); err != nil || j.State != "pending" {
+		t.Errorf("Expected the job in the memory store, got %+v (%v)", j, err)
+	}
+	if code, body := api.do("GET", "/status", ""); code != http.StatusOK || body["pending"] != float64(1) {
+		t.Errorf("Expected one pending job in status, got %d %v", code, body)
+	}
+}
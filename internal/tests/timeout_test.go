@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/model"
+)
+
+func TestJobTimeoutMovesToDLQWithReason(t *testing.T) {
+	st := newStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := st.Enqueue(context.Background(), model.Job{
+		ID:         "hung-job",
+		Command:    "sleep 30",
+		MaxRetries: 1,
+		Timeout:    1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	worker := engine.NewWorker(st)
+	worker.KillGrace = 500 * time.Millisecond
+	go worker.Run(ctx)
+
+	time.Sleep(3 * time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	var lastError string
+	err = st.DB.QueryRowContext(context.Background(), `SELECT last_error FROM dlq WHERE id=?`, "hung-job").Scan(&lastError)
+	if err != nil {
+		t.Fatalf("Expected job in DLQ: %v", err)
+	}
+	if lastError != "timed out after 1s" {
+		t.Errorf("Expected last_error 'timed out after 1s', got '%s'", lastError)
+	}
+
+	a, err := st.GetAttempt(context.Background(), "hung-job", 0)
+	if err != nil || a == nil {
+		t.Fatalf("Failed to get attempt: %v", err)
+	}
+	if a.Signal != "terminated" {
+		t.Errorf("Expected attempt to be killed by SIGTERM, got signal %q", a.Signal)
+	}
+	if a.Error != "timed out after 1s" {
+		t.Errorf("Expected attempt error 'timed out after 1s', got '%s'", a.Error)
+	}
+}
+
+func TestEnqueueDefaultsTimeoutFromConfig(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	if err := st.SetConfig(ctx, "job_timeout_seconds", "45"); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	if err := st.Enqueue(ctx, model.Job{ID: "default-timeout", Command: "true"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := st.Enqueue(ctx, model.Job{ID: "own-timeout", Command: "true", Timeout: 5}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	jobs, err := st.ListJobs(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	timeouts := map[string]int{}
+	for _, j := range jobs {
+		timeouts[j.ID] = j.Timeout
+	}
+	if timeouts["default-timeout"] != 45 {
+		t.Errorf("Expected default timeout 45, got %d", timeouts["default-timeout"])
+	}
+	if timeouts["own-timeout"] != 5 {
+		t.Errorf("Expected explicit timeout 5, got %d", timeouts["own-timeout"])
+	}
+}
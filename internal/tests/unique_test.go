@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+func TestEnqueueGeneratesSortableIDs(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		res, err := st.EnqueueJob(ctx, model.Job{Command: "true"})
+		if err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		if len(res.ID) != 26 || res.Deduplicated {
+			t.Fatalf("Expected a new job with a 26 char ULID, got %+v", res)
+		}
+		ids = append(ids, res.ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("Expected increasing ids, got %v", ids)
+		}
+	}
+	if _, err := getJob(st, ids[0]); err != nil {
+		t.Errorf("Failed to get generated job: %v", err)
+	}
+}
+
+func TestEnqueueRejectsIDStillInDLQ(t *testing.T) {
+	st := newStore(t)
+	if err := enqueueTestJob(st, "dead1", "false", 3); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	killJob(t, st, "dead1", "boom")
+
+	err := st.Enqueue(context.Background(), model.Job{ID: "dead1", Command: "true"})
+	if !errors.Is(err, store.ErrDuplicateID) {
+		t.Errorf("Expected ErrDuplicateID for an id in the DLQ, got %v", err)
+	}
+}
+
+func TestUniqueKeyHeldWhileJobIsActive(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	first, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "report:42"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	second, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "report:42"})
+	if err != nil {
+		t.Fatalf("Duplicate enqueue should not fail: %v", err)
+	}
+	if !second.Deduplicated || second.ID != first.ID {
+		t.Errorf("Expected deduplicated result naming %s, got %+v", first.ID, second)
+	}
+
+	other, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "report:43"})
+	if err != nil || other.Deduplicated {
+		t.Errorf("Expected a different key to enqueue, got %+v (%v)", other, err)
+	}
+
+	if _, err := st.DB.Exec(`UPDATE jobs SET state='completed' WHERE id=?`, first.ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	third, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "report:42"})
+	if err != nil || third.Deduplicated || third.ID == first.ID {
+		t.Errorf("Expected a new job once the first completed, got %+v (%v)", third, err)
+	}
+}
+
+func TestUniqueKeyHeldForWindow(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	first, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "sync", UniqueFor: 600})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := st.DB.Exec(`UPDATE jobs SET state='completed' WHERE id=?`, first.ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	again, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "sync", UniqueFor: 600})
+	if err != nil || !again.Deduplicated || again.ID != first.ID {
+		t.Errorf("Expected the completed job to hold the key inside the window, got %+v (%v)", again, err)
+	}
+
+	// move the first job out of the window
+	old := time.Now().UTC().Add(-11 * time.Minute).Format(store.TimeLayout)
+	if _, err := st.DB.Exec(`UPDATE jobs SET created_at=? WHERE id=?`, old, first.ID); err != nil {
+		t.Fatalf("Failed to age job: %v", err)
+	}
+	later, err := st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "sync", UniqueFor: 600})
+	if err != nil || later.Deduplicated {
+		t.Errorf("Expected a new job after the window, got %+v (%v)", later, err)
+	}
+}
+
+func TestConcurrentUniqueEnqueuesCreateOneJob(t *testing.T) {
+	st := newStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	results := make([]store.EnqueueResult, 8)
+	errs := make([]error, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = st.EnqueueJob(ctx, model.Job{Command: "true", UniqueKey: "once"})
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for i, res := range results {
+		if errs[i] != nil {
+			t.Fatalf("Enqueue %d failed: %v", i, errs[i])
+		}
+		if !res.Deduplicated {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected exactly one job created, got %d", created)
+	}
+}
+
+func TestClientEnqueueReportsDeduplicated(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	first, err := c.Enqueue(ctx, client.Job{Command: "echo hi", UniqueKey: "k"})
+	if err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if first.ID == "" || first.Deduplicated {
+		t.Fatalf("Expected a new job with a generated id, got %+v", first)
+	}
+	dup, err := c.Enqueue(ctx, client.Job{Command: "echo other", UniqueKey: "k"})
+	if err != nil {
+		t.Fatalf("Duplicate enqueue should not fail: %v", err)
+	}
+	if !dup.Deduplicated || dup.ID != first.ID || dup.Command != "echo hi" {
+		t.Errorf("Expected the existing job back, got %+v", dup)
+	}
+
+	if _, err := c.Enqueue(ctx, client.Job{Command: "true", UniqueFor: 60}); err == nil {
+		t.Errorf("Expected unique_for without unique_key to be rejected")
+	}
+}
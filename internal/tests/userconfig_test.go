@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"queuectl/internal/userconfig"
+)
+
+func TestResolveDatabasePrecedence(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", dataDir)
+	t.Setenv(userconfig.EnvDB, "")
+
+	check := func(flagDB, flagContext, wantPath, wantSource string) {
+		t.Helper()
+		path, source, err := userconfig.Resolve(flagDB, flagContext)
+		if err != nil {
+			t.Fatalf("Resolve(%q, %q) failed: %v", flagDB, flagContext, err)
+		}
+		if path != wantPath || source != wantSource {
+			t.Errorf("Resolve(%q, %q) = %s (%s), want %s (%s)", flagDB, flagContext, path, source, wantPath, wantSource)
+		}
+	}
+
+	check("", "", filepath.Join(dataDir, "queuectl", "queue.db"), "default")
+
+	cfg, err := userconfig.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Contexts["staging"] = userconfig.Context{DB: "/srv/staging.db"}
+	cfg.Contexts["prod"] = userconfig.Context{DB: "/srv/prod.db"}
+	cfg.CurrentContext = "staging"
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	check("", "", "/srv/staging.db", "context staging")
+	t.Setenv(userconfig.EnvDB, "/tmp/env.db")
+	check("", "", "/tmp/env.db", "$QUEUECTL_DB")
+	check("", "prod", "/srv/prod.db", "context prod")
+	check("flag.db", "", "flag.db", "--db")
+
+	if _, _, err := userconfig.Resolve("", "missing"); err == nil {
+		t.Errorf("Expected an unknown context to fail")
+	}
+
+	reloaded, err := userconfig.Load()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if names := reloaded.Names(); len(names) != 2 || names[0] != "prod" || reloaded.CurrentContext != "staging" {
+		t.Errorf("Expected contexts to round trip, got %v (current %q)", names, reloaded.CurrentContext)
+	}
+}
@@ -0,0 +1,71 @@
+// Package ulid generates ULIDs: 26 character, lexically sortable ids made
+// of a millisecond timestamp and 80 random bits, in Crockford's base32.
+package ulid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	mu       sync.Mutex
+	lastMS   uint64
+	lastRand [10]byte
+)
+
+// New returns a ULID for the current time. Ids made in the same
+// millisecond by this process increase, so they still sort in order.
+func New() string {
+	return NewAt(time.Now())
+}
+
+// NewAt returns a ULID for t.
+func NewAt(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+
+	mu.Lock()
+	// in the same millisecond, bump the random part instead of drawing again
+	if ms != lastMS || !increment(&lastRand) {
+		lastMS = ms
+		if _, err := rand.Read(lastRand[:]); err != nil {
+			panic("ulid: reading random bytes: " + err.Error())
+		}
+	}
+	r := lastRand
+	mu.Unlock()
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	copy(b[6:], r[:])
+	return encode(b)
+}
+
+// increment adds one to r, reporting false when it overflows.
+func increment(r *[10]byte) bool {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]++
+		if r[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encode writes the 128 bits of b as 26 base32 digits, most significant
+// first; the first digit carries only 3 bits.
+func encode(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = alphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
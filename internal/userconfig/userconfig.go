@@ -0,0 +1,161 @@
+// Package userconfig holds queuectl's per-user settings: named contexts that
+// point at queue databases, and the rules for picking which database a
+// command talks to.
+package userconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// EnvDB names the environment variable that selects the database when no
+// --db or --context flag is given.
+const EnvDB = "QUEUECTL_DB"
+
+// Context is a named database location.
+type Context struct {
+	DB string `json:"db"`
+}
+
+// Config is the contents of the user config file.
+type Config struct {
+	CurrentContext string             `json:"current_context,omitempty"`
+	Contexts       map[string]Context `json:"contexts,omitempty"`
+}
+
+// Path returns the user config file: queuectl/config.json under
+// $XDG_CONFIG_HOME, ~/.config or the platform's equivalent.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config dir: %w", err)
+	}
+	return filepath.Join(dir, "queuectl", "config.json"), nil
+}
+
+// DefaultDBPath returns the database used when nothing else picks one:
+// queuectl/queue.db under $XDG_DATA_HOME, or ~/.local/share.
+func DefaultDBPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" && runtime.GOOS == "windows" {
+		d, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("locate data dir: %w", err)
+		}
+		dir = d
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("locate data dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "queuectl", "queue.db"), nil
+}
+
+// Load reads the user config file. A missing file is an empty config.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{Contexts: map[string]Context{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = map[string]Context{}
+	}
+	return cfg, nil
+}
+
+// Save writes the config file, replacing it in one rename so a crash never
+// leaves it half written.
+func (c *Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Names returns the context names in order.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateContextName rejects names that would be awkward on a command line.
+func ValidateContextName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n/\\") {
+		return fmt.Errorf("invalid context name %q", name)
+	}
+	return nil
+}
+
+// Resolve picks the database a command uses, first match wins: the --db
+// flag, the --context flag, $QUEUECTL_DB, the current context, then
+// DefaultDBPath. It also returns a short description of where the path came
+// from.
+func Resolve(flagDB, flagContext string) (path, source string, err error) {
+	if flagDB != "" {
+		return flagDB, "--db", nil
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		return "", "", err
+	}
+	if flagContext != "" {
+		ctx, ok := cfg.Contexts[flagContext]
+		if !ok {
+			return "", "", fmt.Errorf("unknown context %q", flagContext)
+		}
+		return ctx.DB, "context " + flagContext, nil
+	}
+	if env := os.Getenv(EnvDB); env != "" {
+		return env, "$" + EnvDB, nil
+	}
+	if cfg.CurrentContext != "" {
+		ctx, ok := cfg.Contexts[cfg.CurrentContext]
+		if !ok {
+			return "", "", fmt.Errorf("current context %q is not defined, run `queuectl context use` with another", cfg.CurrentContext)
+		}
+		return ctx.DB, "context " + cfg.CurrentContext, nil
+	}
+
+	path, err = DefaultDBPath()
+	if err != nil {
+		return "", "", err
+	}
+	return path, "default", nil
+}
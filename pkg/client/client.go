@@ -0,0 +1,240 @@
+// Package client is the public Go API for queuectl. It lets other programs
+// enqueue and inspect jobs and run workers against a queue database, or a
+// queue in memory (OpenMemory), without going through the CLI.
+//
+//	c, err := client.Open("queue.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	job, err := c.Enqueue(ctx, client.Job{ID: "report-42", Command: "./report.sh"})
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+)
+
+var (
+	// ErrNotFound means no job has the given id.
+	ErrNotFound = store.ErrNotFound
+	// ErrDuplicateID means Enqueue was given an id that is already queued.
+	ErrDuplicateID = store.ErrDuplicateID
+	// ErrInvalidState means the job's state does not allow the operation,
+	// such as cancelling a job that already finished.
+	ErrInvalidState = store.ErrInvalidState
+)
+
+// Client is a handle on one queue. It is safe for concurrent use.
+type Client struct {
+	st store.Backend
+}
+
+// Open opens the queue database at path, creating and migrating it if
+// needed.
+func Open(path string) (*Client, error) {
+	st, err := store.NewStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{st: st}, nil
+}
+
+// OpenMemory returns a client on a new queue kept in memory, for tests and
+// for programs that embed queuectl without needing the queue to outlive
+// them. Recurring jobs (WithScheduler), queue limits and retention need the
+// database and are not available.
+func OpenMemory() *Client {
+	return &Client{st: store.NewMemStore()}
+}
+
+// NewFromStore wraps a backend that is already open. It exists for
+// queuectl's own commands, which share one store between the client and
+// the rest.
+func NewFromStore(st store.Backend) *Client {
+	return &Client{st: st}
+}
+
+// Close closes the queue.
+func (c *Client) Close() error {
+	return c.st.Close()
+}
+
+// sqlite returns the SQLite store behind the client, or nil when the queue
+// is kept elsewhere. Schedules and retention only exist there.
+func (c *Client) sqlite() *store.Store {
+	st, _ := c.st.(*store.Store)
+	return st
+}
+
+// Enqueue validates j and adds it to the queue, returning the job as stored.
+func (c *Client) Enqueue(ctx context.Context, j Job) (*Job, error) {
+	m, err := j.validate(time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.st.EnqueueJob(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := c.Get(ctx, res.ID)
+	if err != nil {
+		return nil, err
+	}
+	stored.Deduplicated = res.Deduplicated
+	return stored, nil
+}
+
+// BatchResult is the outcome of one job passed to EnqueueBatch.
+type BatchResult struct {
+	ID           string
+	Deduplicated bool
+	// Err is why the job was not enqueued.
+	Err error
+}
+
+// EnqueueBatch validates and adds many jobs at once, returning one result
+// per job in order. Jobs may depend on jobs earlier in the batch.
+//
+// With atomic set nothing is enqueued unless every job is: the returned
+// error names the first bad job and its result holds the reason. Otherwise
+// bad jobs are skipped, with the reason in their result, and the rest are
+// enqueued; the returned error is then only set when the database fails.
+func (c *Client) EnqueueBatch(ctx context.Context, jobs []Job, atomic bool) ([]BatchResult, error) {
+	now := time.Now().UTC()
+	results := make([]BatchResult, len(jobs))
+	valid := make([]model.Job, 0, len(jobs))
+	index := make([]int, 0, len(jobs))
+	for i, j := range jobs {
+		m, err := j.validate(now)
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				return results, fmt.Errorf("job %d: %w (nothing was enqueued)", i+1, err)
+			}
+			continue
+		}
+		valid = append(valid, m)
+		index = append(index, i)
+	}
+
+	stored, err := c.st.EnqueueBatch(ctx, valid, atomic)
+	for k, res := range stored {
+		results[index[k]] = BatchResult{ID: res.ID, Deduplicated: res.Deduplicated, Err: res.Err}
+	}
+	return results, err
+}
+
+// Get returns the job with the given id, including jobs that ended up in
+// the DLQ (state "dead").
+func (c *Client) Get(ctx context.Context, id string) (*Job, error) {
+	m, err := c.st.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	j := fromModel(*m)
+	if j.State == "blocked" {
+		blockedOn, err := c.st.BlockedOn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		j.WaitingOn = blockedOn[j.ID]
+	}
+	return &j, nil
+}
+
+// List returns the queued jobs matching opts. DLQ jobs are not included.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]Job, error) {
+	ms, err := c.st.ListJobsFiltered(ctx, store.JobFilter{
+		State:       opts.State,
+		Queue:       opts.Queue,
+		MinPriority: opts.MinPriority,
+		SortBy:      opts.SortBy,
+		Limit:       opts.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var blockedOn map[string][]string
+	jobs := make([]Job, 0, len(ms))
+	for _, m := range ms {
+		j := fromModel(m)
+		if j.State == "blocked" {
+			if blockedOn == nil {
+				if blockedOn, err = c.st.BlockedOn(ctx); err != nil {
+					return nil, err
+				}
+			}
+			j.WaitingOn = blockedOn[j.ID]
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// Status counts the jobs in each state, with the DLQ counted as dead.
+func (c *Client) Status(ctx context.Context) (map[string]int, error) {
+	return c.st.QueueStatus(ctx)
+}
+
+// Cancel stops a pending, scheduled or blocked job from ever being claimed.
+// A running job is only flagged and running is true: the worker running it
+// notices within a heartbeat, kills it and marks it cancelled. It returns
+// ErrInvalidState for jobs that already finished.
+func (c *Client) Cancel(ctx context.Context, id string) (running bool, err error) {
+	return c.st.CancelJob(ctx, id, time.Now().UTC())
+}
+
+// RetryDead moves a job from the DLQ back to the queue with its attempts
+// reset. It returns ErrNotFound when the job is not in the DLQ.
+func (c *Client) RetryDead(ctx context.Context, id string) error {
+	return c.st.RetryDLQ(ctx, id)
+}
+
+// RetryDeadWhere moves every DLQ job matching f back to the queue in one
+// transaction and returns their ids. If any of them cannot be retried, for
+// example because a job with the same id was enqueued since, none are.
+func (c *Client) RetryDeadWhere(ctx context.Context, f DeadFilter) ([]string, error) {
+	return c.st.RetryDLQWhere(ctx, f.toStore())
+}
+
+// GetDead returns a job from the DLQ, with its last error and when it
+// failed. It returns ErrNotFound when the job is not in the DLQ.
+func (c *Client) GetDead(ctx context.Context, id string) (Job, error) {
+	m, err := c.st.GetDLQJob(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return fromModel(*m), nil
+}
+
+// ListDead returns the jobs in the DLQ, most recently failed first.
+func (c *Client) ListDead(ctx context.Context) ([]Job, error) {
+	ms, err := c.st.ListDLQ(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, 0, len(ms))
+	for _, m := range ms {
+		jobs = append(jobs, fromModel(m))
+	}
+	return jobs, nil
+}
+
+// PurgeDead deletes the given jobs from the DLQ, or the whole DLQ when no
+// ids are given, and reports how many were removed.
+func (c *Client) PurgeDead(ctx context.Context, ids ...string) (int, error) {
+	return c.st.PurgeDLQ(ctx, ids...)
+}
+
+// PurgeDeadWhere deletes the DLQ jobs matching f and reports how many were
+// removed.
+func (c *Client) PurgeDeadWhere(ctx context.Context, f DeadFilter) (int, error) {
+	return c.st.PurgeDLQWhere(ctx, f.toStore())
+}
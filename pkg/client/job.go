@@ -0,0 +1,170 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"queuectl/internal/model"
+	"queuectl/internal/store"
+	"time"
+)
+
+// Job is a unit of work in the queue. Set exactly one of Command (run with
+// bash -lc), Args (executed directly) or Type (a registered Go handler,
+// called with Payload).
+type Job struct {
+	ID      string            `json:"id"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Workdir string            `json:"workdir,omitempty"`
+	Type    string            `json:"type,omitempty"`
+	Payload json.RawMessage   `json:"payload,omitempty"`
+
+	Queue      string `json:"queue,omitempty"`
+	Priority   int    `json:"priority,omitempty"`
+	MaxRetries int    `json:"max_retries,omitempty"`
+	// Timeout is how many seconds a run may take, 0 for the configured
+	// default.
+	Timeout   int      `json:"timeout,omitempty"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	// RunAt delays the job until the given time; zero means now.
+	RunAt time.Time `json:"run_at,omitempty"`
+	// UniqueKey makes Enqueue return the existing job holding the same key
+	// instead of adding another. The key is held while that job is pending,
+	// blocked or processing, or, when UniqueFor is set, for UniqueFor seconds
+	// after it was created.
+	UniqueKey string `json:"unique_key,omitempty"`
+	UniqueFor int    `json:"unique_for,omitempty"`
+
+	// Filled in by the queue.
+	State     string    `json:"state,omitempty"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// WaitingOn lists the unfinished parents of a blocked job, as
+	// "id (state)".
+	WaitingOn []string `json:"waiting_on,omitempty"`
+	// CancelRequested is set on a running job until its worker stops it.
+	CancelRequested bool `json:"cancel_requested,omitempty"`
+	// LastError is the error of the most recent failed run, kept when the
+	// job is retried from the DLQ.
+	LastError string `json:"last_error,omitempty"`
+	// FailedAt is when a dead job entered the DLQ.
+	FailedAt time.Time `json:"failed_at,omitempty"`
+	// Deduplicated is set on the job Enqueue returns when it is an existing
+	// job holding the same unique key, and nothing was added.
+	Deduplicated bool `json:"deduplicated,omitempty"`
+}
+
+// ListOptions narrows and orders List results. The zero value lists every
+// queued job, oldest first.
+type ListOptions struct {
+	// State is a job state, or "scheduled" for pending jobs not yet due.
+	State       string
+	Queue       string
+	MinPriority *int
+	// SortBy is "created" (default) or "priority" (claim order).
+	SortBy string
+	// Limit caps how many jobs are returned; 0 returns all of them.
+	Limit int
+}
+
+// DeadFilter selects DLQ jobs for RetryDeadWhere and PurgeDeadWhere. The
+// zero value matches every DLQ job.
+type DeadFilter struct {
+	// IDs, when set, limits the filter to these jobs.
+	IDs []string
+	// Command is a glob (* matches anything, case sensitive) tried against
+	// the job's command, its args json and its handler type.
+	Command string
+	// Since keeps jobs that failed at or after this time.
+	Since time.Time
+	// Before keeps jobs that failed before this time.
+	Before time.Time
+}
+
+func (f DeadFilter) toStore() store.DLQFilter {
+	return store.DLQFilter{IDs: f.IDs, Command: f.Command, Since: f.Since, Before: f.Before}
+}
+
+// ParseJob decodes the job json accepted by `queuectl enqueue`, including
+// the run_at (RFC3339) and delay (Go duration) fields, and validates it.
+func ParseJob(data []byte) (Job, error) {
+	var spec model.JobSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return Job{}, fmt.Errorf("invalid job json: %w", err)
+	}
+	m, err := spec.ToJob(time.Now().UTC())
+	if err != nil {
+		return Job{}, err
+	}
+	j := fromModel(m)
+	j.State = ""
+	return j, nil
+}
+
+// validate checks j the way ParseJob checks job json and returns the job
+// to store.
+func (j Job) validate(now time.Time) (model.Job, error) {
+	m, err := model.JobSpec{Job: j.toModel()}.ToJob(now)
+	if err != nil {
+		return model.Job{}, err
+	}
+	if !j.RunAt.IsZero() {
+		m.AvailableAt = j.RunAt.UTC()
+	}
+	return m, nil
+}
+
+func (j Job) toModel() model.Job {
+	return model.Job{
+		ID:          j.ID,
+		Command:     j.Command,
+		Args:        j.Args,
+		Env:         j.Env,
+		Workdir:     j.Workdir,
+		Type:        j.Type,
+		Payload:     j.Payload,
+		Queue:       j.Queue,
+		Priority:    j.Priority,
+		MaxRetries:  j.MaxRetries,
+		Timeout:     j.Timeout,
+		DependsOn:   j.DependsOn,
+		AvailableAt: j.RunAt,
+		UniqueKey:   j.UniqueKey,
+		UniqueFor:   j.UniqueFor,
+	}
+}
+
+func fromModel(m model.Job) Job {
+	return Job{
+		ID:         m.ID,
+		Command:    m.Command,
+		Args:       m.Args,
+		Env:        m.Env,
+		Workdir:    m.Workdir,
+		Type:       m.Type,
+		Payload:    m.Payload,
+		Queue:      m.Queue,
+		Priority:   m.Priority,
+		MaxRetries: m.MaxRetries,
+		Timeout:    m.Timeout,
+		DependsOn:  m.DependsOn,
+		RunAt:      m.AvailableAt,
+		UniqueKey:  m.UniqueKey,
+		UniqueFor:  m.UniqueFor,
+		State:      m.State,
+		Attempts:   m.Attempts,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+
+		CancelRequested: m.CancelRequested,
+		LastError:       m.LastError,
+		FailedAt:        m.FailedAt,
+	}
+}
+
+// Display returns what the job runs, as shown by `queuectl list`.
+func (j Job) Display() string {
+	return j.toModel().Display()
+}
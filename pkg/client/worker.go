@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"queuectl/internal/engine"
+	"queuectl/internal/metrics"
+	"queuectl/internal/store"
+)
+
+// Handler runs jobs of one Type in the worker process.
+type Handler = engine.Handler
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc = engine.HandlerFunc
+
+// ErrPermanent, wrapped in a handler's error, sends the job straight to the
+// DLQ instead of retrying it.
+var ErrPermanent = engine.ErrPermanent
+
+// Register makes fn the handler for jobs enqueued with the given Type. Call
+// it before starting workers. It panics if the type is already registered.
+func Register(jobType string, fn HandlerFunc) {
+	engine.Register(jobType, fn)
+}
+
+// Worker claims and runs jobs. Create one with Client.NewWorker.
+type Worker struct {
+	c           *Client
+	transport   engine.Transport
+	concurrency int
+	queues      []engine.QueueWeight
+	lease       time.Duration
+	base, cap   int
+	backoffSet  bool
+	outputMax   int
+	killGrace   time.Duration
+	scheduler   bool
+	metricsAddr string
+}
+
+// WorkerOption configures a Worker.
+type WorkerOption func(*Worker) error
+
+// WithConcurrency runs n jobs at a time. The default is the
+// worker_concurrency config, which is followed as it changes.
+func WithConcurrency(n int) WorkerOption {
+	return func(w *Worker) error {
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency %d", n)
+		}
+		w.concurrency = n
+		return nil
+	}
+}
+
+// WithQueues restricts the worker to the given queues, with optional
+// weights, e.g. "emails:3,reports". The default is every queue.
+func WithQueues(spec string) WorkerOption {
+	return func(w *Worker) error {
+		queues, err := engine.ParseQueues(spec)
+		if err != nil {
+			return err
+		}
+		w.queues = queues
+		return nil
+	}
+}
+
+// WithLease sets how long a claim lasts without a heartbeat, overriding the
+// lease_seconds config and any later change to it.
+func WithLease(d time.Duration) WorkerOption {
+	return func(w *Worker) error {
+		if d < time.Second {
+			return fmt.Errorf("lease %s is shorter than 1s", d)
+		}
+		w.lease = d
+		return nil
+	}
+}
+
+// WithBackoff sets the retry delay to base^attempts seconds, capped at
+// capSeconds, overriding the backoff_base and backoff_cap_seconds config and
+// any later change to them.
+func WithBackoff(base, capSeconds int) WorkerOption {
+	return func(w *Worker) error {
+		if base < 1 || capSeconds < 0 {
+			return fmt.Errorf("invalid backoff base %d cap %d", base, capSeconds)
+		}
+		w.base, w.cap, w.backoffSet = base, capSeconds, true
+		return nil
+	}
+}
+
+// WithOutputLimit caps the stdout and stderr kept per attempt, in bytes.
+func WithOutputLimit(bytes int) WorkerOption {
+	return func(w *Worker) error {
+		w.outputMax = bytes
+		return nil
+	}
+}
+
+// WithKillGrace sets how long a timed out command gets between SIGTERM and
+// SIGKILL.
+func WithKillGrace(d time.Duration) WorkerOption {
+	return func(w *Worker) error {
+		w.killGrace = d
+		return nil
+	}
+}
+
+// WithScheduler also runs the recurring job scheduler alongside the worker.
+func WithScheduler() WorkerOption {
+	return func(w *Worker) error {
+		w.scheduler = true
+		return nil
+	}
+}
+
+// WithMetricsAddr serves Prometheus metrics for this worker at
+// http://addr/metrics while it runs. Bind it to a private interface: the
+// endpoint has no authentication.
+func WithMetricsAddr(addr string) WorkerOption {
+	return func(w *Worker) error {
+		w.metricsAddr = addr
+		return nil
+	}
+}
+
+// NewWorker returns a worker for this client's queue. Settings not given as
+// options come from the queue's config.
+func (c *Client) NewWorker(opts ...WorkerOption) (*Worker, error) {
+	return newWorker(c, c.st, opts)
+}
+
+// NewRemoteWorker returns a worker that claims and reports jobs through the
+// HTTP API of `queuectl serve` at serverURL instead of opening the database.
+// Settings not given as options come from the server's config.
+func NewRemoteWorker(serverURL, token string, opts ...WorkerOption) (*Worker, error) {
+	return newWorker(nil, engine.NewRemoteTransport(serverURL, token), opts)
+}
+
+func newWorker(c *Client, t engine.Transport, opts []WorkerOption) (*Worker, error) {
+	w := &Worker{c: c, transport: t, outputMax: -1, killGrace: -1}
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+	if w.scheduler && c == nil {
+		return nil, fmt.Errorf("the scheduler needs direct access to the database and cannot run in a remote worker")
+	}
+	if w.scheduler && c.sqlite() == nil {
+		return nil, fmt.Errorf("the scheduler needs a SQLite database and cannot run on an in-memory queue")
+	}
+	return w, nil
+}
+
+// Run processes jobs until ctx is cancelled or `queuectl worker stop` is
+// run. After `queuectl worker stop` the jobs in flight finish first; when
+// ctx is cancelled they are stopped, recorded as failed attempts and left
+// to be retried. Config changes made
+// while it runs apply between jobs, except to settings given as options. A
+// worker with a SQLite database also enforces the DLQ retention policy
+// while it runs.
+func (w *Worker) Run(ctx context.Context) error {
+	engine.RemoveStopFile()
+
+	var m *metrics.Metrics
+	if w.metricsAddr != "" {
+		m = metrics.New()
+		stop, err := w.serveMetrics(m)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	if w.scheduler {
+		schedCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go engine.NewScheduler(w.c.sqlite()).Run(schedCtx)
+	}
+	if w.c != nil && w.c.sqlite() != nil {
+		janitorCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go engine.NewJanitor(w.c.sqlite()).Run(janitorCtx)
+	}
+
+	pool := engine.NewPool(w.transport)
+	pool.Queues = w.queues
+	pool.Metrics = m
+	pool.Override = w.pin
+	pool.Run(ctx)
+	return nil
+}
+
+// pin keeps the settings given as options whatever config says.
+func (w *Worker) pin(s *engine.Settings) {
+	if w.concurrency > 0 {
+		s.Concurrency = w.concurrency
+	}
+	if w.lease > 0 {
+		s.Lease = w.lease
+	}
+	if w.backoffSet {
+		s.Base, s.Cap = w.base, w.cap
+	}
+	if w.outputMax >= 0 {
+		s.OutputMax = w.outputMax
+	}
+	if w.killGrace >= 0 {
+		s.KillGrace = w.killGrace
+	}
+}
+
+// serveMetrics starts the metrics listener and returns a func that stops it.
+// Queue gauges are included when the worker has the database.
+func (w *Worker) serveMetrics(m *metrics.Metrics) (func(), error) {
+	ln, err := net.Listen("tcp", w.metricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listener: %w", err)
+	}
+
+	var st store.Backend
+	if w.c != nil {
+		st = w.c.st
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler(m, st))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}